@@ -0,0 +1,65 @@
+// Command generator writes a fixture file with synthetic users and product
+// data that MockIn can load at startup.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+	"github.com/luikyv/go-open-insurance/internal/generator"
+	"gopkg.in/yaml.v3"
+)
+
+func main() {
+	var opts generator.Options
+	flag.Uint64Var(&opts.Seed, "seed", 1, "seed of the random data, the same seed always generates the same data")
+	flag.IntVar(&opts.Users, "users", 1, "number of users to generate")
+	flag.IntVar(&opts.PlansPerUser, "plans", 1, "number of capitalization title plans per user")
+	flag.StringVar(&opts.Password, "password", "", "password of the users generated")
+	format := flag.String("format", "yaml", "output format, yaml or json")
+	out := flag.String("out", "", "file to write the fixture to, defaults to the standard output")
+	flag.Parse()
+
+	swagger, err := api.GetSwagger()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	f, err := generator.Generate(swagger, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch *format {
+	case "json":
+	case "yaml":
+		// Go through a generic value so the YAML keys are the same as the
+		// JSON ones.
+		var raw any
+		if err := json.Unmarshal(data, &raw); err != nil {
+			log.Fatal(err)
+		}
+		if data, err = yaml.Marshal(raw); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("unknown format %s", *format)
+	}
+
+	if *out == "" {
+		_, err = os.Stdout.Write(data)
+	} else {
+		err = os.WriteFile(*out, data, 0o644)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
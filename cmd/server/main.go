@@ -2,16 +2,25 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/getkin/kin-openapi/routers/gorillamux"
@@ -23,9 +32,13 @@ import (
 	"github.com/luikyv/go-open-insurance/internal/consent"
 	"github.com/luikyv/go-open-insurance/internal/customer"
 	"github.com/luikyv/go-open-insurance/internal/endorsement"
+	"github.com/luikyv/go-open-insurance/internal/fixture"
+	"github.com/luikyv/go-open-insurance/internal/generator"
 	"github.com/luikyv/go-open-insurance/internal/oidc"
 	"github.com/luikyv/go-open-insurance/internal/quoteauto"
 	"github.com/luikyv/go-open-insurance/internal/resource"
+	"github.com/luikyv/go-open-insurance/internal/scheduler"
+	"github.com/luikyv/go-open-insurance/internal/snapshot"
 	"github.com/luikyv/go-open-insurance/internal/user"
 	"github.com/luikyv/go-open-insurance/internal/webhook"
 	"github.com/oapi-codegen/runtime/strictmiddleware/nethttp"
@@ -35,16 +48,29 @@ import (
 )
 
 var (
-	dbSchema              = getEnv("MOCKIN_DB_SCHEMA", "mockin")
-	dbStringConnection    = getEnv("MOCKIN_DB_CONNECTION", "mongodb://localhost:27017/mockin")
-	port                  = getEnv("MOCKIN_PORT", "80")
-	awsBaseEndpoint       = getEnv("MOCKIN_AWS_BASE_ENDPOINT", "http://localhost:4566")
-	host                  = getEnv("MOCKIN_HOST", "https://mockin.local")
-	mtlsHost              = getEnv("MOCKIN_MTLS_HOST", "https://matls-mockin.local")
-	kmsSigningKeyAlias    = getEnv("MOCKIN_KMS_SIGNING_KEY_ALIAS", "alias/mockin/signing-key")
-	kmsEncryptionKeyAlias = getEnv("MOCKIN_KMS_ENCRYPTION_KEY_ALIAS", "alias/mockin/encryption-key")
-	apiPrefixOIDC         = "/auth"
-	apiPrefixOPIN         = "/open-insurance"
+	storageBackend             = getEnv("MOCKIN_STORAGE", "sqlite")
+	dbSchema                   = getEnv("MOCKIN_DB_SCHEMA", "mockin")
+	dbStringConnection         = getEnv("MOCKIN_DB_CONNECTION", "mongodb://localhost:27017/mockin")
+	sqlitePath                 = getEnv("MOCKIN_SQLITE_PATH", "mockin.db")
+	port                       = getEnv("MOCKIN_PORT", "80")
+	tlsPort                    = getEnv("MOCKIN_TLS_PORT", "")
+	awsBaseEndpoint            = getEnv("MOCKIN_AWS_BASE_ENDPOINT", "http://localhost:4566")
+	host                       = getEnv("MOCKIN_HOST", "https://mockin.local")
+	mtlsHost                   = getEnv("MOCKIN_MTLS_HOST", "https://matls-mockin.local")
+	keyProvider                = getEnv("MOCKIN_KEY_PROVIDER", "file")
+	kmsSigningKeyAlias         = getEnv("MOCKIN_KMS_SIGNING_KEY_ALIAS", "alias/mockin/signing-key")
+	kmsEncryptionKeyAlias      = getEnv("MOCKIN_KMS_ENCRYPTION_KEY_ALIAS", "alias/mockin/encryption-key")
+	keyRotationIntervalSecs    = getEnv("MOCKIN_KEY_ROTATION_INTERVAL_SECS", "0")
+	keyPublishPeriodSecs       = getEnv("MOCKIN_KEY_PUBLISH_PERIOD_SECS", "60")
+	keyGracePeriodSecs         = getEnv("MOCKIN_KEY_GRACE_PERIOD_SECS", "600")
+	sweepIntervalSecs          = getEnv("MOCKIN_SWEEP_INTERVAL_SECS", "60")
+	autoApproveUsers           = getEnv("MOCKIN_AUTO_APPROVE_USERS", "")
+	adminToken                 = getEnv("MOCKIN_ADMIN_TOKEN", "")
+	fixturesDir                = getEnv("MOCKIN_FIXTURES_DIR", "")
+	fixturesReloadIntervalSecs = getEnv("MOCKIN_FIXTURES_RELOAD_INTERVAL_SECS", "5")
+	apiPrefixOIDC              = "/auth"
+	apiPrefixOPIN              = "/open-insurance"
+	apiPrefixAdmin             = "/admin"
 )
 
 type ConsentServerV2 = consent.ServerV2
@@ -63,36 +89,53 @@ type opinServer struct {
 }
 
 func main() {
-	kmsClient := kmsClient()
-	db, err := dbConnection()
+	keys, err := newKeyProvider()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// Storage.
-	userStorage := user.NewStorage()
-	consentStorage := consent.NewStorage(db)
-	idempotencyStorage := api.NewIdempotencyStorage(db)
-	resourceStorage := resource.NewStorage()
-	customerStorage := customer.NewStorage()
-	capTitleStorage := capitalizationtitle.NewStorage()
-	quoteAutoStorage := quoteauto.NewStorage(db)
+	st, err := newStorages()
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Services.
-	userService := user.NewService(userStorage)
-	consentService := consent.NewService(consentStorage, userService)
+	userService := user.NewService(st.user)
+	consentService := consent.NewService(
+		st.consent,
+		userService,
+		st.grantSessionManager,
+	)
+	resourceService := resource.NewService(st.resource, consentService)
 	// OpenID Provider.
-	op, err := openidProvider(db, kmsClient, userService, consentService)
+	op, err := openidProvider(
+		keys,
+		st.clientManager,
+		st.authnSessionManager,
+		st.grantSessionManager,
+		st.userSessionManager,
+		userService,
+		consentService,
+		resourceService,
+	)
 	if err != nil {
 		log.Fatal(err)
 	}
 	webhookService := webhook.NewService(op, httpClientFunc())
-	idempotencyService := api.NewIdempotencyService(idempotencyStorage)
-	resourceService := resource.NewService(resourceStorage, consentService)
-	customerService := customer.NewService(customerStorage)
-	capitalizationtitleService := capitalizationtitle.NewService(capTitleStorage, resourceService)
+	idempotencyService := api.NewIdempotencyService(st.idempotency)
+	customerService := customer.NewService(st.customer)
+	capitalizationtitleService := capitalizationtitle.NewService(st.capitalizationTitle, resourceService)
 	endorsementService := endorsement.NewService(consentService, resourceService)
-	quoteAutoService := quoteauto.NewService(quoteAutoStorage, webhookService)
+	quoteAutoService := quoteauto.NewService(st.quoteAuto, webhookService)
+	snapshotService := snapshot.NewService(
+		userService,
+		st.clientManager,
+		consentService,
+		quoteAutoService,
+		customerService,
+		resourceService,
+		capitalizationtitleService,
+	)
 
 	// Server.
 	server := opinServer{
@@ -108,10 +151,12 @@ func main() {
 		server,
 		[]nethttp.StrictHTTPMiddlewareFunc{
 			api.MetaMiddleware(mtlsHost),
+			// The middlewares run in reverse order, so idempotency keys are
+			// only reserved for authenticated clients.
+			api.IdempotencyMiddleware(idempotencyService),
 			api.CacheControlMiddleware(),
 			api.AuthPermissionMiddleware(consentService),
 			api.AuthScopeMiddleware(op),
-			api.IdempotencyMiddleware(idempotencyService),
 			api.FAPIIDMiddleware(),
 		},
 		api.StrictHTTPServerOptions{
@@ -133,21 +178,64 @@ func main() {
 	opinHandler = api.SchemaValidationMiddleware(opinHandler, router)
 	opinHandler = api.ResponseEncodingMiddleware(opinHandler)
 
+	fixtureLoader := fixture.NewLoader(
+		fixturesDirPath(),
+		swagger,
+		userService,
+		customerService,
+		resourceService,
+		capitalizationtitleService,
+	)
+
 	mux := http.NewServeMux()
 	mux.Handle(apiPrefixOIDC+"/", op.Handler())
+	mux.Handle("POST "+apiPrefixOIDC+"/logout", oidc.LogoutHandler(st.userSessionManager))
 	mux.Handle(apiPrefixOPIN+"/", opinHandler)
 
+	schemaValidator := fixture.SchemaValidator(swagger.Components.Schemas)
+	adminMux := http.NewServeMux()
+	user.RegisterAdminHandlers(adminMux, apiPrefixAdmin, userService)
+	oidc.RegisterAdminHandlers(adminMux, apiPrefixAdmin, userService, consentService, resourceService)
+	oidc.RegisterKeyAdminHandlers(adminMux, apiPrefixAdmin, keys)
+	customer.RegisterAdminHandlers(adminMux, apiPrefixAdmin, customerService, schemaValidator)
+	resource.RegisterAdminHandlers(adminMux, apiPrefixAdmin, resourceService, schemaValidator)
+	capitalizationtitle.RegisterAdminHandlers(adminMux, apiPrefixAdmin, capitalizationtitleService, schemaValidator)
+	generator.RegisterAdminHandlers(adminMux, apiPrefixAdmin, swagger, fixtureLoader)
+	snapshot.RegisterAdminHandlers(adminMux, apiPrefixAdmin, snapshotService)
+	if adminToken == "" {
+		adminToken, err = randomToken()
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("MOCKIN_ADMIN_TOKEN is not set, the admin API accepts the token %s", adminToken)
+	}
+	mux.Handle(apiPrefixAdmin+"/", api.AdminAuthMiddleware(adminToken, adminMux))
+
 	// Run.
-	if err := loadMocks(
-		userService,
-		customerService,
-		resourceService,
-		capitalizationtitleService,
-	); err != nil {
+	if err := fixtureLoader.Load(context.Background()); err != nil {
 		log.Fatal(err)
 	}
+	go fixtureReloader(fixtureLoader).Run(context.Background())
+	go keyRotator(keys).Run(context.Background())
+	go expirySweeper(
+		consentService,
+		quoteAutoService,
+		idempotencyService,
+		st.authnSessionManager,
+		st.grantSessionManager,
+		st.userSessionManager,
+	).Run(context.Background())
+	handler := http.Handler(mux)
+	if tlsPort != "" {
+		go func() {
+			log.Fatal(serveTLS(mux))
+		}()
+		// The client certificates are verified by the TLS listener, so the
+		// header can't be trusted on the plain HTTP port.
+		handler = withoutClientCertHeader(mux)
+	}
 	s := &http.Server{
-		Handler: mux,
+		Handler: handler,
 		Addr:    net.JoinHostPort("0.0.0.0", port),
 	}
 	if err := s.ListenAndServe(); err != nil {
@@ -155,6 +243,44 @@ func main() {
 	}
 }
 
+// serveTLS serves handler over TLS with the server certificate generated by
+// cmd/keymaker. Client certificates are verified against the client CA, so
+// mTLS works without a reverse proxy in front of MockIn.
+func serveTLS(handler http.Handler) error {
+	keysDir := keysDirPath()
+	caPEM, err := os.ReadFile(filepath.Join(keysDir, "client_ca.crt"))
+	if err != nil {
+		return fmt.Errorf("could not read the client CA: %w", err)
+	}
+
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caPEM) {
+		return errors.New("could not parse the client CA")
+	}
+
+	s := &http.Server{
+		Handler: handler,
+		Addr:    net.JoinHostPort("0.0.0.0", tlsPort),
+		TLSConfig: &tls.Config{
+			// The certificate is optional at the TLS level, since not every
+			// endpoint requires it.
+			ClientAuth: tls.VerifyClientCertIfGiven,
+			ClientCAs:  clientCAs,
+			MinVersion: tls.VersionTLS12,
+		},
+	}
+	return s.ListenAndServeTLS(filepath.Join(keysDir, "server.crt"), filepath.Join(keysDir, "server.key"))
+}
+
+// withoutClientCertHeader removes the client certificate forwarded by a
+// reverse proxy from the requests.
+func withoutClientCertHeader(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del(oidc.HeaderClientCert)
+		handler.ServeHTTP(w, r)
+	})
+}
+
 func dbConnection() (*mongo.Database, error) {
 	ctx := context.Background()
 
@@ -177,11 +303,103 @@ func dbConnection() (*mongo.Database, error) {
 	return conn.Database(dbSchema), nil
 }
 
+// storages groups the storage of every domain.
+type storages struct {
+	user                user.Storage
+	consent             consent.Storage
+	idempotency         api.IdempotencyStorage
+	resource            resource.Storage
+	customer            customer.Storage
+	capitalizationTitle capitalizationtitle.Storage
+	quoteAuto           quoteauto.Storage
+	clientManager       oidc.ClientManager
+	authnSessionManager oidc.AuthnSessionManager
+	grantSessionManager oidc.GrantSessionManager
+	userSessionManager  oidc.UserSessionManager
+}
+
+// newStorages creates the storages of the backend set in MOCKIN_STORAGE.
+// "memory" keeps everything in memory so no external database is needed,
+// whereas "mongo" persists the data in the database informed with
+// MOCKIN_DB_CONNECTION and "sqlite", the default, in the file informed with
+// MOCKIN_SQLITE_PATH.
+func newStorages() (storages, error) {
+	switch storageBackend {
+	case "memory":
+		return memoryStorages(), nil
+	case "mongo":
+		db, err := dbConnection()
+		if err != nil {
+			return storages{}, err
+		}
+		return mongoStorages(db)
+	case "sqlite":
+		db, err := api.OpenSQLite(sqlitePath)
+		if err != nil {
+			return storages{}, err
+		}
+		return sqliteStorages(db)
+	default:
+		return storages{}, fmt.Errorf("unknown storage backend %q", storageBackend)
+	}
+}
+
+func memoryStorages() storages {
+	return storages{
+		user:                user.NewMemoryStorage(),
+		consent:             consent.NewMemoryStorage(),
+		idempotency:         api.NewMemoryIdempotencyStorage(),
+		resource:            resource.NewMemoryStorage(),
+		customer:            customer.NewMemoryStorage(),
+		capitalizationTitle: capitalizationtitle.NewMemoryStorage(),
+		quoteAuto:           quoteauto.NewMemoryStorage(),
+		clientManager:       oidc.NewMemoryClientManager(),
+		authnSessionManager: oidc.NewMemoryAuthnSessionManager(),
+		grantSessionManager: oidc.NewMemoryGrantSessionManager(),
+		userSessionManager:  oidc.NewMemoryUserSessionManager(),
+	}
+}
+
+func mongoStorages(db *mongo.Database) (storages, error) {
+	userStorage := user.NewMongoStorage(db)
+	idempotencyStorage := api.NewMongoIdempotencyStorage(db)
+	authnSessionManager := oidc.NewMongoAuthnSessionManager(db)
+	grantSessionManager := oidc.NewMongoGrantSessionManager(db)
+	userSessionManager := oidc.NewMongoUserSessionManager(db)
+	if err := createIndexes(
+		userStorage,
+		idempotencyStorage,
+		authnSessionManager,
+		grantSessionManager,
+		userSessionManager,
+	); err != nil {
+		return storages{}, err
+	}
+
+	return storages{
+		user:                userStorage,
+		consent:             consent.NewMongoStorage(db),
+		idempotency:         idempotencyStorage,
+		resource:            resource.NewMongoStorage(db),
+		customer:            customer.NewMongoStorage(db),
+		capitalizationTitle: capitalizationtitle.NewMongoStorage(db),
+		quoteAuto:           quoteauto.NewMongoStorage(db),
+		clientManager:       oidc.NewMongoClientManager(db),
+		authnSessionManager: authnSessionManager,
+		grantSessionManager: grantSessionManager,
+		userSessionManager:  userSessionManager,
+	}, nil
+}
+
 func openidProvider(
-	db *mongo.Database,
-	kmsClient *kms.Client,
+	keys oidc.KeyProvider,
+	clientManager oidc.ClientManager,
+	authnSessionManager oidc.AuthnSessionManager,
+	grantSessionManager oidc.GrantSessionManager,
+	userSessionManager oidc.UserSessionManager,
 	userService user.Service,
 	consentService consent.Service,
+	resourceService resource.Service,
 ) (
 	provider.Provider,
 	error,
@@ -191,20 +409,19 @@ func openidProvider(
 	_, filename, _, _ := runtime.Caller(0)
 	sourceDir := filepath.Dir(filename)
 
-	// TODO: This will cause problems for the docker file.
-	keysDir := filepath.Join(sourceDir, "../../keys")
+	keysDir := keysDirPath()
 	templatesDirPath := filepath.Join(sourceDir, "../../templates")
 
 	return provider.New(
 		goidc.ProfileOpenID,
 		host,
-		oidc.JWKSFunc(kmsClient, kmsSigningKeyAlias, kmsEncryptionKeyAlias),
-		provider.WithSignFunc(oidc.SignFunc(kmsClient, kmsSigningKeyAlias)),
-		provider.WithDecryptFunc(oidc.DecryptFunc(kmsClient, kmsEncryptionKeyAlias)),
+		keys.JWKS,
+		provider.WithSignFunc(keys.Sign),
+		provider.WithDecryptFunc(keys.Decrypt),
 		provider.WithPathPrefix(apiPrefixOIDC),
-		provider.WithClientStorage(oidc.NewClientManager(db)),
-		provider.WithAuthnSessionStorage(oidc.NewAuthnSessionManager(db)),
-		provider.WithGrantSessionStorage(oidc.NewGrantSessionManager(db)),
+		provider.WithClientStorage(clientManager),
+		provider.WithAuthnSessionStorage(authnSessionManager),
+		provider.WithGrantSessionStorage(grantSessionManager),
 		provider.WithScopes(api.Scopes...),
 		provider.WithTokenOptions(oidc.TokenOptionsFunc()),
 		provider.WithAuthorizationCodeGrant(),
@@ -217,8 +434,8 @@ func openidProvider(
 		provider.WithTLSCertTokenBindingRequired(),
 		provider.WithPAR(60),
 		provider.WithJAR(jose.PS256),
-		provider.WithJAREncryption(jose.RSA_OAEP),
-		provider.WithJARContentEncryptionAlgs(jose.A256GCM),
+		provider.WithJAREncryption(oidc.KeyEncryptionAlgs[0], oidc.KeyEncryptionAlgs[1:]...),
+		provider.WithJARContentEncryptionAlgs(oidc.ContentEncryptionAlgs[0], oidc.ContentEncryptionAlgs[1:]...),
 		provider.WithJARM(jose.PS256),
 		provider.WithIssuerResponseParameter(),
 		provider.WithPKCE(goidc.CodeChallengeMethodSHA256),
@@ -228,7 +445,14 @@ func openidProvider(
 		provider.WithStaticClient(client("client_one", keysDir)),
 		provider.WithStaticClient(client("client_two", keysDir)),
 		provider.WithHandleGrantFunc(oidc.HandleGrantFunc(consentService)),
-		provider.WithPolicy(oidc.Policy(templatesDirPath, host+apiPrefixOIDC, userService, consentService)),
+		provider.WithPolicy(oidc.Policy(
+			templatesDirPath,
+			host+apiPrefixOIDC,
+			userService,
+			consentService,
+			resourceService,
+			userSessionManager,
+		)),
 		provider.WithNotifyErrorFunc(oidc.LogErrorFunc()),
 		provider.WithDCR(
 			oidc.DCRFunc(api.Scopes),
@@ -240,6 +464,162 @@ func openidProvider(
 	)
 }
 
+// sqliteStorages persists the consents, quotes, idempotency records, clients
+// and authn, grant and user sessions in SQLite. The other domains are kept in memory,
+// since they are loaded from the fixtures when the server starts.
+func sqliteStorages(db *sql.DB) (storages, error) {
+	consentStorage := consent.NewSQLiteStorage(db)
+	idempotencyStorage := api.NewSQLiteIdempotencyStorage(db)
+	quoteAutoStorage := quoteauto.NewSQLiteStorage(db)
+	clientManager := oidc.NewSQLiteClientManager(db)
+	authnSessionManager := oidc.NewSQLiteAuthnSessionManager(db)
+	grantSessionManager := oidc.NewSQLiteGrantSessionManager(db)
+	userSessionManager := oidc.NewSQLiteUserSessionManager(db)
+	if err := createTables(
+		consentStorage,
+		idempotencyStorage,
+		quoteAutoStorage,
+		clientManager,
+		authnSessionManager,
+		grantSessionManager,
+		userSessionManager,
+	); err != nil {
+		return storages{}, err
+	}
+
+	return storages{
+		user:                user.NewMemoryStorage(),
+		consent:             consentStorage,
+		idempotency:         idempotencyStorage,
+		resource:            resource.NewMemoryStorage(),
+		customer:            customer.NewMemoryStorage(),
+		capitalizationTitle: capitalizationtitle.NewMemoryStorage(),
+		quoteAuto:           quoteAutoStorage,
+		clientManager:       clientManager,
+		authnSessionManager: authnSessionManager,
+		grantSessionManager: grantSessionManager,
+		userSessionManager:  userSessionManager,
+	}, nil
+}
+
+// createTables creates the SQLite tables that don't exist yet.
+func createTables(storages ...interface {
+	CreateTables(ctx context.Context) error
+}) error {
+	ctx := context.Background()
+	for _, st := range storages {
+		if err := st.CreateTables(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createIndexes creates the unique indexes and the TTL indexes which make
+// mongo remove expired records.
+func createIndexes(
+	userStorage user.MongoStorage,
+	idempotencyStorage api.MongoIdempotencyStorage,
+	authnSessionManager oidc.MongoAuthnSessionManager,
+	grantSessionManager oidc.MongoGrantSessionManager,
+	userSessionManager oidc.MongoUserSessionManager,
+) error {
+	ctx := context.Background()
+	if err := userStorage.CreateIndexes(ctx); err != nil {
+		return err
+	}
+
+	if err := idempotencyStorage.CreateIndexes(ctx); err != nil {
+		return err
+	}
+
+	if err := authnSessionManager.CreateIndexes(ctx); err != nil {
+		return err
+	}
+
+	if err := grantSessionManager.CreateIndexes(ctx); err != nil {
+		return err
+	}
+
+	return userSessionManager.CreateIndexes(ctx)
+}
+
+// expirySweeper periodically moves expired consents and quotes to their final
+// status and purges expired sessions and idempotency records.
+func expirySweeper(
+	consentService consent.Service,
+	quoteAutoService quoteauto.Service,
+	idempotencyService api.IdempotencyService,
+	authnSessionManager oidc.AuthnSessionManager,
+	grantSessionManager oidc.GrantSessionManager,
+	userSessionManager oidc.UserSessionManager,
+) scheduler.Scheduler {
+	intervalSecs, err := strconv.Atoi(sweepIntervalSecs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Zero disables the sweeper.
+	if intervalSecs < 0 {
+		log.Fatalf("MOCKIN_SWEEP_INTERVAL_SECS must not be negative, got %d", intervalSecs)
+	}
+
+	return scheduler.New(
+		time.Duration(intervalSecs)*time.Second,
+		scheduler.Job{Name: "reject_expired_consents", Run: consentService.RejectExpired},
+		scheduler.Job{Name: "expire_auto_quotes", Run: quoteAutoService.ExpireQuotes},
+		scheduler.Job{Name: "purge_idempotency_records", Run: idempotencyService.DeleteExpired},
+		scheduler.Job{Name: "purge_authn_sessions", Run: authnSessionManager.DeleteExpired},
+		scheduler.Job{Name: "purge_grant_sessions", Run: grantSessionManager.DeleteExpired},
+		scheduler.Job{Name: "purge_user_sessions", Run: userSessionManager.DeleteExpired},
+	)
+}
+
+// fixtureReloader periodically loads the fixtures again when their files
+// change.
+func fixtureReloader(loader *fixture.Loader) scheduler.Scheduler {
+	intervalSecs, err := strconv.Atoi(fixturesReloadIntervalSecs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch {
+	case intervalSecs < 0:
+		log.Fatalf("MOCKIN_FIXTURES_RELOAD_INTERVAL_SECS must not be negative, got %d", intervalSecs)
+	case intervalSecs == 0:
+		// The scheduler is disabled by a zero interval.
+		log.Print("MOCKIN_FIXTURES_RELOAD_INTERVAL_SECS is 0, the fixtures are only loaded at startup")
+	}
+
+	return scheduler.New(
+		time.Duration(intervalSecs)*time.Second,
+		scheduler.Job{Name: "reload_fixtures", Run: loader.Reload},
+	)
+}
+
+// keyRotationCheckInterval is how often the key rotation policy is applied,
+// so the publish and grace periods are honored with this precision.
+const keyRotationCheckInterval = 5 * time.Second
+
+// keyRotator periodically activates and retires the server keys according to
+// the rotation policy.
+func keyRotator(keys oidc.KeyProvider) scheduler.Scheduler {
+	return scheduler.New(
+		keyRotationCheckInterval,
+		scheduler.Job{Name: "rotate_keys", Run: keys.AdvanceRotation},
+	)
+}
+
+// fixturesDirPath defaults to the fixtures directory at the root of the
+// project.
+func fixturesDirPath() string {
+	if fixturesDir != "" {
+		return fixturesDir
+	}
+
+	_, filename, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(filename), "../../fixtures")
+}
+
 func client(clientID string, keysDir string) *goidc.Client {
 	var scopes []string
 	for _, scope := range api.Scopes {
@@ -252,7 +632,7 @@ func client(clientID string, keysDir string) *goidc.Client {
 		publicJWKS.Keys = append(publicJWKS.Keys, jwk.Public())
 	}
 	rawPublicJWKS, _ := json.Marshal(publicJWKS)
-	return &goidc.Client{
+	client := &goidc.Client{
 		ID: clientID,
 		ClientMetaInfo: goidc.ClientMetaInfo{
 			TokenAuthnMethod: goidc.ClientAuthnPrivateKeyJWT,
@@ -275,6 +655,24 @@ func client(clientID string, keysDir string) *goidc.Client {
 			IDTokenContentEncAlg: jose.A128CBC_HS256,
 		},
 	}
+
+	if username, ok := autoApproveUsername(clientID); ok {
+		client.SetAttribute(oidc.ClientAttrAutoApproveUsername, username)
+	}
+	return client
+}
+
+// autoApproveUsername returns the user configured to approve the consents of
+// the client automatically.
+// MOCKIN_AUTO_APPROVE_USERS has the format "client_one=bob@mail.com,...".
+func autoApproveUsername(clientID string) (string, bool) {
+	for _, entry := range strings.Split(autoApproveUsers, ",") {
+		id, username, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if ok && id == clientID {
+			return username, true
+		}
+	}
+	return "", false
 }
 
 func privateJWKS(filePath string) jose.JSONWebKeySet {
@@ -313,12 +711,54 @@ func httpClientFunc() goidc.HTTPClientFunc {
 	}
 }
 
+// newKeyProvider creates the provider of the server keys set in
+// MOCKIN_KEY_PROVIDER.
+// "kms" uses the keys in AWS KMS, whereas "file", the default, uses the keys in
+// keys/server.jwks generated by cmd/keymaker, so no AWS emulator is needed.
+func newKeyProvider() (oidc.KeyProvider, error) {
+	policy := oidc.KeyRotationPolicy{
+		Interval:      envSecs(keyRotationIntervalSecs),
+		PublishPeriod: envSecs(keyPublishPeriodSecs),
+		GracePeriod:   envSecs(keyGracePeriodSecs),
+	}
+
+	switch keyProvider {
+	case "kms":
+		return oidc.NewKMSKeyProvider(kmsClient(), kmsSigningKeyAlias, kmsEncryptionKeyAlias, policy), nil
+	case "file":
+		path := filepath.Join(keysDirPath(), "server.jwks")
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("the server keys were not found at %s, generate them with "+
+				"'go run cmd/keymaker/main.go' or set MOCKIN_KEY_PROVIDER=kms to use AWS KMS", path)
+		}
+		return oidc.NewFileKeyProvider(path, policy)
+	default:
+		return nil, fmt.Errorf("unknown key provider %q", keyProvider)
+	}
+}
+
+// keysDirPath returns the directory where cmd/keymaker writes the keys.
+// TODO: This will cause problems for the docker file.
+func keysDirPath() string {
+	_, filename, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(filename), "../../keys")
+}
+
 func kmsClient() *kms.Client {
 	return kms.New(kms.Options{
 		BaseEndpoint: &awsBaseEndpoint,
 	})
 }
 
+// envSecs parses a number of seconds read from an environment variable.
+func envSecs(secs string) time.Duration {
+	n, err := strconv.Atoi(secs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return time.Duration(n) * time.Second
+}
+
 // getEnv retrieves an environment variable or returns a fallback value if not found
 func getEnv(key, fallback string) string {
 	if value, exists := os.LookupEnv(key); exists {
@@ -326,3 +766,12 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// randomToken generates a token that can't be guessed for the admin API.
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate the admin token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
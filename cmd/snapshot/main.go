@@ -0,0 +1,135 @@
+// Command snapshot exports the state of a running MockIn to a file and imports
+// it back, so a scenario can be reproduced later.
+//
+// Usage:
+//
+//	snapshot [flags] export|import
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/luikyv/go-open-insurance/internal/snapshot"
+)
+
+func main() {
+	baseURL := flag.String("url", "https://mockin.local", "base URL of MockIn")
+	token := flag.String("token", os.Getenv("MOCKIN_ADMIN_TOKEN"), "admin token of MockIn, defaults to MOCKIN_ADMIN_TOKEN")
+	file := flag.String("file", "snapshot.json.gz", "file to export the snapshot to or import it from")
+	insecure := flag.Bool("insecure", false, "skip the verification of the MockIn certificate")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] export|import\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *token == "" {
+		log.Fatal("the admin token must be informed with -token or MOCKIN_ADMIN_TOKEN")
+	}
+
+	client := http.DefaultClient
+	if *insecure {
+		client = &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			},
+		}
+	}
+	url := strings.TrimSuffix(*baseURL, "/") + "/admin/snapshot"
+
+	var err error
+	switch flag.Arg(0) {
+	case "export":
+		err = export(client, url, *token, *file)
+	case "import":
+		err = importFile(client, url, *token, *file)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// export downloads the snapshot and writes it to file. The snapshot is decoded
+// before being written, so a file is only created for valid snapshots.
+func export(client *http.Client, url, token, file string) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := do(client, req, token)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	s, err := snapshot.Read(resp.Body)
+	if err != nil {
+		return fmt.Errorf("could not read the snapshot: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := snapshot.Write(&buf, s); err != nil {
+		return err
+	}
+	return os.WriteFile(file, buf.Bytes(), 0o644)
+}
+
+// importFile replaces the state of MockIn by the snapshot in file.
+func importFile(client *http.Client, url, token, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s, err := snapshot.Read(f)
+	if err != nil {
+		return fmt.Errorf("could not read the snapshot at %s: %w", file, err)
+	}
+
+	var buf bytes.Buffer
+	if err := snapshot.Write(&buf, s); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/gzip")
+
+	resp, err := do(client, req, token)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// do sends the request with the admin token and fails if MockIn doesn't
+// respond with a success status.
+func do(client *http.Client, req *http.Request, token string) (*http.Response, error) {
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("mockin responded with %s: %s", resp.Status, body)
+	}
+	return resp, nil
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DecodeJSON reads the body of an admin request into v.
+func DecodeJSON(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return NewError("INVALID_REQUEST", http.StatusBadRequest, err.Error())
+	}
+	return nil
+}
+
+// WriteJSON writes the response of an admin request.
+func WriteJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// SchemaValidator checks the payload of an admin request against the OpenAPI
+// schema with the name informed. value can also be a list, in which case each
+// item is checked.
+type SchemaValidator func(schema string, value any) error
+
+// RegisterListHandlers adds endpoints at path to manage a list of records
+// that have no identifier of their own.
+// GET returns the records, POST appends one record, PUT replaces all of them
+// and DELETE removes all of them.
+// The records received are validated against the OpenAPI schema informed.
+// The function key receives the request and returns the key the list is
+// stored with, e.g. the username informed in the path.
+func RegisterListHandlers[K, T any](
+	mux *http.ServeMux,
+	path string,
+	schema string,
+	validate SchemaValidator,
+	key func(r *http.Request) K,
+	list func(ctx context.Context, key K) ([]T, error),
+	set func(ctx context.Context, key K, records []T) error,
+) {
+	// mu serializes the changes to the lists, so records appended at the same
+	// time are not lost.
+	var mu sync.Mutex
+
+	mux.HandleFunc("GET "+path, func(w http.ResponseWriter, r *http.Request) {
+		records, err := list(r.Context(), key(r))
+		if err != nil {
+			ResponseErrorMiddleware(w, r, err)
+			return
+		}
+		if records == nil {
+			records = []T{}
+		}
+		WriteJSON(w, http.StatusOK, records)
+	})
+
+	mux.HandleFunc("POST "+path, func(w http.ResponseWriter, r *http.Request) {
+		var record T
+		if err := DecodeJSON(r, &record); err != nil {
+			ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		if err := validate(schema, record); err != nil {
+			ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		k := key(r)
+		records, err := list(r.Context(), k)
+		if err != nil {
+			ResponseErrorMiddleware(w, r, err)
+			return
+		}
+		if err := set(r.Context(), k, append(records, record)); err != nil {
+			ResponseErrorMiddleware(w, r, err)
+			return
+		}
+		WriteJSON(w, http.StatusCreated, record)
+	})
+
+	mux.HandleFunc("PUT "+path, func(w http.ResponseWriter, r *http.Request) {
+		var records []T
+		if err := DecodeJSON(r, &records); err != nil {
+			ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		if err := validate(schema, records); err != nil {
+			ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err := set(r.Context(), key(r), records); err != nil {
+			ResponseErrorMiddleware(w, r, err)
+			return
+		}
+		WriteJSON(w, http.StatusOK, records)
+	})
+
+	mux.HandleFunc("DELETE "+path, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := set(r.Context(), key(r), nil); err != nil {
+			ResponseErrorMiddleware(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// AdminAuthMiddleware only lets through requests carrying the admin token as
+// a bearer token.
+// An empty token rejects every request, so the admin API is never left open.
+func AdminAuthMiddleware(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		informedToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(informedToken), []byte(token)) != 1 {
+			ResponseErrorMiddleware(w, r, NewError("UNAUTHORISED", http.StatusUnauthorized,
+				"invalid admin token"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
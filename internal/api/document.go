@@ -0,0 +1,131 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	DocumentRelCPF  = "CPF"
+	DocumentRelCNPJ = "CNPJ"
+)
+
+const (
+	// ErrorCodeMissingParameter is the error code for a required field not
+	// informed.
+	ErrorCodeMissingParameter = "PARAMETRO_NAO_INFORMADO"
+	// ErrorCodeInvalidParameter is the error code for a field informed with an
+	// invalid value.
+	ErrorCodeInvalidParameter = "PARAMETRO_INVALIDO"
+)
+
+// ValidateCPF verifies cpf has eleven digits and valid check digits.
+// field is the path of the field in the request, so clients know which
+// document was rejected.
+func ValidateCPF(field, cpf string) error {
+	return validateDocument(field, DocumentRelCPF, cpf, isCPFValid)
+}
+
+// ValidateCNPJ verifies cnpj has fourteen digits and valid check digits.
+// field is the path of the field in the request, so clients know which
+// document was rejected.
+func ValidateCNPJ(field, cnpj string) error {
+	return validateDocument(field, DocumentRelCNPJ, cnpj, isCNPJValid)
+}
+
+func validateDocument(field, rel, document string, isValid func(string) bool) error {
+	if document == "" {
+		return NewError(ErrorCodeMissingParameter, http.StatusUnprocessableEntity,
+			fmt.Sprintf("the %s %s is not informed", strings.ToLower(rel), field))
+	}
+
+	if !isValid(document) {
+		return NewError(ErrorCodeInvalidParameter, http.StatusUnprocessableEntity,
+			fmt.Sprintf("the %s %s is invalid: %s", strings.ToLower(rel), field, document))
+	}
+
+	return nil
+}
+
+// NewCPF builds a CPF from its first nine digits by appending the check
+// digits.
+func NewCPF(digits [9]int) string {
+	cpf := append([]int{}, digits[:]...)
+	cpf = append(cpf, checkDigit(cpf, []int{10, 9, 8, 7, 6, 5, 4, 3, 2}))
+	cpf = append(cpf, checkDigit(cpf, []int{11, 10, 9, 8, 7, 6, 5, 4, 3, 2}))
+	return documentString(cpf)
+}
+
+// NewCNPJ builds a CNPJ from its first twelve digits by appending the check
+// digits.
+func NewCNPJ(digits [12]int) string {
+	cnpj := append([]int{}, digits[:]...)
+	cnpj = append(cnpj, checkDigit(cnpj, []int{5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2}))
+	cnpj = append(cnpj, checkDigit(cnpj, []int{6, 5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2}))
+	return documentString(cnpj)
+}
+
+func isCPFValid(cpf string) bool {
+	digits, ok := documentDigits(cpf, 11)
+	if !ok {
+		return false
+	}
+
+	return digits[9] == checkDigit(digits[:9], []int{10, 9, 8, 7, 6, 5, 4, 3, 2}) &&
+		digits[10] == checkDigit(digits[:10], []int{11, 10, 9, 8, 7, 6, 5, 4, 3, 2})
+}
+
+func isCNPJValid(cnpj string) bool {
+	digits, ok := documentDigits(cnpj, 14)
+	if !ok {
+		return false
+	}
+
+	return digits[12] == checkDigit(digits[:12], []int{5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2}) &&
+		digits[13] == checkDigit(digits[:13], []int{6, 5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2})
+}
+
+// documentDigits converts the document into its digits.
+// Documents with the wrong length, non numeric characters or made of a single
+// repeated digit, e.g. 00000000000, are rejected.
+func documentDigits(document string, length int) ([]int, bool) {
+	if len(document) != length {
+		return nil, false
+	}
+
+	digits := make([]int, length)
+	isRepeated := true
+	for i, c := range document {
+		if c < '0' || c > '9' {
+			return nil, false
+		}
+		digits[i] = int(c - '0')
+		if digits[i] != digits[0] {
+			isRepeated = false
+		}
+	}
+
+	return digits, !isRepeated
+}
+
+func documentString(digits []int) string {
+	document := make([]byte, len(digits))
+	for i, d := range digits {
+		document[i] = byte('0' + d)
+	}
+	return string(document)
+}
+
+// checkDigit computes the modulo 11 check digit of digits using the weights.
+func checkDigit(digits []int, weights []int) int {
+	sum := 0
+	for i, d := range digits {
+		sum += d * weights[i]
+	}
+
+	if rest := sum % 11; rest >= 2 {
+		return 11 - rest
+	}
+	return 0
+}
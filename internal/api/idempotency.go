@@ -1,17 +1,35 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
+	"net/http"
+	"time"
+)
 
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+const (
+	// idempotencyRecordLifetimeSecs is for how long an idempotency key is valid.
+	idempotencyRecordLifetimeSecs = 86400
+	// idempotencyReservationLifetimeSecs is for how long a key stays reserved
+	// while its request is processed. This prevents a key from being blocked
+	// for the whole record lifetime if the server stops in the middle of a
+	// request.
+	idempotencyReservationLifetimeSecs = 60
 )
 
-var errIdempotencyNotFound = errors.New("idempotency not found")
+var (
+	errIdempotencyNotFound    = errors.New("idempotency not found")
+	errIdempotencyKeyReserved = errors.New("idempotency key already reserved")
+	// errIdempotencyReservationLost is returned when completing or releasing a
+	// reservation that expired and may have been replaced by another request.
+	errIdempotencyReservationLost = errors.New("idempotency key reservation lost")
+)
 
 type IdempotencyService struct {
 	storage IdempotencyStorage
@@ -23,74 +41,132 @@ func NewIdempotencyService(storage IdempotencyStorage) IdempotencyService {
 	}
 }
 
-func (s IdempotencyService) FetchIdempotencyResponse(
+// Reserve reserves the idempotency key of the client so the request can be
+// processed. Keys are scoped by client, so different clients can use the same
+// key.
+// If the key was already used for the same request, the record returned is
+// completed and holds the response to be sent back instead of processing the
+// request again.
+func (s IdempotencyService) Reserve(
 	ctx context.Context,
-	id string,
+	clientID string,
+	key string,
 	req any,
 ) (
-	resp string,
-	err error,
+	idempotencyRecord,
+	error,
 ) {
-	record, err := s.storage.record(ctx, id)
+	hash, err := requestHash(req)
 	if err != nil {
-		Logger(ctx).Debug(
-			"error fetching the idempotency record",
+		Logger(ctx).Error(
+			"could not hash the request",
 			slog.String("error", err.Error()),
 		)
-		return "", err
+		return idempotencyRecord{}, ErrInternal
 	}
 
-	payload, err := json.Marshal(req)
-	if err != nil {
+	// The expiration identifies the reservation, so it is truncated to the
+	// precision all the storages keep.
+	record := idempotencyRecord{
+		ID:          clientID + ":" + key,
+		RequestHash: hash,
+		ExpiresAt:   time.Now().UTC().Add(idempotencyReservationLifetimeSecs * time.Second).Truncate(time.Millisecond),
+	}
+	err = s.storage.reserve(ctx, record)
+	if err == nil {
+		return record, nil
+	}
+
+	if !errors.Is(err, errIdempotencyKeyReserved) {
 		Logger(ctx).Error(
-			"could not marshal the request",
+			"could not reserve the idempotency key",
 			slog.String("error", err.Error()),
-			slog.Any("request", req),
 		)
-		return "", ErrInternal
+		return idempotencyRecord{}, ErrInternal
 	}
 
-	if string(payload) != record.Request {
+	previous, err := s.storage.record(ctx, record.ID)
+	if err != nil && !errors.Is(err, errIdempotencyNotFound) {
+		Logger(ctx).Error(
+			"could not fetch the idempotency record",
+			slog.String("error", err.Error()),
+		)
+		return idempotencyRecord{}, ErrInternal
+	}
+
+	// The record may have been released since the reservation was attempted,
+	// in which case the client can simply try again.
+	if errors.Is(err, errIdempotencyNotFound) || !previous.isCompleted() {
+		Logger(ctx).Debug("request with the same idempotency key in progress")
+		return idempotencyRecord{}, NewError("ERRO_IDEMPOTENCIA", http.StatusUnprocessableEntity,
+			"a request with the same idempotency key is still being processed")
+	}
+
+	if previous.RequestHash != hash {
 		Logger(ctx).Debug("requested payload doesn't match the previous one sent for idempotency")
-		return "", errors.New("requested payload doesn't match the previous one sent for idempotency")
+		return idempotencyRecord{}, NewError("ERRO_IDEMPOTENCIA", http.StatusUnprocessableEntity,
+			"the payload doesn't match the previous one sent with the same idempotency key")
 	}
 
-	return record.Response, nil
+	return previous, nil
 }
 
-func (s IdempotencyService) CreateIdempotency(
+// Complete stores the response of a reserved request so it can be sent back
+// for the following requests with the same idempotency key.
+// The completed record is returned even if it could not be stored. If the
+// reservation expired in the meantime, errIdempotencyReservationLost is
+// returned and the record stored is left untouched.
+func (s IdempotencyService) Complete(
 	ctx context.Context,
-	id string,
-	req any,
-	resp any,
-) error {
-	reqPayload, err := json.Marshal(req)
-	if err != nil {
+	reservation idempotencyRecord,
+	statusCode int,
+	header http.Header,
+	body []byte,
+) (
+	idempotencyRecord,
+	error,
+) {
+	record := reservation
+	record.StatusCode = statusCode
+	record.Header = header
+	record.Response = string(body)
+	record.ExpiresAt = time.Now().UTC().Add(idempotencyRecordLifetimeSecs * time.Second)
+	if err := s.storage.complete(ctx, reservation, record); err != nil {
+		if errors.Is(err, errIdempotencyReservationLost) {
+			Logger(ctx).Warn("the idempotency key reservation expired before the request completed")
+			return record, err
+		}
 		Logger(ctx).Error(
-			"could not marshal the request",
+			"could not save the idempotency record",
 			slog.String("error", err.Error()),
-			slog.Any("request", resp),
 		)
-		return err
+		return record, err
 	}
-	respPayload, err := json.Marshal(resp)
-	if err != nil {
+
+	return record, nil
+}
+
+// Release frees the idempotency key of a request that failed, so the client
+// can try again with the same key.
+// Nothing is done if the reservation already expired.
+func (s IdempotencyService) Release(ctx context.Context, reservation idempotencyRecord) error {
+	if err := s.storage.release(ctx, reservation); err != nil {
 		Logger(ctx).Error(
-			"could not marshal the response",
+			"could not release the idempotency key",
 			slog.String("error", err.Error()),
-			slog.Any("request", resp),
 		)
 		return err
 	}
 
-	Logger(ctx).Info("requested payload doesn't match the previous one sent for idempotency")
-	if err := s.storage.save(ctx, idempotencyRecord{
-		ID:       id,
-		Request:  string(reqPayload),
-		Response: string(respPayload),
-	}); err != nil {
+	return nil
+}
+
+// DeleteExpired removes the idempotency records whose keys are no longer
+// valid.
+func (s IdempotencyService) DeleteExpired(ctx context.Context) error {
+	if err := s.storage.deleteExpired(ctx); err != nil {
 		Logger(ctx).Error(
-			"could not save the idempotency record",
+			"could not delete the expired idempotency records",
 			slog.String("error", err.Error()),
 		)
 		return err
@@ -99,58 +175,65 @@ func (s IdempotencyService) CreateIdempotency(
 	return nil
 }
 
+// idempotencyRecord is kept for each idempotency key used by a client.
+// While the request is processed, the record only reserves the key and has no
+// status code.
 type idempotencyRecord struct {
-	ID       string `bson:"_id"`
-	Request  string `bson:"request"`
-	Response string `bson:"response"`
+	// ID is the idempotency key prefixed with the client ID.
+	ID          string      `bson:"_id"`
+	RequestHash string      `bson:"request_hash"`
+	StatusCode  int         `bson:"status_code,omitempty"`
+	Header      http.Header `bson:"header,omitempty"`
+	Response    string      `bson:"response,omitempty"`
+	ExpiresAt   time.Time   `bson:"expires_at"`
 }
 
-type IdempotencyStorage struct {
-	collection *mongo.Collection
+func (r idempotencyRecord) isCompleted() bool {
+	return r.StatusCode != 0
 }
 
-func NewIdempotencyStorage(db *mongo.Database) IdempotencyStorage {
-	return IdempotencyStorage{
-		collection: db.Collection("idempotency"),
+// requestHash returns a hash of the canonical JSON representation of the
+// request, i.e. with its object keys sorted.
+// The type of the request is also hashed, so the same key cannot be reused for
+// a different operation.
+func requestHash(req any) (string, error) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return "", err
 	}
-}
 
-func (s IdempotencyStorage) save(ctx context.Context, record idempotencyRecord) error {
-	shouldUpsert := true
-	filter := bson.D{{Key: "_id", Value: record.ID}}
-	if _, err := s.collection.ReplaceOne(
-		ctx,
-		filter,
-		record,
-		&options.ReplaceOptions{Upsert: &shouldUpsert},
-	); err != nil {
-		return err
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return "", err
 	}
 
-	return nil
-}
-
-func (s IdempotencyStorage) record(
-	ctx context.Context,
-	id string,
-) (
-	idempotencyRecord,
-	error,
-) {
-	filter := bson.D{{Key: "_id", Value: id}}
-
-	result := s.collection.FindOne(ctx, filter)
-	if result.Err() != nil {
-		if errors.Is(result.Err(), mongo.ErrNoDocuments) {
-			return idempotencyRecord{}, errIdempotencyNotFound
-		}
-		return idempotencyRecord{}, result.Err()
+	canonical, err := json.Marshal(value)
+	if err != nil {
+		return "", err
 	}
 
-	var record idempotencyRecord
-	if err := result.Decode(&record); err != nil {
-		return idempotencyRecord{}, err
-	}
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%T:", req)
+	hash.Write(canonical)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
 
-	return record, nil
+// IdempotencyStorage persists the idempotency records.
+// The implementations return errIdempotencyNotFound when the record doesn't
+// exist or is expired.
+type IdempotencyStorage interface {
+	// reserve saves the record unless there is a valid record with the same ID,
+	// in which case errIdempotencyKeyReserved is returned.
+	reserve(ctx context.Context, record idempotencyRecord) error
+	// complete replaces the reservation by the completed record only if the
+	// record stored is still the reservation, i.e. it has the same request hash
+	// and expiration. Otherwise, errIdempotencyReservationLost is returned.
+	complete(ctx context.Context, reservation, record idempotencyRecord) error
+	record(ctx context.Context, id string) (idempotencyRecord, error)
+	// release deletes the reservation if the record stored is still the
+	// reservation.
+	release(ctx context.Context, reservation idempotencyRecord) error
+	deleteExpired(ctx context.Context) error
 }
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryIdempotencyStorage keeps the idempotency records in memory, so they
+// are lost when the server stops.
+type MemoryIdempotencyStorage struct {
+	mu      sync.RWMutex
+	records map[string]idempotencyRecord
+}
+
+func NewMemoryIdempotencyStorage() *MemoryIdempotencyStorage {
+	return &MemoryIdempotencyStorage{
+		records: make(map[string]idempotencyRecord),
+	}
+}
+
+func (s *MemoryIdempotencyStorage) reserve(_ context.Context, record idempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if previous, ok := s.records[record.ID]; ok && previous.ExpiresAt.After(time.Now().UTC()) {
+		return errIdempotencyKeyReserved
+	}
+
+	s.records[record.ID] = record
+	return nil
+}
+
+func (s *MemoryIdempotencyStorage) complete(_ context.Context, reservation, record idempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isReserved(reservation) {
+		return errIdempotencyReservationLost
+	}
+
+	s.records[record.ID] = record
+	return nil
+}
+
+func (s *MemoryIdempotencyStorage) record(
+	_ context.Context,
+	id string,
+) (
+	idempotencyRecord,
+	error,
+) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, ok := s.records[id]
+	if !ok || !record.ExpiresAt.After(time.Now().UTC()) {
+		return idempotencyRecord{}, errIdempotencyNotFound
+	}
+
+	return record, nil
+}
+
+func (s *MemoryIdempotencyStorage) release(_ context.Context, reservation idempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.isReserved(reservation) {
+		delete(s.records, reservation.ID)
+	}
+	return nil
+}
+
+// isReserved must be called with the lock held.
+func (s *MemoryIdempotencyStorage) isReserved(reservation idempotencyRecord) bool {
+	stored, ok := s.records[reservation.ID]
+	return ok && !stored.isCompleted() &&
+		stored.RequestHash == reservation.RequestHash &&
+		stored.ExpiresAt.Equal(reservation.ExpiresAt)
+}
+
+func (s *MemoryIdempotencyStorage) deleteExpired(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	for id, record := range s.records {
+		if record.ExpiresAt.Before(now) {
+			delete(s.records, id)
+		}
+	}
+
+	return nil
+}
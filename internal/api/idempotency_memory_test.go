@@ -0,0 +1,34 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestMemoryIdempotencyStorage_Reserve reserves the same key at the same time
+// and expects only one reservation to succeed.
+func TestMemoryIdempotencyStorage_Reserve(t *testing.T) {
+	st := NewMemoryIdempotencyStorage()
+	ctx := context.Background()
+	record := idempotencyRecord{ID: "key", ExpiresAt: time.Now().UTC().Add(time.Hour)}
+
+	var reserved atomic.Int32
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := st.reserve(ctx, record); err == nil {
+				reserved.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := reserved.Load(); got != 1 {
+		t.Errorf("the key was reserved %d times, want 1", got)
+	}
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoIdempotencyStorage keeps the idempotency records in a mongo
+// collection.
+type MongoIdempotencyStorage struct {
+	collection *mongo.Collection
+}
+
+func NewMongoIdempotencyStorage(db *mongo.Database) MongoIdempotencyStorage {
+	return MongoIdempotencyStorage{
+		collection: db.Collection("idempotency"),
+	}
+}
+
+func (s MongoIdempotencyStorage) reserve(ctx context.Context, record idempotencyRecord) error {
+	// An expired record that mongo didn't remove yet is replaced. If there is a
+	// valid one, the filter doesn't match and the upsert fails with a duplicate
+	// key error.
+	shouldUpsert := true
+	filter := bson.D{
+		{Key: "_id", Value: record.ID},
+		{Key: "expires_at", Value: bson.D{{Key: "$lte", Value: time.Now().UTC()}}},
+	}
+	if _, err := s.collection.ReplaceOne(
+		ctx,
+		filter,
+		record,
+		&options.ReplaceOptions{Upsert: &shouldUpsert},
+	); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errIdempotencyKeyReserved
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s MongoIdempotencyStorage) complete(ctx context.Context, reservation, record idempotencyRecord) error {
+	result, err := s.collection.ReplaceOne(ctx, reservationFilter(reservation), record)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return errIdempotencyReservationLost
+	}
+
+	return nil
+}
+
+func (s MongoIdempotencyStorage) record(
+	ctx context.Context,
+	id string,
+) (
+	idempotencyRecord,
+	error,
+) {
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now().UTC()}}},
+	}
+
+	result := s.collection.FindOne(ctx, filter)
+	if result.Err() != nil {
+		if errors.Is(result.Err(), mongo.ErrNoDocuments) {
+			return idempotencyRecord{}, errIdempotencyNotFound
+		}
+		return idempotencyRecord{}, result.Err()
+	}
+
+	var record idempotencyRecord
+	if err := result.Decode(&record); err != nil {
+		return idempotencyRecord{}, err
+	}
+
+	return record, nil
+}
+
+// CreateIndexes creates a TTL index so mongo removes the idempotency records
+// once they expire.
+func (s MongoIdempotencyStorage) CreateIndexes(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+func (s MongoIdempotencyStorage) release(ctx context.Context, reservation idempotencyRecord) error {
+	if _, err := s.collection.DeleteOne(ctx, reservationFilter(reservation)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// reservationFilter matches the record only while it is still the
+// reservation informed.
+func reservationFilter(reservation idempotencyRecord) bson.D {
+	return bson.D{
+		{Key: "_id", Value: reservation.ID},
+		{Key: "request_hash", Value: reservation.RequestHash},
+		{Key: "expires_at", Value: reservation.ExpiresAt},
+		{Key: "status_code", Value: bson.D{{Key: "$exists", Value: false}}},
+	}
+}
+
+func (s MongoIdempotencyStorage) deleteExpired(ctx context.Context) error {
+	filter := bson.D{{Key: "expires_at", Value: bson.D{
+		{Key: "$lt", Value: time.Now().UTC()},
+	}}}
+	if _, err := s.collection.DeleteMany(ctx, filter); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// SQLiteIdempotencyStorage keeps the idempotency records in a SQLite table.
+type SQLiteIdempotencyStorage struct {
+	db *sql.DB
+}
+
+func NewSQLiteIdempotencyStorage(db *sql.DB) SQLiteIdempotencyStorage {
+	return SQLiteIdempotencyStorage{
+		db: db,
+	}
+}
+
+// CreateTables creates the idempotency table if it doesn't exist yet.
+func (s SQLiteIdempotencyStorage) CreateTables(ctx context.Context) error {
+	return SQLiteExec(
+		ctx,
+		s.db,
+		`CREATE TABLE IF NOT EXISTS idempotency (
+			id TEXT PRIMARY KEY,
+			expires_at INTEGER NOT NULL,
+			data TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idempotency_expires_at ON idempotency (expires_at)`,
+	)
+}
+
+func (s SQLiteIdempotencyStorage) reserve(ctx context.Context, record idempotencyRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	// An expired record that wasn't purged yet is replaced.
+	result, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO idempotency (id, expires_at, data) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			expires_at = excluded.expires_at,
+			data = excluded.data
+		WHERE idempotency.expires_at <= ?`,
+		record.ID,
+		record.ExpiresAt.Unix(),
+		data,
+		time.Now().UTC().Unix(),
+	)
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return errIdempotencyKeyReserved
+	}
+
+	return nil
+}
+
+func (s SQLiteIdempotencyStorage) complete(ctx context.Context, reservation, record idempotencyRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.ExecContext(
+		ctx,
+		`UPDATE idempotency SET expires_at = ?, data = ? WHERE `+sqliteReservationCondition,
+		record.ExpiresAt.Unix(),
+		data,
+		reservation.ID,
+		reservation.RequestHash,
+		reservation.ExpiresAt.Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return errIdempotencyReservationLost
+	}
+
+	return nil
+}
+
+func (s SQLiteIdempotencyStorage) record(
+	ctx context.Context,
+	id string,
+) (
+	idempotencyRecord,
+	error,
+) {
+	record, err := SQLiteFindOne[idempotencyRecord](
+		ctx,
+		s.db,
+		`SELECT data FROM idempotency WHERE id = ? AND expires_at > ?`,
+		id,
+		time.Now().UTC().Unix(),
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return idempotencyRecord{}, errIdempotencyNotFound
+	}
+	return record, err
+}
+
+func (s SQLiteIdempotencyStorage) release(ctx context.Context, reservation idempotencyRecord) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`DELETE FROM idempotency WHERE `+sqliteReservationCondition,
+		reservation.ID,
+		reservation.RequestHash,
+		reservation.ExpiresAt.Format(time.RFC3339Nano),
+	)
+	return err
+}
+
+// sqliteReservationCondition matches the record only while it is still the
+// reservation with the ID, request hash and expiration informed.
+// The expiration is compared as encoded in the data column, since the
+// expires_at column only has seconds.
+const sqliteReservationCondition = `id = ?
+	AND json_extract(data, '$.RequestHash') = ?
+	AND json_extract(data, '$.ExpiresAt') = ?
+	AND json_extract(data, '$.StatusCode') = 0`
+
+func (s SQLiteIdempotencyStorage) deleteExpired(ctx context.Context) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`DELETE FROM idempotency WHERE expires_at < ?`,
+		time.Now().UTC().Unix(),
+	)
+	return err
+}
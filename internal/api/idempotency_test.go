@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestIdempotencyService_Reserve(t *testing.T) {
+	type request struct {
+		Amount int `json:"amount"`
+	}
+	header := http.Header{"Content-Type": {"application/json"}}
+	body := []byte(`{"data":{}}`)
+
+	// completed reserves the key of the client and stores a response for it.
+	completed := func(t *testing.T, s IdempotencyService, clientID string) {
+		t.Helper()
+		reservation, err := s.Reserve(context.Background(), clientID, "key", request{Amount: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Complete(context.Background(), reservation, http.StatusCreated, header, body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name       string
+		setup      func(t *testing.T, s IdempotencyService)
+		clientID   string
+		req        request
+		wantReplay bool
+		wantErr    bool
+	}{
+		{
+			name:       "a completed key replays the response",
+			setup:      func(t *testing.T, s IdempotencyService) { completed(t, s, "client") },
+			clientID:   "client",
+			req:        request{Amount: 1},
+			wantReplay: true,
+		},
+		{
+			name:     "a different payload is rejected",
+			setup:    func(t *testing.T, s IdempotencyService) { completed(t, s, "client") },
+			clientID: "client",
+			req:      request{Amount: 2},
+			wantErr:  true,
+		},
+		{
+			name: "a key in progress is rejected",
+			setup: func(t *testing.T, s IdempotencyService) {
+				if _, err := s.Reserve(context.Background(), "client", "key", request{Amount: 1}); err != nil {
+					t.Fatal(err)
+				}
+			},
+			clientID: "client",
+			req:      request{Amount: 1},
+			wantErr:  true,
+		},
+		{
+			name:     "keys are scoped by client",
+			setup:    func(t *testing.T, s IdempotencyService) { completed(t, s, "other-client") },
+			clientID: "client",
+			req:      request{Amount: 2},
+		},
+		{
+			name: "a released key can be reused",
+			setup: func(t *testing.T, s IdempotencyService) {
+				reservation, err := s.Reserve(context.Background(), "client", "key", request{Amount: 1})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := s.Release(context.Background(), reservation); err != nil {
+					t.Fatal(err)
+				}
+			},
+			clientID: "client",
+			req:      request{Amount: 2},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := NewIdempotencyService(NewMemoryIdempotencyStorage())
+			c.setup(t, s)
+
+			record, err := s.Reserve(context.Background(), c.clientID, "key", c.req)
+			if c.wantErr {
+				var apiErr Error
+				if !errors.As(err, &apiErr) || apiErr.Code != "ERRO_IDEMPOTENCIA" ||
+					apiErr.StatusCode != http.StatusUnprocessableEntity {
+					t.Fatalf("got %v, want ERRO_IDEMPOTENCIA", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !c.wantReplay {
+				if record.isCompleted() {
+					t.Errorf("got a completed record with status %d, want a new reservation", record.StatusCode)
+				}
+				return
+			}
+			if record.StatusCode != http.StatusCreated || !maps.EqualFunc(record.Header, header, slices.Equal[[]string]) ||
+				record.Response != string(body) {
+				t.Errorf("got status %d, header %v and body %s, want %d, %v and %s",
+					record.StatusCode, record.Header, record.Response, http.StatusCreated, header, body)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"strings"
@@ -23,7 +24,12 @@ const (
 )
 
 // IdempotencyMiddleware ensures that requests with the same idempotency ID
-// are not processed multiple times, returning a cached response if available.
+// are not processed multiple times, returning the stored response if
+// available.
+// The key is reserved before the request is processed, so concurrent requests
+// with the same key are rejected instead of processed in parallel.
+// It must run after the client is authenticated, since keys are scoped by
+// client.
 func IdempotencyMiddleware(
 	service IdempotencyService,
 ) nethttp.StrictHTTPMiddlewareFunc {
@@ -51,56 +57,102 @@ func IdempotencyMiddleware(
 					"missing idempotency id header")
 			}
 
-			// Try to fetch a cached response for the idempotency ID.
-			idempotentResp, err := service.FetchIdempotencyResponse(
+			record, err := service.Reserve(
 				ctx,
+				NewRequestMeta(ctx).ClientID,
 				idempotencyID,
 				request,
 			)
-			// If a cached response exists, write it to the response writer and
-			// exit early.
-			if err == nil {
+			if err != nil {
+				return nil, err
+			}
+
+			// If the request was already processed, send the same response back.
+			if record.isCompleted() {
 				Logger(ctx).Info("return cached idempotency response")
-				writeIdempotencyResp(w, r, idempotentResp)
+				writeIdempotencyResp(w, record)
 				// returning the response as nil guarantees that the cached
 				// response won't be overwritten.
 				return nil, nil
 			}
-			// If the error was not due to "idempotency not found", return an
-			// internal error.
-			if !errors.Is(err, errIdempotencyNotFound) {
-				return nil, NewError("ERRO_IDEMPOTENCIA", http.StatusUnprocessableEntity,
-					err.Error())
-			}
 
-			// The idempotency record was not found, then process the request
-			// and cache the response for next requests with the same idempotency ID.
 			response, err = f(ctx, w, r, request)
 			if err != nil {
+				_ = service.Release(ctx, record)
 				return nil, err
 			}
-			_ = service.CreateIdempotency(
-				r.Context(),
-				idempotencyID,
-				request,
-				response,
-			)
-			return response, nil
+
+			// Write the response to a recorder so the status code and headers
+			// can be stored along with the body.
+			rec := newIdempotencyRecorder()
+			if err := visitResponse(response, rec); err != nil {
+				_ = service.Release(ctx, record)
+				return nil, err
+			}
+
+			completed, err := service.Complete(ctx, record, rec.statusCode, rec.header, rec.body.Bytes())
+			if err != nil {
+				_ = service.Release(ctx, record)
+			}
+			writeIdempotencyResp(w, completed)
+			return nil, nil
 		}
 	}
 }
 
 func writeIdempotencyResp(
 	w http.ResponseWriter,
-	r *http.Request,
-	resp string,
+	record idempotencyRecord,
 ) {
-	if r.Method == http.MethodPost {
-		w.WriteHeader(http.StatusCreated)
-	} else {
-		w.WriteHeader(http.StatusOK)
+	for key, values := range record.Header {
+		w.Header()[key] = values
 	}
-	_, _ = w.Write([]byte(resp))
+	w.WriteHeader(record.StatusCode)
+	_, _ = w.Write([]byte(record.Response))
+}
+
+// visitResponse writes a response of the strict handlers of the idempotent
+// operations.
+func visitResponse(response any, w http.ResponseWriter) error {
+	switch resp := response.(type) {
+	case CreateEndorsementV1ResponseObject:
+		return resp.VisitCreateEndorsementV1Response(w)
+	case CreateQuoteAutoLeadV1ResponseObject:
+		return resp.VisitCreateQuoteAutoLeadV1Response(w)
+	case CreateQuoteAutoV1ResponseObject:
+		return resp.VisitCreateQuoteAutoV1Response(w)
+	default:
+		return fmt.Errorf("unexpected response type: %T", response)
+	}
+}
+
+// idempotencyRecorder captures a response so it can be stored for idempotency.
+type idempotencyRecorder struct {
+	header     http.Header
+	statusCode int
+	body       *bytes.Buffer
+}
+
+func newIdempotencyRecorder() *idempotencyRecorder {
+	return &idempotencyRecorder{
+		header: http.Header{},
+		body:   &bytes.Buffer{},
+	}
+}
+
+func (rec *idempotencyRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *idempotencyRecorder) WriteHeader(statusCode int) {
+	if rec.statusCode == 0 {
+		rec.statusCode = statusCode
+	}
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	rec.WriteHeader(http.StatusOK)
+	return rec.body.Write(b)
 }
 
 func FAPIIDMiddleware() nethttp.StrictHTTPMiddlewareFunc {
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FindAll returns all the documents of the collection.
+func FindAll[T any](ctx context.Context, collection *mongo.Collection) ([]T, error) {
+	cursor, err := collection.Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+
+	docs := []T{}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
+
+// ReplaceAll deletes all the documents of the collection and inserts docs in
+// their place.
+func ReplaceAll[T any](ctx context.Context, collection *mongo.Collection, docs []T) error {
+	if _, err := collection.DeleteMany(ctx, bson.D{}); err != nil {
+		return err
+	}
+
+	if len(docs) == 0 {
+		return nil
+	}
+
+	anyDocs := make([]any, len(docs))
+	for i, doc := range docs {
+		anyDocs[i] = doc
+	}
+	_, err := collection.InsertMany(ctx, anyDocs)
+	return err
+}
+
+// MongoKeyValue stores values of type T in a collection, one document per key.
+type MongoKeyValue[T any] struct {
+	collection *mongo.Collection
+}
+
+func NewMongoKeyValue[T any](collection *mongo.Collection) MongoKeyValue[T] {
+	return MongoKeyValue[T]{
+		collection: collection,
+	}
+}
+
+type keyValueDocument[T any] struct {
+	Key   string `bson:"_id"`
+	Value T      `bson:"value"`
+}
+
+// Get returns the value stored under the key. The boolean is false if there
+// is none.
+func (kv MongoKeyValue[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	var doc keyValueDocument[T]
+	err := kv.collection.FindOne(ctx, bson.D{{Key: "_id", Value: key}}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return doc.Value, false, nil
+	}
+	if err != nil {
+		return doc.Value, false, err
+	}
+
+	return doc.Value, true, nil
+}
+
+// Set stores the value under the key replacing the previous one.
+func (kv MongoKeyValue[T]) Set(ctx context.Context, key string, value T) error {
+	shouldUpsert := true
+	filter := bson.D{{Key: "_id", Value: key}}
+	if _, err := kv.collection.ReplaceOne(
+		ctx,
+		filter,
+		keyValueDocument[T]{Key: key, Value: value},
+		&options.ReplaceOptions{Upsert: &shouldUpsert},
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Delete removes the value stored under the key if any.
+func (kv MongoKeyValue[T]) Delete(ctx context.Context, key string) error {
+	if _, err := kv.collection.DeleteOne(ctx, bson.D{{Key: "_id", Value: key}}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// All returns all the values indexed by their keys.
+func (kv MongoKeyValue[T]) All(ctx context.Context) (map[string]T, error) {
+	docs, err := FindAll[keyValueDocument[T]](ctx, kv.collection)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]T, len(docs))
+	for _, doc := range docs {
+		values[doc.Key] = doc.Value
+	}
+	return values, nil
+}
+
+// ReplaceAll deletes all the values and stores the ones informed in their
+// place.
+func (kv MongoKeyValue[T]) ReplaceAll(ctx context.Context, values map[string]T) error {
+	docs := make([]keyValueDocument[T], 0, len(values))
+	for key, value := range values {
+		docs = append(docs, keyValueDocument[T]{Key: key, Value: value})
+	}
+	return ReplaceAll(ctx, kv.collection, docs)
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"slices"
 	"strings"
 
 	"github.com/luikyv/go-oidc/pkg/goidc"
@@ -47,6 +48,139 @@ var Scopes = []goidc.Scope{
 	ScopeQuoteAuto,
 }
 
+// scopePermissions associates the scopes giving access to data shared by the
+// user with the permissions that imply them.
+// Scopes not listed here don't depend on the permissions of a consent.
+var scopePermissions = []struct {
+	scope       goidc.Scope
+	permissions []ConsentPermission
+}{
+	{
+		scope:       ScopeResources,
+		permissions: []ConsentPermission{ConsentPermissionRESOURCESREAD},
+	},
+	{
+		scope: ScopeCustomers,
+		permissions: []ConsentPermission{
+			ConsentPermissionCUSTOMERSPERSONALIDENTIFICATIONSREAD,
+			ConsentPermissionCUSTOMERSPERSONALQUALIFICATIONREAD,
+			ConsentPermissionCUSTOMERSPERSONALADDITIONALINFOREAD,
+			ConsentPermissionCUSTOMERSBUSINESSIDENTIFICATIONSREAD,
+			ConsentPermissionCUSTOMERSBUSINESSQUALIFICATIONREAD,
+			ConsentPermissionCUSTOMERSBUSINESSADDITIONALINFOREAD,
+		},
+	},
+	{
+		scope: ScopeCapitalizationTitle,
+		permissions: []ConsentPermission{
+			ConsentPermissionCAPITALIZATIONTITLEREAD,
+			ConsentPermissionCAPITALIZATIONTITLEPLANINFOREAD,
+			ConsentPermissionCAPITALIZATIONTITLEEVENTSREAD,
+			ConsentPermissionCAPITALIZATIONTITLESETTLEMENTSREAD,
+		},
+	},
+	{
+		scope: ScopeAcceptanceAndBranchesAbroad,
+		permissions: []ConsentPermission{
+			ConsentPermissionDAMAGESANDPEOPLEACCEPTANCEANDBRANCHESABROADREAD,
+			ConsentPermissionDAMAGESANDPEOPLEACCEPTANCEANDBRANCHESABROADPOLICYINFOREAD,
+			ConsentPermissionDAMAGESANDPEOPLEACCEPTANCEANDBRANCHESABROADPREMIUMREAD,
+			ConsentPermissionDAMAGESANDPEOPLEACCEPTANCEANDBRANCHESABROADCLAIMREAD,
+		},
+	},
+	{
+		scope: ScopeInsuranceAuto,
+		permissions: []ConsentPermission{
+			ConsentPermissionDAMAGESANDPEOPLEAUTOREAD,
+			ConsentPermissionDAMAGESANDPEOPLEAUTOPOLICYINFOREAD,
+			ConsentPermissionDAMAGESANDPEOPLEAUTOPREMIUMREAD,
+			ConsentPermissionDAMAGESANDPEOPLEAUTOCLAIMREAD,
+		},
+	},
+	{
+		scope: ScopeInsuranceFinancialRisk,
+		permissions: []ConsentPermission{
+			ConsentPermissionDAMAGESANDPEOPLEFINANCIALRISKSREAD,
+			ConsentPermissionDAMAGESANDPEOPLEFINANCIALRISKSPOLICYINFOREAD,
+			ConsentPermissionDAMAGESANDPEOPLEFINANCIALRISKSPREMIUMREAD,
+			ConsentPermissionDAMAGESANDPEOPLEFINANCIALRISKSCLAIMREAD,
+		},
+	},
+	{
+		scope: ScopeInsurancePatrimonial,
+		permissions: []ConsentPermission{
+			ConsentPermissionDAMAGESANDPEOPLEPATRIMONIALREAD,
+			ConsentPermissionDAMAGESANDPEOPLEPATRIMONIALPOLICYINFOREAD,
+			ConsentPermissionDAMAGESANDPEOPLEPATRIMONIALPREMIUMREAD,
+			ConsentPermissionDAMAGESANDPEOPLEPATRIMONIALCLAIMREAD,
+		},
+	},
+	{
+		scope: ScopeInsuranceResponsibility,
+		permissions: []ConsentPermission{
+			ConsentPermissionDAMAGESANDPEOPLERESPONSIBILITYREAD,
+			ConsentPermissionDAMAGESANDPEOPLERESPONSIBILITYPOLICYINFOREAD,
+			ConsentPermissionDAMAGESANDPEOPLERESPONSIBILITYPREMIUMREAD,
+			ConsentPermissionDAMAGESANDPEOPLERESPONSIBILITYCLAIMREAD,
+		},
+	},
+	{
+		scope:       ScopeEndorsement,
+		permissions: []ConsentPermission{ConsentPermissionENDORSEMENTREQUESTCREATE},
+	},
+}
+
+// permissionScopes maps each permission listed in scopePermissions to the
+// scope it implies.
+var permissionScopes = func() map[ConsentPermission]goidc.Scope {
+	scopes := map[ConsentPermission]goidc.Scope{}
+	for _, sp := range scopePermissions {
+		for _, p := range sp.permissions {
+			if _, ok := scopes[p]; ok {
+				panic("more than one scope is associated to the permission " + string(p))
+			}
+			scopes[p] = sp.scope
+		}
+	}
+	return scopes
+}()
+
+// GrantedScopes filters the space separated requested scopes keeping only the
+// ones implied by the permissions granted.
+// Scopes that don't depend on permissions are kept as long as they are
+// supported, i.e. listed in Scopes.
+func GrantedScopes(requestedScopes string, permissions []ConsentPermission) string {
+	var scopes []string
+	for _, s := range strings.Split(requestedScopes, " ") {
+		if isScopeSupported(s) && isScopeImplied(s, permissions) {
+			scopes = append(scopes, s)
+		}
+	}
+	return strings.Join(scopes, " ")
+}
+
+func isScopeSupported(scope string) bool {
+	return slices.ContainsFunc(Scopes, func(s goidc.Scope) bool {
+		return s.Matches(scope)
+	})
+}
+
+func isScopeImplied(scope string, permissions []ConsentPermission) bool {
+	for _, sp := range scopePermissions {
+		if !sp.scope.Matches(scope) {
+			continue
+		}
+
+		for _, p := range sp.permissions {
+			if slices.Contains(permissions, p) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
 func ConsentID(scopes string) (string, bool) {
 	for _, s := range strings.Split(scopes, " ") {
 		if ScopeConsent.Matches(s) {
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	_ "modernc.org/sqlite"
+)
+
+// OpenSQLite opens the SQLite database at path, creating the file if it
+// doesn't exist.
+// The records are kept as JSON documents in a data column, with the fields
+// used in queries copied to their own columns.
+func OpenSQLite(path string) (*sql.DB, error) {
+	db, err := sql.Open(
+		"sqlite",
+		"file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)",
+	)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer at a time, so sharing one connection avoids
+	// "database is locked" errors under concurrent requests.
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// SQLiteExec runs each of the statements in order. It is meant for creating
+// tables and indexes.
+func SQLiteExec(ctx context.Context, db *sql.DB, stmts ...string) error {
+	for _, stmt := range stmts {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SQLiteFindOne decodes the JSON document of the first row returned by the
+// query. It returns sql.ErrNoRows if there is none.
+func SQLiteFindOne[T any](
+	ctx context.Context,
+	db *sql.DB,
+	query string,
+	args ...any,
+) (
+	T,
+	error,
+) {
+	var doc T
+	var data []byte
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&data); err != nil {
+		return doc, err
+	}
+
+	err := json.Unmarshal(data, &doc)
+	return doc, err
+}
+
+// SQLiteFindAll decodes the JSON documents of all the rows returned by the
+// query.
+func SQLiteFindAll[T any](
+	ctx context.Context,
+	db *sql.DB,
+	query string,
+	args ...any,
+) (
+	[]T,
+	error,
+) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := []T{}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		var doc T
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+
+	return docs, rows.Err()
+}
+
+// SQLiteReplaceAll deletes all the rows of the table and calls insert for
+// each of docs inside a single transaction.
+func SQLiteReplaceAll[T any](
+	ctx context.Context,
+	db *sql.DB,
+	table string,
+	docs []T,
+	insert func(tx *sql.Tx, doc T) error,
+) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM "+table); err != nil {
+		return err
+	}
+
+	for _, doc := range docs {
+		if err := insert(tx, doc); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SQLiteExecer is implemented by both *sql.DB and *sql.Tx, so the same
+// upsert can be run standalone or as part of a transaction.
+type SQLiteExecer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
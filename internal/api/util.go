@@ -8,10 +8,41 @@ type operationOptions struct {
 	scopes           []goidc.Scope
 	permissions      []ConsentPermission
 	fapiIDIsRequired bool
-	isIdempotent     bool
+	// isIdempotent operations must also have their responses handled by
+	// visitResponse.
+	isIdempotent bool
+}
+
+// consentedOperations maps the operations that read data shared by the user to
+// the permission they require.
+var consentedOperations = map[string]ConsentPermission{
+	"ResourcesV2":                      ConsentPermissionRESOURCESREAD,
+	"PersonalIdentificationsV1":        ConsentPermissionCUSTOMERSPERSONALIDENTIFICATIONSREAD,
+	"PersonalQualificationsV1":         ConsentPermissionCUSTOMERSPERSONALQUALIFICATIONREAD,
+	"PersonalComplimentaryInfoV1":      ConsentPermissionCUSTOMERSPERSONALADDITIONALINFOREAD,
+	"CapitalizationTitlePlansV1":       ConsentPermissionCAPITALIZATIONTITLEREAD,
+	"CapitalizationTitlePlanInfoV1":    ConsentPermissionCAPITALIZATIONTITLEPLANINFOREAD,
+	"CapitalizationTitleEventsV1":      ConsentPermissionCAPITALIZATIONTITLEEVENTSREAD,
+	"CapitalizationTitleSettlementsV1": ConsentPermissionCAPITALIZATIONTITLESETTLEMENTSREAD,
+}
+
+// init makes sure every consented operation requires a scope, so a permission
+// missing from scopePermissions is caught at startup instead of at request
+// time.
+func init() {
+	for operationID, permission := range consentedOperations {
+		if _, ok := permissionScopes[permission]; !ok {
+			panic("no scope is associated to the permission " + string(permission) +
+				" required by the operation " + operationID)
+		}
+	}
 }
 
 func newOperationOptions(operationID string) operationOptions {
+	if permission, ok := consentedOperations[operationID]; ok {
+		return newConsentedOperationOptions(permission)
+	}
+
 	switch operationID {
 	case "CreateConsentV2":
 		return operationOptions{
@@ -21,99 +52,6 @@ func newOperationOptions(operationID string) operationOptions {
 		return operationOptions{
 			scopes: []goidc.Scope{ScopeConsents},
 		}
-	case "ResourcesV2":
-		return operationOptions{
-			scopes: []goidc.Scope{
-				ScopeOpenID,
-				ScopeConsent,
-				ScopeResources,
-			},
-			permissions: []ConsentPermission{ConsentPermissionRESOURCESREAD},
-		}
-	case "PersonalIdentificationsV1":
-		return operationOptions{
-			scopes: []goidc.Scope{
-				ScopeOpenID,
-				ScopeConsent,
-				ScopeCustomers,
-			},
-			permissions: []ConsentPermission{
-				ConsentPermissionRESOURCESREAD,
-				ConsentPermissionCUSTOMERSPERSONALIDENTIFICATIONSREAD,
-			},
-		}
-	case "PersonalQualificationsV1":
-		return operationOptions{
-			scopes: []goidc.Scope{
-				ScopeOpenID,
-				ScopeConsent,
-				ScopeCustomers,
-			},
-			permissions: []ConsentPermission{
-				ConsentPermissionRESOURCESREAD,
-				ConsentPermissionCUSTOMERSPERSONALQUALIFICATIONREAD,
-			},
-		}
-	case "PersonalComplimentaryInfoV1":
-		return operationOptions{
-			scopes: []goidc.Scope{
-				ScopeOpenID,
-				ScopeConsent,
-				ScopeCustomers,
-			},
-			permissions: []ConsentPermission{
-				ConsentPermissionRESOURCESREAD,
-				ConsentPermissionCUSTOMERSPERSONALADDITIONALINFOREAD,
-			},
-		}
-	case "CapitalizationTitlePlansV1":
-		return operationOptions{
-			scopes: []goidc.Scope{
-				ScopeOpenID,
-				ScopeConsent,
-				ScopeCapitalizationTitle,
-			},
-			permissions: []ConsentPermission{
-				ConsentPermissionRESOURCESREAD,
-				ConsentPermissionCAPITALIZATIONTITLEREAD,
-			},
-		}
-	case "CapitalizationTitlePlanInfoV1":
-		return operationOptions{
-			scopes: []goidc.Scope{
-				ScopeOpenID,
-				ScopeConsent,
-				ScopeCapitalizationTitle,
-			},
-			permissions: []ConsentPermission{
-				ConsentPermissionRESOURCESREAD,
-				ConsentPermissionCAPITALIZATIONTITLEPLANINFOREAD,
-			},
-		}
-	case "CapitalizationTitleEventsV1":
-		return operationOptions{
-			scopes: []goidc.Scope{
-				ScopeOpenID,
-				ScopeConsent,
-				ScopeCapitalizationTitle,
-			},
-			permissions: []ConsentPermission{
-				ConsentPermissionRESOURCESREAD,
-				ConsentPermissionCAPITALIZATIONTITLEEVENTSREAD,
-			},
-		}
-	case "CapitalizationTitleSettlementsV1":
-		return operationOptions{
-			scopes: []goidc.Scope{
-				ScopeOpenID,
-				ScopeConsent,
-				ScopeCapitalizationTitle,
-			},
-			permissions: []ConsentPermission{
-				ConsentPermissionRESOURCESREAD,
-				ConsentPermissionCAPITALIZATIONTITLESETTLEMENTSREAD,
-			},
-		}
 	case "CreateEndorsementV1":
 		return operationOptions{
 			scopes: []goidc.Scope{
@@ -158,6 +96,25 @@ func newOperationOptions(operationID string) operationOptions {
 	}
 }
 
+// newConsentedOperationOptions builds the options for an operation that reads
+// data shared by the user.
+// The scope required is the one associated to the permission in
+// scopePermissions, so tokens are only granted it when the permission is.
+func newConsentedOperationOptions(permission ConsentPermission) operationOptions {
+	permissions := []ConsentPermission{ConsentPermissionRESOURCESREAD}
+	if permission != ConsentPermissionRESOURCESREAD {
+		permissions = append(permissions, permission)
+	}
+	return operationOptions{
+		scopes: []goidc.Scope{
+			ScopeOpenID,
+			ScopeConsent,
+			permissionScopes[permission],
+		},
+		permissions: permissions,
+	}
+}
+
 // areScopesValid verifies every scope in requiredScopes has a match among
 // scopes.
 // scopes can have more scopes than the defined at requiredScopes, but the
@@ -28,7 +28,11 @@ func (s ServerV1) CapitalizationTitlePlansV1(
 	meta := api.NewRequestMeta(ctx)
 	pagination := api.NewPagination(request.Params.Page, request.Params.PageSize)
 
-	resp := s.service.plans(meta, pagination)
+	resp, err := s.service.plans(ctx, meta, pagination)
+	if err != nil {
+		return nil, err
+	}
+
 	return api.CapitalizationTitlePlansV1200JSONResponse(resp), nil
 }
 
@@ -42,7 +46,7 @@ func (s ServerV1) CapitalizationTitleEventsV1(
 	meta := api.NewRequestMeta(ctx)
 	pagination := api.NewPagination(request.Params.Page, request.Params.PageSize)
 
-	resp, err := s.service.planEvents(meta, request.PlanId, pagination)
+	resp, err := s.service.planEvents(ctx, meta, request.PlanId, pagination)
 	if err != nil {
 		return nil, err
 	}
@@ -58,7 +62,7 @@ func (s ServerV1) CapitalizationTitlePlanInfoV1(
 	error,
 ) {
 	meta := api.NewRequestMeta(ctx)
-	resp, err := s.service.planInfo(meta, request.PlanId)
+	resp, err := s.service.planInfo(ctx, meta, request.PlanId)
 	if err != nil {
 		return nil, err
 	}
@@ -76,7 +80,7 @@ func (s ServerV1) CapitalizationTitleSettlementsV1(
 	meta := api.NewRequestMeta(ctx)
 	pagination := api.NewPagination(request.Params.Page, request.Params.PageSize)
 
-	resp, err := s.service.planSettlements(meta, request.PlanId, pagination)
+	resp, err := s.service.planSettlements(ctx, meta, request.PlanId, pagination)
 	if err != nil {
 		return nil, err
 	}
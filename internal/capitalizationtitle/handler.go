@@ -0,0 +1,115 @@
+package capitalizationtitle
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+)
+
+// RegisterAdminHandlers adds the endpoints to manage the capitalization title
+// plans of the users under prefix.
+func RegisterAdminHandlers(
+	mux *http.ServeMux,
+	prefix string,
+	service Service,
+	validate api.SchemaValidator,
+) {
+	path := prefix + "/users/{username}/capitalization-title/plans"
+	api.RegisterListHandlers(
+		mux,
+		path,
+		"CapitalizationTitlePlanData",
+		validate,
+		func(r *http.Request) string { return r.PathValue("username") },
+		service.Plans,
+		service.SetPlans,
+	)
+
+	mux.HandleFunc("GET "+path+"/{planId}/info", planInfoHandler(service))
+	mux.HandleFunc("PUT "+path+"/{planId}/info", updatePlanInfoHandler(service, validate))
+	mux.HandleFunc("DELETE "+path+"/{planId}/info", deletePlanInfoHandler(service))
+
+	api.RegisterListHandlers(
+		mux,
+		path+"/{planId}/events",
+		"CapitalizationTitleEvent",
+		validate,
+		newPlanKey,
+		func(ctx context.Context, k planKey) ([]api.CapitalizationTitleEvent, error) {
+			return service.PlanEvents(ctx, k.sub, k.planID)
+		},
+		func(ctx context.Context, k planKey, events []api.CapitalizationTitleEvent) error {
+			return service.SetPlanEvents(ctx, k.sub, k.planID, events)
+		},
+	)
+	api.RegisterListHandlers(
+		mux,
+		path+"/{planId}/settlements",
+		"CapitalizationTitleSettlement",
+		validate,
+		newPlanKey,
+		func(ctx context.Context, k planKey) ([]api.CapitalizationTitleSettlement, error) {
+			return service.PlanSettlements(ctx, k.sub, k.planID)
+		},
+		func(ctx context.Context, k planKey, settlements []api.CapitalizationTitleSettlement) error {
+			return service.SetPlanSettlements(ctx, k.sub, k.planID, settlements)
+		},
+	)
+}
+
+// planKey identifies a plan of a user.
+type planKey struct {
+	sub    string
+	planID string
+}
+
+func newPlanKey(r *http.Request) planKey {
+	return planKey{
+		sub:    r.PathValue("username"),
+		planID: r.PathValue("planId"),
+	}
+}
+
+func planInfoHandler(service Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, err := service.PlanInfo(r.Context(), r.PathValue("username"), r.PathValue("planId"))
+		if err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		api.WriteJSON(w, http.StatusOK, info)
+	}
+}
+
+func updatePlanInfoHandler(service Service, validate api.SchemaValidator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var info api.CapitalizationTitlePlanInfo
+		if err := api.DecodeJSON(r, &info); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		if err := validate("CapitalizationTitlePlanInfo", info); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		if err := service.SetPlanInfo(r.Context(), r.PathValue("username"), r.PathValue("planId"), info); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+		api.WriteJSON(w, http.StatusOK, info)
+	}
+}
+
+func deletePlanInfoHandler(service Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := service.DeletePlanInfo(r.Context(), r.PathValue("username"), r.PathValue("planId")); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
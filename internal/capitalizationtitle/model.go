@@ -59,3 +59,12 @@ func newPlanSettlementsResponse(
 		},
 	}
 }
+
+// Snapshot is the capitalization title data of all users. The plans are
+// indexed by username and the rest by username and plan ID.
+type Snapshot struct {
+	Plans       map[string][]api.CapitalizationTitlePlanData   `json:"plans"`
+	PlanInfo    map[string]api.CapitalizationTitlePlanInfo     `json:"plan_info"`
+	Events      map[string][]api.CapitalizationTitleEvent      `json:"events"`
+	Settlements map[string][]api.CapitalizationTitleSettlement `json:"settlements"`
+}
@@ -1,19 +1,23 @@
 package capitalizationtitle
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 
 	"github.com/luikyv/go-open-insurance/internal/api"
 	"github.com/luikyv/go-open-insurance/internal/resource"
 )
 
 type Service struct {
-	storage         *Storage
+	storage         Storage
 	resourceService resource.Service
 }
 
 func NewService(
-	storage *Storage,
+	storage Storage,
 	resourceService resource.Service,
 ) Service {
 	return Service{
@@ -22,62 +26,193 @@ func NewService(
 	}
 }
 
-func (s Service) AddPlan(
+// Plans returns all the plans of the user regardless of consents.
+func (s Service) Plans(ctx context.Context, sub string) ([]api.CapitalizationTitlePlanData, error) {
+	return s.storage.plans(ctx, sub)
+}
+
+// SetPlans replaces the plans of the user.
+// The resources of the products no longer offered are removed and the ones of
+// new products are created, the remaining ones are kept untouched.
+func (s Service) SetPlans(
+	ctx context.Context,
 	sub string,
-	plan api.CapitalizationTitlePlanData,
-) {
-	s.storage.addPlan(sub, plan)
-	for _, company := range plan.Brand.Companies {
-		for _, product := range company.Products {
-			s.resourceService.Add(sub, api.ResourceData{
-				ResourceId: product.PlanId,
-				Status:     api.ResourceStatusAVAILABLE,
-				Type:       api.ResourceTypeCAPITALIZATIONTITLES,
-			})
+	plans []api.CapitalizationTitlePlanData,
+) error {
+	var newPlanIDs []string
+	for _, plan := range plans {
+		newPlanIDs = append(newPlanIDs, planIDs(plan)...)
+	}
+
+	oldPlans, err := s.storage.plans(ctx, sub)
+	if err != nil {
+		return err
+	}
+	for _, plan := range oldPlans {
+		for _, planID := range planIDs(plan) {
+			if !slices.Contains(newPlanIDs, planID) {
+				_ = s.resourceService.Delete(ctx, sub, planID)
+			}
 		}
 	}
+
+	if err := s.storage.setPlans(ctx, sub, plans); err != nil {
+		return err
+	}
+
+	resources, err := s.resourceService.All(ctx, sub)
+	if err != nil {
+		return err
+	}
+	for _, planID := range newPlanIDs {
+		if slices.ContainsFunc(resources, func(r api.ResourceData) bool {
+			return r.ResourceId == planID
+		}) {
+			continue
+		}
+		if err := s.resourceService.Save(ctx, sub, api.ResourceData{
+			ResourceId: planID,
+			Status:     api.ResourceStatusAVAILABLE,
+			Type:       api.ResourceTypeCAPITALIZATIONTITLES,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Snapshot returns the capitalization title data of all users.
+func (s Service) Snapshot(ctx context.Context) (Snapshot, error) {
+	return s.storage.snapshot(ctx)
+}
+
+// Restore replaces the capitalization title data of all users by the one in
+// the snapshot. The resources of the plans are expected to be restored
+// separately.
+func (s Service) Restore(ctx context.Context, snapshot Snapshot) error {
+	return s.storage.restore(ctx, snapshot)
 }
 
 func (s Service) plans(
+	ctx context.Context,
 	meta api.RequestMeta,
 	page api.Pagination,
-) api.GetCapitalizationTitlePlansResponse {
-	plans := s.storage.plans(meta.Subject, page)
-	return newPlansResponse(meta, plans)
+) (
+	api.GetCapitalizationTitlePlansResponse,
+	error,
+) {
+	consent, err := s.resourceService.Consent(ctx, meta)
+	if err != nil {
+		return api.GetCapitalizationTitlePlansResponse{}, err
+	}
+
+	userPlans, err := s.storage.plans(ctx, meta.Subject)
+	if err != nil {
+		return api.GetCapitalizationTitlePlansResponse{}, err
+	}
+
+	// Keep only the products the user shared when authorizing the consent.
+	var plans []api.CapitalizationTitlePlanData
+	for _, plan := range userPlans {
+		var companies []api.CapitalizationTitleCompany
+		for _, company := range plan.Brand.Companies {
+			var products []api.CapitalizationTitleProduct
+			for _, product := range company.Products {
+				if consent.HasResource(product.PlanId) {
+					products = append(products, product)
+				}
+			}
+			if len(products) != 0 {
+				company.Products = products
+				companies = append(companies, company)
+			}
+		}
+		if len(companies) != 0 {
+			plan.Brand.Companies = companies
+			plans = append(plans, plan)
+		}
+	}
+	return newPlansResponse(meta, api.Paginate(plans, page)), nil
 }
 
-func (s Service) AddPlanInfo(
+// SetPlanInfo replaces the information of the plan.
+func (s Service) SetPlanInfo(
+	ctx context.Context,
 	sub string,
 	planID string,
 	info api.CapitalizationTitlePlanInfo,
+) error {
+	return s.storage.setPlanInfo(ctx, sub, planID, info)
+}
+
+func (s Service) PlanInfo(
+	ctx context.Context,
+	sub string,
+	planID string,
+) (
+	api.CapitalizationTitlePlanInfo,
+	error,
 ) {
-	s.storage.addPlanInfo(sub, planID, info)
+	info, err := s.storage.planInfo(ctx, sub, planID)
+	if err != nil {
+		return api.CapitalizationTitlePlanInfo{}, planError(planID, err)
+	}
+	return info, nil
+}
+
+func (s Service) DeletePlanInfo(ctx context.Context, sub string, planID string) error {
+	return s.storage.deletePlanInfo(ctx, sub, planID)
 }
 
 func (s Service) planInfo(
+	ctx context.Context,
 	meta api.RequestMeta,
 	planID string,
 ) (
 	api.GetCapitalizationTitlePlanInfoResponse,
 	error,
 ) {
-	info, err := s.storage.planInfo(meta.Subject, planID)
+	if err := s.resourceService.Verify(ctx, meta, planID); err != nil {
+		return api.GetCapitalizationTitlePlanInfoResponse{}, err
+	}
+
+	info, err := s.storage.planInfo(ctx, meta.Subject, planID)
 	if err != nil {
-		return api.GetCapitalizationTitlePlanInfoResponse{},
-			api.NewError("NOT_FOUND", http.StatusNotFound, err.Error())
+		return api.GetCapitalizationTitlePlanInfoResponse{}, planError(planID, err)
 	}
 	return newPlanInfoResponse(meta, info), nil
 }
 
-func (s Service) AddPlanEvent(
+// PlanEvents returns the events of the plan, nil if there are none.
+func (s Service) PlanEvents(
+	ctx context.Context,
 	sub string,
 	planID string,
-	event api.CapitalizationTitleEvent,
+) (
+	[]api.CapitalizationTitleEvent,
+	error,
 ) {
-	s.storage.addPlanEvent(sub, planID, event)
+	events, err := s.storage.planEvents(ctx, sub, planID)
+	if errors.Is(err, errPlanNotFound) {
+		return nil, nil
+	}
+	return events, err
+}
+
+// SetPlanEvents replaces the events of the plan. If events is nil, the plan
+// is left with no events.
+func (s Service) SetPlanEvents(
+	ctx context.Context,
+	sub string,
+	planID string,
+	events []api.CapitalizationTitleEvent,
+) error {
+	return s.storage.setPlanEvents(ctx, sub, planID, events)
 }
 
 func (s Service) planEvents(
+	ctx context.Context,
 	meta api.RequestMeta,
 	planID string,
 	page api.Pagination,
@@ -85,23 +220,46 @@ func (s Service) planEvents(
 	api.GetCapitalizationTitleEventsResponse,
 	error,
 ) {
-	events, err := s.storage.planEvents(meta.Subject, planID, page)
+	if err := s.resourceService.Verify(ctx, meta, planID); err != nil {
+		return api.GetCapitalizationTitleEventsResponse{}, err
+	}
+
+	events, err := s.storage.planEvents(ctx, meta.Subject, planID)
 	if err != nil {
-		return api.GetCapitalizationTitleEventsResponse{},
-			api.NewError("NOT_FOUND", http.StatusNotFound, err.Error())
+		return api.GetCapitalizationTitleEventsResponse{}, planError(planID, err)
 	}
-	return newPlanEventsResponse(meta, events), nil
+	return newPlanEventsResponse(meta, api.Paginate(events, page)), nil
 }
 
-func (s Service) AddPlanSettlement(
+// PlanSettlements returns the settlements of the plan, nil if there are none.
+func (s Service) PlanSettlements(
+	ctx context.Context,
 	sub string,
 	planID string,
-	settlement api.CapitalizationTitleSettlement,
+) (
+	[]api.CapitalizationTitleSettlement,
+	error,
 ) {
-	s.storage.addPlanSettlement(sub, planID, settlement)
+	settlements, err := s.storage.planSettlements(ctx, sub, planID)
+	if errors.Is(err, errPlanNotFound) {
+		return nil, nil
+	}
+	return settlements, err
+}
+
+// SetPlanSettlements replaces the settlements of the plan. If settlements is
+// nil, the plan is left with no settlements.
+func (s Service) SetPlanSettlements(
+	ctx context.Context,
+	sub string,
+	planID string,
+	settlements []api.CapitalizationTitleSettlement,
+) error {
+	return s.storage.setPlanSettlements(ctx, sub, planID, settlements)
 }
 
 func (s Service) planSettlements(
+	ctx context.Context,
 	meta api.RequestMeta,
 	planID string,
 	page api.Pagination,
@@ -109,11 +267,34 @@ func (s Service) planSettlements(
 	api.GetCapitalizationTitleSettlementsResponse,
 	error,
 ) {
-	settlements, err := s.storage.planSettlements(meta.Subject, planID, page)
+	if err := s.resourceService.Verify(ctx, meta, planID); err != nil {
+		return api.GetCapitalizationTitleSettlementsResponse{}, err
+	}
+
+	settlements, err := s.storage.planSettlements(ctx, meta.Subject, planID)
 	if err != nil {
-		return api.GetCapitalizationTitleSettlementsResponse{},
-			api.NewError("NOT_FOUND", http.StatusNotFound, err.Error())
+		return api.GetCapitalizationTitleSettlementsResponse{}, planError(planID, err)
 	}
-	resp := newPlanSettlementsResponse(meta, settlements)
+	resp := newPlanSettlementsResponse(meta, api.Paginate(settlements, page))
 	return resp, nil
 }
+
+// planError converts errPlanNotFound into a not found error for the API.
+func planError(planID string, err error) error {
+	if errors.Is(err, errPlanNotFound) {
+		return api.NewError("NOT_FOUND", http.StatusNotFound,
+			fmt.Sprintf("plan %s not found", planID))
+	}
+	return err
+}
+
+// planIDs returns the IDs of all the products offered in the plan.
+func planIDs(plan api.CapitalizationTitlePlanData) []string {
+	var ids []string
+	for _, company := range plan.Brand.Companies {
+		for _, product := range company.Products {
+			ids = append(ids, product.PlanId)
+		}
+	}
+	return ids
+}
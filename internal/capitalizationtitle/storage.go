@@ -1,112 +1,34 @@
 package capitalizationtitle
 
 import (
-	"fmt"
+	"context"
+	"errors"
 
 	"github.com/luikyv/go-open-insurance/internal/api"
 )
 
-type Storage struct {
-	plansMap           map[string][]api.CapitalizationTitlePlanData
-	planInfoMap        map[string]api.CapitalizationTitlePlanInfo
-	planEventsMap      map[string][]api.CapitalizationTitleEvent
-	planSettlementsMap map[string][]api.CapitalizationTitleSettlement
-}
-
-func NewStorage() *Storage {
-	return &Storage{
-		plansMap:           make(map[string][]api.CapitalizationTitlePlanData),
-		planInfoMap:        make(map[string]api.CapitalizationTitlePlanInfo),
-		planEventsMap:      make(map[string][]api.CapitalizationTitleEvent),
-		planSettlementsMap: make(map[string][]api.CapitalizationTitleSettlement),
-	}
-}
-
-func (s *Storage) addPlan(
-	sub string,
-	title api.CapitalizationTitlePlanData,
-) {
-	s.plansMap[sub] = append(s.plansMap[sub], title)
-}
-
-func (s *Storage) plans(
-	sub string,
-	page api.Pagination,
-) api.Page[api.CapitalizationTitlePlanData] {
-	return api.Paginate(s.plansMap[sub], page)
-}
-
-func (s *Storage) addPlanInfo(
-	sub string,
-	planID string,
-	info api.CapitalizationTitlePlanInfo,
-) {
-	s.planInfoMap[sub+"_"+planID] = info
-}
-
-func (s *Storage) planInfo(
-	sub string,
-	planID string,
-) (
-	api.CapitalizationTitlePlanInfo,
-	error,
-) {
-	info, ok := s.planInfoMap[sub+"_"+planID]
-	if !ok {
-		return api.CapitalizationTitlePlanInfo{}, fmt.Errorf("plan %s not found", planID)
-	}
-
-	return info, nil
-}
-
-func (s *Storage) addPlanEvent(
-	sub string,
-	planID string,
-	event api.CapitalizationTitleEvent,
-) {
-	s.planEventsMap[sub+"_"+planID] = append(s.planEventsMap[sub+"_"+planID], event)
-}
-
-func (s *Storage) planEvents(
-	sub string,
-	planID string,
-	page api.Pagination,
-) (
-	api.Page[api.CapitalizationTitleEvent],
-	error,
-) {
-	events, ok := s.planEventsMap[sub+"_"+planID]
-	if !ok {
-		return api.Page[api.CapitalizationTitleEvent]{}, fmt.Errorf("plan %s not found", planID)
-	}
-
-	return api.Paginate(events, page), nil
-}
-
-func (s *Storage) addPlanSettlement(
-	sub string,
-	planID string,
-	settlement api.CapitalizationTitleSettlement,
-) {
-	s.planSettlementsMap[sub+"_"+planID] = append(
-		s.planSettlementsMap[sub+"_"+planID],
-		settlement,
-	)
-}
-
-func (s *Storage) planSettlements(
-	sub string,
-	planID string,
-	page api.Pagination,
-) (
-	api.Page[api.CapitalizationTitleSettlement],
-	error,
-) {
-	settlements, ok := s.planSettlementsMap[sub+"_"+planID]
-	if !ok {
-		return api.Page[api.CapitalizationTitleSettlement]{},
-			fmt.Errorf("plan %s not found", planID)
-	}
-
-	return api.Paginate(settlements, page), nil
+var errPlanNotFound = errors.New("plan not found")
+
+// Storage persists the capitalization title plans of the users and their
+// information, events and settlements.
+// Setting a list to nil removes it. The implementations return
+// errPlanNotFound when the information, events or settlements of a plan were
+// never set.
+type Storage interface {
+	plans(ctx context.Context, sub string) ([]api.CapitalizationTitlePlanData, error)
+	setPlans(ctx context.Context, sub string, plans []api.CapitalizationTitlePlanData) error
+	planInfo(ctx context.Context, sub string, planID string) (api.CapitalizationTitlePlanInfo, error)
+	setPlanInfo(ctx context.Context, sub string, planID string, info api.CapitalizationTitlePlanInfo) error
+	deletePlanInfo(ctx context.Context, sub string, planID string) error
+	planEvents(ctx context.Context, sub string, planID string) ([]api.CapitalizationTitleEvent, error)
+	setPlanEvents(ctx context.Context, sub string, planID string, events []api.CapitalizationTitleEvent) error
+	planSettlements(ctx context.Context, sub string, planID string) ([]api.CapitalizationTitleSettlement, error)
+	setPlanSettlements(ctx context.Context, sub string, planID string, settlements []api.CapitalizationTitleSettlement) error
+	snapshot(ctx context.Context) (Snapshot, error)
+	restore(ctx context.Context, snapshot Snapshot) error
+}
+
+// storageKey identifies the data of a plan of a user.
+func storageKey(sub string, planID string) string {
+	return sub + "_" + planID
 }
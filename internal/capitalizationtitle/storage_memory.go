@@ -0,0 +1,182 @@
+package capitalizationtitle
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+)
+
+// MemoryStorage keeps the capitalization title data in memory, so it is lost
+// when the server stops.
+type MemoryStorage struct {
+	mu                 sync.RWMutex
+	plansMap           map[string][]api.CapitalizationTitlePlanData
+	planInfoMap        map[string]api.CapitalizationTitlePlanInfo
+	planEventsMap      map[string][]api.CapitalizationTitleEvent
+	planSettlementsMap map[string][]api.CapitalizationTitleSettlement
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		plansMap:           make(map[string][]api.CapitalizationTitlePlanData),
+		planInfoMap:        make(map[string]api.CapitalizationTitlePlanInfo),
+		planEventsMap:      make(map[string][]api.CapitalizationTitleEvent),
+		planSettlementsMap: make(map[string][]api.CapitalizationTitleSettlement),
+	}
+}
+
+func (s *MemoryStorage) plans(
+	_ context.Context,
+	sub string,
+) (
+	[]api.CapitalizationTitlePlanData,
+	error,
+) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clip(s.plansMap[sub]), nil
+}
+
+func (s *MemoryStorage) setPlans(
+	_ context.Context,
+	sub string,
+	plans []api.CapitalizationTitlePlanData,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	setOrDelete(s.plansMap, sub, plans)
+	return nil
+}
+
+func (s *MemoryStorage) planInfo(
+	_ context.Context,
+	sub string,
+	planID string,
+) (
+	api.CapitalizationTitlePlanInfo,
+	error,
+) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	info, ok := s.planInfoMap[storageKey(sub, planID)]
+	if !ok {
+		return api.CapitalizationTitlePlanInfo{}, errPlanNotFound
+	}
+
+	return info, nil
+}
+
+func (s *MemoryStorage) setPlanInfo(
+	_ context.Context,
+	sub string,
+	planID string,
+	info api.CapitalizationTitlePlanInfo,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.planInfoMap[storageKey(sub, planID)] = info
+	return nil
+}
+
+func (s *MemoryStorage) deletePlanInfo(_ context.Context, sub string, planID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.planInfoMap, storageKey(sub, planID))
+	return nil
+}
+
+func (s *MemoryStorage) planEvents(
+	_ context.Context,
+	sub string,
+	planID string,
+) (
+	[]api.CapitalizationTitleEvent,
+	error,
+) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	events, ok := s.planEventsMap[storageKey(sub, planID)]
+	if !ok {
+		return nil, errPlanNotFound
+	}
+
+	return slices.Clip(events), nil
+}
+
+func (s *MemoryStorage) setPlanEvents(
+	_ context.Context,
+	sub string,
+	planID string,
+	events []api.CapitalizationTitleEvent,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	setOrDelete(s.planEventsMap, storageKey(sub, planID), events)
+	return nil
+}
+
+func (s *MemoryStorage) planSettlements(
+	_ context.Context,
+	sub string,
+	planID string,
+) (
+	[]api.CapitalizationTitleSettlement,
+	error,
+) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	settlements, ok := s.planSettlementsMap[storageKey(sub, planID)]
+	if !ok {
+		return nil, errPlanNotFound
+	}
+
+	return slices.Clip(settlements), nil
+}
+
+func (s *MemoryStorage) setPlanSettlements(
+	_ context.Context,
+	sub string,
+	planID string,
+	settlements []api.CapitalizationTitleSettlement,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	setOrDelete(s.planSettlementsMap, storageKey(sub, planID), settlements)
+	return nil
+}
+
+func (s *MemoryStorage) snapshot(_ context.Context) (Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return Snapshot{
+		Plans:       maps.Clone(s.plansMap),
+		PlanInfo:    maps.Clone(s.planInfoMap),
+		Events:      maps.Clone(s.planEventsMap),
+		Settlements: maps.Clone(s.planSettlementsMap),
+	}, nil
+}
+
+func (s *MemoryStorage) restore(_ context.Context, snapshot Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.plansMap = make(map[string][]api.CapitalizationTitlePlanData)
+	s.planInfoMap = make(map[string]api.CapitalizationTitlePlanInfo)
+	s.planEventsMap = make(map[string][]api.CapitalizationTitleEvent)
+	s.planSettlementsMap = make(map[string][]api.CapitalizationTitleSettlement)
+	maps.Copy(s.plansMap, snapshot.Plans)
+	maps.Copy(s.planInfoMap, snapshot.PlanInfo)
+	maps.Copy(s.planEventsMap, snapshot.Events)
+	maps.Copy(s.planSettlementsMap, snapshot.Settlements)
+	return nil
+}
+
+func setOrDelete[T any](m map[string][]T, key string, records []T) {
+	if records == nil {
+		delete(m, key)
+		return
+	}
+	m[key] = slices.Clip(records)
+}
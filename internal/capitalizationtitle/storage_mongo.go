@@ -0,0 +1,185 @@
+package capitalizationtitle
+
+import (
+	"context"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MongoStorage keeps the capitalization title data in mongo collections. The
+// plans are stored per user and the information, events and settlements per
+// plan.
+type MongoStorage struct {
+	plansKV           api.MongoKeyValue[[]api.CapitalizationTitlePlanData]
+	planInfoKV        api.MongoKeyValue[api.CapitalizationTitlePlanInfo]
+	planEventsKV      api.MongoKeyValue[[]api.CapitalizationTitleEvent]
+	planSettlementsKV api.MongoKeyValue[[]api.CapitalizationTitleSettlement]
+}
+
+func NewMongoStorage(db *mongo.Database) MongoStorage {
+	return MongoStorage{
+		plansKV: api.NewMongoKeyValue[[]api.CapitalizationTitlePlanData](
+			db.Collection("capitalization_title_plans")),
+		planInfoKV: api.NewMongoKeyValue[api.CapitalizationTitlePlanInfo](
+			db.Collection("capitalization_title_plan_info")),
+		planEventsKV: api.NewMongoKeyValue[[]api.CapitalizationTitleEvent](
+			db.Collection("capitalization_title_plan_events")),
+		planSettlementsKV: api.NewMongoKeyValue[[]api.CapitalizationTitleSettlement](
+			db.Collection("capitalization_title_plan_settlements")),
+	}
+}
+
+func (s MongoStorage) plans(
+	ctx context.Context,
+	sub string,
+) (
+	[]api.CapitalizationTitlePlanData,
+	error,
+) {
+	plans, _, err := s.plansKV.Get(ctx, sub)
+	return plans, err
+}
+
+func (s MongoStorage) setPlans(
+	ctx context.Context,
+	sub string,
+	plans []api.CapitalizationTitlePlanData,
+) error {
+	return setOrDeleteKV(ctx, s.plansKV, sub, plans)
+}
+
+func (s MongoStorage) planInfo(
+	ctx context.Context,
+	sub string,
+	planID string,
+) (
+	api.CapitalizationTitlePlanInfo,
+	error,
+) {
+	return getKV(ctx, s.planInfoKV, storageKey(sub, planID))
+}
+
+func (s MongoStorage) setPlanInfo(
+	ctx context.Context,
+	sub string,
+	planID string,
+	info api.CapitalizationTitlePlanInfo,
+) error {
+	return s.planInfoKV.Set(ctx, storageKey(sub, planID), info)
+}
+
+func (s MongoStorage) deletePlanInfo(ctx context.Context, sub string, planID string) error {
+	return s.planInfoKV.Delete(ctx, storageKey(sub, planID))
+}
+
+func (s MongoStorage) planEvents(
+	ctx context.Context,
+	sub string,
+	planID string,
+) (
+	[]api.CapitalizationTitleEvent,
+	error,
+) {
+	return getKV(ctx, s.planEventsKV, storageKey(sub, planID))
+}
+
+func (s MongoStorage) setPlanEvents(
+	ctx context.Context,
+	sub string,
+	planID string,
+	events []api.CapitalizationTitleEvent,
+) error {
+	return setOrDeleteKV(ctx, s.planEventsKV, storageKey(sub, planID), events)
+}
+
+func (s MongoStorage) planSettlements(
+	ctx context.Context,
+	sub string,
+	planID string,
+) (
+	[]api.CapitalizationTitleSettlement,
+	error,
+) {
+	return getKV(ctx, s.planSettlementsKV, storageKey(sub, planID))
+}
+
+func (s MongoStorage) setPlanSettlements(
+	ctx context.Context,
+	sub string,
+	planID string,
+	settlements []api.CapitalizationTitleSettlement,
+) error {
+	return setOrDeleteKV(ctx, s.planSettlementsKV, storageKey(sub, planID), settlements)
+}
+
+func (s MongoStorage) snapshot(ctx context.Context) (Snapshot, error) {
+	plans, err := s.plansKV.All(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	info, err := s.planInfoKV.All(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	events, err := s.planEventsKV.All(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	settlements, err := s.planSettlementsKV.All(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	return Snapshot{
+		Plans:       plans,
+		PlanInfo:    info,
+		Events:      events,
+		Settlements: settlements,
+	}, nil
+}
+
+func (s MongoStorage) restore(ctx context.Context, snapshot Snapshot) error {
+	if err := s.plansKV.ReplaceAll(ctx, snapshot.Plans); err != nil {
+		return err
+	}
+
+	if err := s.planInfoKV.ReplaceAll(ctx, snapshot.PlanInfo); err != nil {
+		return err
+	}
+
+	if err := s.planEventsKV.ReplaceAll(ctx, snapshot.Events); err != nil {
+		return err
+	}
+
+	return s.planSettlementsKV.ReplaceAll(ctx, snapshot.Settlements)
+}
+
+// getKV returns errPlanNotFound if there is no value stored under the key.
+func getKV[T any](ctx context.Context, kv api.MongoKeyValue[T], key string) (T, error) {
+	v, ok, err := kv.Get(ctx, key)
+	if err != nil {
+		return v, err
+	}
+
+	if !ok {
+		return v, errPlanNotFound
+	}
+
+	return v, nil
+}
+
+func setOrDeleteKV[T any](
+	ctx context.Context,
+	kv api.MongoKeyValue[[]T],
+	key string,
+	records []T,
+) error {
+	if records == nil {
+		return kv.Delete(ctx, key)
+	}
+	return kv.Set(ctx, key, records)
+}
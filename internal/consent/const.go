@@ -2,4 +2,7 @@ package consent
 
 const (
 	maxTimeAwaitingAuthorizationSecs = 3600
+	// maxSaveAttempts is how many times a consent is fetched and modified
+	// again when a concurrent modification is detected.
+	maxSaveAttempts = 3
 )
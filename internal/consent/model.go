@@ -2,23 +2,35 @@ package consent
 
 import (
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/luikyv/go-open-insurance/internal/api"
 )
 
 type Consent struct {
-	ID            string                  `bson:"_id"`
-	Status        api.ConsentStatus       `bson:"status"`
-	UserCPF       string                  `bson:"user_cpf"`
-	BusinessCNPJ  string                  `bson:"business_cnpj,omitempty"`
-	ClientId      string                  `bson:"client_id"`
-	Permissions   []api.ConsentPermission `bson:"permissions"`
-	CreatedAt     time.Time               `bson:"created_at"`
-	UpdatedAt     time.Time               `bson:"updated_at"`
-	ExpiresAt     time.Time               `bson:"expires_at"`
-	RejectionInfo *RejectionInfo          `bson:"rejection,omitempty"`
-	Data          api.ConsentData         `json:"data"`
+	ID           string                  `bson:"_id"`
+	Status       api.ConsentStatus       `bson:"status"`
+	UserCPF      string                  `bson:"user_cpf"`
+	BusinessCNPJ string                  `bson:"business_cnpj,omitempty"`
+	ClientId     string                  `bson:"client_id"`
+	Permissions  []api.ConsentPermission `bson:"permissions"`
+	// ResourceIDs are the resources the user chose to share when authorizing
+	// the consent.
+	ResourceIDs []string `bson:"resource_ids,omitempty"`
+	// UserID identifies the user who authorized the consent.
+	UserID string `bson:"user_id,omitempty"`
+	// PreApproved is set when the consent was authorized without user
+	// interaction and no authorization request has used it yet.
+	PreApproved   bool            `bson:"pre_approved,omitempty"`
+	CreatedAt     time.Time       `bson:"created_at"`
+	UpdatedAt     time.Time       `bson:"updated_at"`
+	ExpiresAt     time.Time       `bson:"expires_at"`
+	RejectionInfo *RejectionInfo  `bson:"rejection,omitempty"`
+	Data          api.ConsentData `json:"data"`
+	// Version is incremented every time the consent is saved, so concurrent
+	// modifications can be detected.
+	Version int `bson:"version"`
 }
 
 // HasAuthExpired returns true if the status is [StatusAwaitingAuthorisation] and
@@ -44,10 +56,22 @@ func (c Consent) IsAwaitingAuthorization() bool {
 	return c.Status == api.ConsentStatusAWAITINGAUTHORISATION
 }
 
+func (c Consent) IsPreApproved() bool {
+	return c.IsAuthorized() && c.PreApproved
+}
+
 func (c Consent) HasPermissions(permissions []api.ConsentPermission) bool {
 	return containsAll(c.Permissions, permissions...)
 }
 
+// HasResource returns true if the user shared the resource when authorizing
+// the consent.
+// Consents authorized before resources could be selected have no resource IDs
+// and give access to all the resources covered by their permissions.
+func (c Consent) HasResource(id string) bool {
+	return len(c.ResourceIDs) == 0 || slices.Contains(c.ResourceIDs, id)
+}
+
 type RejectionInfo struct {
 	RejectedBy api.ConsentRejectedBy         `bson:"rejected_by"`
 	Reason     api.ConsentRejectedReasonCode `bson:"reason"`
@@ -400,6 +400,15 @@ var permissionCategories = []PermissionCategory{
 	permissionCategoryCapitalizationTitleWithdrawalWithdrawal,
 }
 
+// IsPermissionOptional returns true if the user can choose not to grant the
+// permission.
+// Only phase 2 permissions other than [api.ConsentPermissionRESOURCESREAD]
+// can be deselected, phase 3 categories must be granted as a whole.
+func IsPermissionOptional(p api.ConsentPermission) bool {
+	return p != api.ConsentPermissionRESOURCESREAD &&
+		slices.Contains(permissionsPhase2, p)
+}
+
 type PermissionCategory []api.ConsentPermission
 
 func (pc PermissionCategory) contains(p api.ConsentPermission) bool {
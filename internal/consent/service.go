@@ -2,6 +2,8 @@ package consent
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 
@@ -9,22 +11,86 @@ import (
 	"github.com/luikyv/go-open-insurance/internal/user"
 )
 
+// errConcurrentModification is returned when the consent was modified by
+// another request while this one was being processed.
+var errConcurrentModification = api.NewError("CONFLICT", http.StatusConflict,
+	"the consent was modified by another request, try again")
+
+// GrantSessionManager gives access to the grants issued based on consents.
+type GrantSessionManager interface {
+	// DeleteByConsentID invalidates all the tokens issued for a consent.
+	DeleteByConsentID(ctx context.Context, consentID string) error
+}
+
 type Service struct {
-	storage     Storage
-	userService user.Service
+	storage             Storage
+	userService         user.Service
+	grantSessionManager GrantSessionManager
 }
 
-func NewService(storage Storage, userService user.Service) Service {
+func NewService(
+	storage Storage,
+	userService user.Service,
+	grantSessionManager GrantSessionManager,
+) Service {
 	return Service{
-		storage:     storage,
-		userService: userService,
+		storage:             storage,
+		userService:         userService,
+		grantSessionManager: grantSessionManager,
 	}
 }
 
+// Authorize authorizes the consent granting the permissions and resources
+// selected by the user.
+// The permissions must be a valid subset of the ones requested by the client.
 func (s Service) Authorize(
 	ctx context.Context,
 	id string,
-	permissions ...api.ConsentPermission,
+	userID string,
+	permissions []api.ConsentPermission,
+	resourceIDs []string,
+) error {
+	return s.authorize(ctx, id, userID, permissions, resourceIDs, false)
+}
+
+// PreApprove authorizes the consent on behalf of the user without going
+// through the consent page.
+// The next authorization request for the consent will be completed without
+// user interaction.
+func (s Service) PreApprove(
+	ctx context.Context,
+	id string,
+	userID string,
+	permissions []api.ConsentPermission,
+	resourceIDs []string,
+) error {
+	return s.authorize(ctx, id, userID, permissions, resourceIDs, true)
+}
+
+// ConsumePreApproval makes sure a pre approval is used by only one
+// authorization request.
+func (s Service) ConsumePreApproval(ctx context.Context, id string) error {
+	if err := s.storage.consumePreApproval(ctx, id); err != nil {
+		if errors.Is(err, errConsentNotPreApproved) {
+			return api.NewError("INVALID_STATUS", http.StatusBadRequest,
+				"the consent is not pre approved")
+		}
+		api.Logger(ctx).Error("could not consume the consent pre approval",
+			slog.String("consent_id", id), slog.Any("error", err))
+		return api.ErrInternal
+	}
+
+	api.Logger(ctx).Info("consent pre approval consumed", slog.String("consent_id", id))
+	return nil
+}
+
+func (s Service) authorize(
+	ctx context.Context,
+	id string,
+	userID string,
+	permissions []api.ConsentPermission,
+	resourceIDs []string,
+	preApproved bool,
 ) error {
 
 	api.Logger(ctx).Debug("trying to authorize consent",
@@ -41,11 +107,26 @@ func (s Service) Authorize(
 			"invalid consent status")
 	}
 
+	if err := validateGrantedPermissions(ctx, consent, permissions); err != nil {
+		api.Logger(ctx).Debug("the permissions granted are not valid",
+			slog.String("consent_id", id), slog.Any("error", err))
+		return err
+	}
+
 	api.Logger(ctx).Info("authorizing consent",
 		slog.String("consent_id", id))
 	consent.Status = api.ConsentStatusAUTHORISED
+	consent.UserID = userID
 	consent.Permissions = permissions
-	return s.save(ctx, consent)
+	consent.ResourceIDs = resourceIDs
+	consent.PreApproved = preApproved
+	return s.save(ctx, &consent)
+}
+
+// Consent fetches the consent regardless of the client that created it.
+// This is intended for administrative operations.
+func (s Service) Consent(ctx context.Context, id string) (Consent, error) {
+	return s.fetchAndModify(ctx, id)
 }
 
 func (s Service) Fetch(
@@ -70,6 +151,9 @@ func (s Service) Fetch(
 	return consent, nil
 }
 
+// FetchAndConsume fetches the consent and marks it as consumed.
+// The consumption is atomic, so if several operations try to use the same
+// consent at the same time, only one of them succeeds.
 func (s Service) FetchAndConsume(
 	ctx context.Context,
 	meta api.RequestMeta,
@@ -78,15 +162,11 @@ func (s Service) FetchAndConsume(
 	Consent,
 	error,
 ) {
-	consent, err := s.Fetch(ctx, meta, id)
-	if err != nil {
-		return Consent{}, err
-	}
-	if err := s.consume(ctx, consent); err != nil {
+	if _, err := s.Fetch(ctx, meta, id); err != nil {
 		return Consent{}, err
 	}
 
-	return consent, nil
+	return s.consume(ctx, id)
 }
 
 func (s Service) Reject(
@@ -129,6 +209,44 @@ func (s Service) Verify(
 	return nil
 }
 
+// RejectExpired moves to rejected all the consents that have been awaiting
+// authorization for too long or that reached their expiration date.
+func (s Service) RejectExpired(ctx context.Context) error {
+	consents, err := s.storage.expired(ctx)
+	if err != nil {
+		api.Logger(ctx).Error("could not fetch the expired consents",
+			slog.Any("error", err))
+		return api.ErrInternal
+	}
+
+	// A consent that can't be rejected must not prevent the others from being
+	// rejected.
+	var errs []error
+	for _, consent := range consents {
+		api.Logger(ctx).Debug("rejecting expired consent",
+			slog.String("consent_id", consent.ID))
+		// If the consent was modified in the meantime, the request that did it
+		// already took care of rejecting it.
+		if err := s.modify(ctx, &consent); err != nil && !errors.Is(err, errConcurrentModification) {
+			api.Logger(ctx).Error("could not reject the expired consent",
+				slog.String("consent_id", consent.ID), slog.Any("error", err))
+			errs = append(errs, fmt.Errorf("could not reject the expired consent %s: %w", consent.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Snapshot returns all the consents.
+func (s Service) Snapshot(ctx context.Context) ([]Consent, error) {
+	return s.storage.all(ctx)
+}
+
+// Restore replaces all the consents by the ones informed.
+func (s Service) Restore(ctx context.Context, consents []Consent) error {
+	return s.storage.replaceAll(ctx, consents)
+}
+
 func (s Service) create(
 	ctx context.Context,
 	meta api.RequestMeta,
@@ -143,7 +261,7 @@ func (s Service) create(
 	}
 
 	api.Logger(ctx).Info("creating consent", slog.String("consent_id", consent.ID))
-	if err := s.save(ctx, consent); err != nil {
+	if err := s.save(ctx, &consent); err != nil {
 		return api.ConsentResponse{}, err
 	}
 
@@ -152,15 +270,26 @@ func (s Service) create(
 
 func (s Service) consume(
 	ctx context.Context,
-	consent Consent,
-) error {
-	if !consent.IsAuthorized() {
-		return api.NewError("INVALID_OPERATION", http.StatusBadRequest,
-			"cannot consume a consent that is not authorized")
+	id string,
+) (
+	Consent,
+	error,
+) {
+	consent, err := s.storage.consume(ctx, id)
+	if err != nil {
+		if errors.Is(err, errConsentNotAuthorised) {
+			api.Logger(ctx).Debug("cannot consume a consent that is not authorized",
+				slog.String("consent_id", id))
+			return Consent{}, api.NewError("INVALID_OPERATION", http.StatusBadRequest,
+				"cannot consume a consent that is not authorized")
+		}
+		api.Logger(ctx).Error("could not consume the consent",
+			slog.String("consent_id", id), slog.Any("error", err))
+		return Consent{}, api.ErrInternal
 	}
 
-	consent.Status = api.ConsentStatusCONSUMED
-	return s.save(ctx, consent)
+	api.Logger(ctx).Info("consent consumed", slog.String("consent_id", id))
+	return consent, nil
 }
 
 func (s Service) delete(
@@ -178,14 +307,10 @@ func (s Service) delete(
 		reason = api.ConsentRejectedReasonCodeCUSTOMERMANUALLYREVOKED
 	}
 
-	if err := s.reject(ctx, &c, RejectionInfo{
+	return s.reject(ctx, &c, RejectionInfo{
 		RejectedBy: api.ConsentRejectedByUSER,
 		Reason:     reason,
-	}); err != nil {
-		return err
-	}
-
-	return s.save(ctx, c)
+	})
 }
 
 func (s Service) reject(
@@ -200,14 +325,36 @@ func (s Service) reject(
 
 	consent.Status = api.ConsentStatusREJECTED
 	consent.RejectionInfo = &info
-	return s.save(ctx, *consent)
+	if err := s.save(ctx, consent); err != nil {
+		return err
+	}
+
+	return s.revokeGrants(ctx, consent.ID)
+}
+
+// revokeGrants deletes the grant sessions issued for the consent so the
+// tokens associated to it stop working immediately.
+func (s Service) revokeGrants(ctx context.Context, consentID string) error {
+	api.Logger(ctx).Debug("revoking the grants issued for the consent",
+		slog.String("consent_id", consentID))
+	if err := s.grantSessionManager.DeleteByConsentID(ctx, consentID); err != nil {
+		api.Logger(ctx).Error("could not revoke the grants of the consent",
+			slog.String("consent_id", consentID), slog.Any("error", err))
+		return api.ErrInternal
+	}
+	return nil
 }
 
 func (s Service) save(
 	ctx context.Context,
-	consent Consent,
+	consent *Consent,
 ) error {
 	if err := s.storage.save(ctx, consent); err != nil {
+		if errors.Is(err, errConsentVersionConflict) {
+			api.Logger(ctx).Debug("the consent was modified concurrently",
+				slog.String("consent_id", consent.ID), slog.Int("version", consent.Version))
+			return errConcurrentModification
+		}
 		api.Logger(ctx).Error("could not save the consent", slog.Any("error", err))
 		return api.ErrInternal
 	}
@@ -230,19 +377,28 @@ func (s Service) fetch(
 	return newResponse(meta, consent), nil
 }
 
+// fetchAndModify fetches the consent and makes it compliant.
+// If another request modifies the consent at the same time, it is fetched
+// again, so reading a consent doesn't fail because of a concurrent update.
 func (s Service) fetchAndModify(ctx context.Context, id string) (Consent, error) {
-	consent, err := s.storage.fetch(ctx, id)
-	if err != nil {
-		api.Logger(ctx).Debug("could not find the consent", slog.Any("error", err))
-		return Consent{}, api.NewError("NOT_FOUND", http.StatusNotFound,
-			"could not find the consent")
-	}
+	for attempt := 1; ; attempt++ {
+		consent, err := s.storage.fetch(ctx, id)
+		if err != nil {
+			api.Logger(ctx).Debug("could not find the consent", slog.Any("error", err))
+			return Consent{}, api.NewError("NOT_FOUND", http.StatusNotFound,
+				"could not find the consent")
+		}
 
-	if err := s.modify(ctx, &consent); err != nil {
-		return Consent{}, err
-	}
+		err = s.modify(ctx, &consent)
+		if errors.Is(err, errConcurrentModification) && attempt < maxSaveAttempts {
+			continue
+		}
+		if err != nil {
+			return Consent{}, err
+		}
 
-	return consent, nil
+		return consent, nil
+	}
 }
 
 // modify will evaluated the consent information and modify it to be compliant.
@@ -273,9 +429,10 @@ func (s Service) modify(ctx context.Context, consent *Consent) error {
 
 	if consentWasModified {
 		api.Logger(ctx).Debug("the consent was modified")
-		if err := s.save(ctx, *consent); err != nil {
+		if err := s.save(ctx, consent); err != nil {
 			return err
 		}
+		return s.revokeGrants(ctx, consent.ID)
 	}
 
 	return nil
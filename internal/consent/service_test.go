@@ -0,0 +1,51 @@
+package consent
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luikyv/go-open-insurance/internal/api"
+	"github.com/luikyv/go-open-insurance/internal/storagetest"
+)
+
+// TestService_FetchAndConsume uses the same consent in several operations at
+// the same time and expects only one of them to succeed.
+func TestService_FetchAndConsume(t *testing.T) {
+	const operations = 20
+
+	for name, st := range testStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			s := Service{storage: st}
+			ctx := context.Background()
+			meta := api.RequestMeta{ClientID: "client"}
+
+			c := Consent{
+				ID:        "urn:mockin:" + uuid.NewString(),
+				Status:    api.ConsentStatusAUTHORISED,
+				ClientId:  meta.ClientID,
+				CreatedAt: time.Now().UTC(),
+				ExpiresAt: time.Now().UTC().Add(time.Hour),
+			}
+			if err := s.save(ctx, &c); err != nil {
+				t.Fatal(err)
+			}
+
+			errs := storagetest.Concurrently(operations, func() error {
+				_, err := s.FetchAndConsume(ctx, meta, c.ID)
+				return err
+			})
+			storagetest.AssertOneSuccess(t, errs, http.StatusBadRequest)
+
+			consumed, err := st.fetch(ctx, c.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if consumed.Status != api.ConsentStatusCONSUMED {
+				t.Errorf("got status %s, want %s", consumed.Status, api.ConsentStatusCONSUMED)
+			}
+		})
+	}
+}
@@ -2,49 +2,38 @@ package consent
 
 import (
 	"context"
-
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"errors"
 )
 
-type Storage struct {
-	collection *mongo.Collection
-}
-
-func NewStorage(db *mongo.Database) Storage {
-	return Storage{
-		collection: db.Collection("consents"),
-	}
-}
-
-func (st Storage) save(ctx context.Context, consent Consent) error {
-	shouldUpsert := true
-	filter := bson.D{{Key: "_id", Value: consent.ID}}
-	if _, err := st.collection.ReplaceOne(
-		ctx,
-		filter,
-		consent,
-		&options.ReplaceOptions{Upsert: &shouldUpsert},
-	); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (st Storage) fetch(ctx context.Context, id string) (Consent, error) {
-	filter := bson.D{{Key: "_id", Value: id}}
-
-	result := st.collection.FindOne(ctx, filter)
-	if result.Err() != nil {
-		return Consent{}, result.Err()
-	}
-
-	var consent Consent
-	if err := result.Decode(&consent); err != nil {
-		return Consent{}, err
-	}
+var (
+	errConsentNotFound        = errors.New("consent not found")
+	errConsentVersionConflict = errors.New("consent was modified concurrently")
+	errConsentNotAuthorised   = errors.New("consent is not authorised")
+	errConsentNotPreApproved  = errors.New("consent is not pre approved")
+)
 
-	return consent, nil
+// Storage persists the consents.
+// The implementations return errConsentNotFound when fetching a consent that
+// doesn't exist.
+type Storage interface {
+	// save inserts the consent if its version is zero, otherwise it replaces
+	// the stored consent only if it still has the same version.
+	// On success, the version of the consent is incremented. If the consent was
+	// saved by someone else in the meantime, errConsentVersionConflict is
+	// returned.
+	save(ctx context.Context, consent *Consent) error
+	fetch(ctx context.Context, id string) (Consent, error)
+	// consume atomically moves the consent from authorised to consumed and
+	// returns it, so it can be used by only one operation. If the consent is
+	// not authorised, errConsentNotAuthorised is returned.
+	consume(ctx context.Context, id string) (Consent, error)
+	// consumePreApproval atomically clears the pre approval of an authorised
+	// consent, so it can be used by only one authorization request. If the
+	// consent is not pre approved, errConsentNotPreApproved is returned.
+	consumePreApproval(ctx context.Context, id string) error
+	// expired returns the consents that have been awaiting authorization for
+	// too long or that are authorized and reached the expiration date.
+	expired(ctx context.Context) ([]Consent, error)
+	all(ctx context.Context) ([]Consent, error)
+	replaceAll(ctx context.Context, consents []Consent) error
 }
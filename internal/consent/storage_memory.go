@@ -0,0 +1,120 @@
+package consent
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+)
+
+// MemoryStorage keeps the consents in memory, so they are lost when the
+// server stops.
+type MemoryStorage struct {
+	mu          sync.RWMutex
+	consentsMap map[string]Consent
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		consentsMap: make(map[string]Consent),
+	}
+}
+
+func (st *MemoryStorage) save(_ context.Context, consent *Consent) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if stored := st.consentsMap[consent.ID]; stored.Version != consent.Version {
+		return errConsentVersionConflict
+	}
+
+	consent.Version++
+	st.consentsMap[consent.ID] = *consent
+	return nil
+}
+
+func (st *MemoryStorage) fetch(_ context.Context, id string) (Consent, error) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	consent, ok := st.consentsMap[id]
+	if !ok {
+		return Consent{}, errConsentNotFound
+	}
+
+	return consent, nil
+}
+
+func (st *MemoryStorage) consume(_ context.Context, id string) (Consent, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	consent, ok := st.consentsMap[id]
+	if !ok {
+		return Consent{}, errConsentNotFound
+	}
+
+	if consent.Status != api.ConsentStatusAUTHORISED {
+		return Consent{}, errConsentNotAuthorised
+	}
+
+	consent.Status = api.ConsentStatusCONSUMED
+	consent.UpdatedAt = time.Now().UTC()
+	consent.Version++
+	st.consentsMap[id] = consent
+	return consent, nil
+}
+
+func (st *MemoryStorage) consumePreApproval(_ context.Context, id string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	consent, ok := st.consentsMap[id]
+	if !ok {
+		return errConsentNotFound
+	}
+
+	if !consent.IsPreApproved() {
+		return errConsentNotPreApproved
+	}
+
+	consent.PreApproved = false
+	consent.UpdatedAt = time.Now().UTC()
+	consent.Version++
+	st.consentsMap[id] = consent
+	return nil
+}
+
+func (st *MemoryStorage) expired(_ context.Context) ([]Consent, error) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	var consents []Consent
+	for _, consent := range st.consentsMap {
+		if consent.HasAuthExpired() || consent.IsExpired() {
+			consents = append(consents, consent)
+		}
+	}
+
+	return consents, nil
+}
+
+func (st *MemoryStorage) all(_ context.Context) ([]Consent, error) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	consents := make([]Consent, 0, len(st.consentsMap))
+	for _, consent := range st.consentsMap {
+		consents = append(consents, consent)
+	}
+	slices.SortFunc(consents, func(a, b Consent) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return consents, nil
+}
+
+func (st *MemoryStorage) replaceAll(_ context.Context, consents []Consent) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	clear(st.consentsMap)
+	for _, consent := range consents {
+		st.consentsMap[consent.ID] = consent
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package consent
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStorage keeps the consents in a mongo collection.
+type MongoStorage struct {
+	collection *mongo.Collection
+}
+
+func NewMongoStorage(db *mongo.Database) MongoStorage {
+	return MongoStorage{
+		collection: db.Collection("consents"),
+	}
+}
+
+func (st MongoStorage) save(ctx context.Context, consent *Consent) error {
+	updated := *consent
+	updated.Version++
+
+	// A new consent is upserted. If it was inserted concurrently, the filter
+	// won't match and the upsert fails with a duplicate key error.
+	// Consents stored before versioning was introduced have no version.
+	if consent.Version == 0 {
+		shouldUpsert := true
+		filter := bson.D{
+			{Key: "_id", Value: consent.ID},
+			{Key: "version", Value: bson.D{{Key: "$in", Value: bson.A{0, nil}}}},
+		}
+		if _, err := st.collection.ReplaceOne(
+			ctx,
+			filter,
+			updated,
+			&options.ReplaceOptions{Upsert: &shouldUpsert},
+		); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return errConsentVersionConflict
+			}
+			return err
+		}
+
+		consent.Version = updated.Version
+		return nil
+	}
+
+	filter := bson.D{
+		{Key: "_id", Value: consent.ID},
+		{Key: "version", Value: consent.Version},
+	}
+	result, err := st.collection.ReplaceOne(ctx, filter, updated)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errConsentVersionConflict
+	}
+
+	consent.Version = updated.Version
+	return nil
+}
+
+func (st MongoStorage) fetch(ctx context.Context, id string) (Consent, error) {
+	filter := bson.D{{Key: "_id", Value: id}}
+
+	result := st.collection.FindOne(ctx, filter)
+	if result.Err() != nil {
+		if errors.Is(result.Err(), mongo.ErrNoDocuments) {
+			return Consent{}, errConsentNotFound
+		}
+		return Consent{}, result.Err()
+	}
+
+	var consent Consent
+	if err := result.Decode(&consent); err != nil {
+		return Consent{}, err
+	}
+
+	return consent, nil
+}
+
+func (st MongoStorage) consume(ctx context.Context, id string) (Consent, error) {
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "status", Value: api.ConsentStatusAUTHORISED},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: api.ConsentStatusCONSUMED},
+			{Key: "updated_at", Value: time.Now().UTC()},
+		}},
+		{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}},
+	}
+
+	result := st.collection.FindOneAndUpdate(
+		ctx,
+		filter,
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	)
+	if result.Err() != nil {
+		if errors.Is(result.Err(), mongo.ErrNoDocuments) {
+			return Consent{}, errConsentNotAuthorised
+		}
+		return Consent{}, result.Err()
+	}
+
+	var consent Consent
+	if err := result.Decode(&consent); err != nil {
+		return Consent{}, err
+	}
+
+	return consent, nil
+}
+
+func (st MongoStorage) consumePreApproval(ctx context.Context, id string) error {
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "status", Value: api.ConsentStatusAUTHORISED},
+		{Key: "pre_approved", Value: true},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "updated_at", Value: time.Now().UTC()}}},
+		{Key: "$unset", Value: bson.D{{Key: "pre_approved", Value: ""}}},
+		{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}},
+	}
+
+	result, err := st.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.ModifiedCount == 0 {
+		return errConsentNotPreApproved
+	}
+	return nil
+}
+
+// expired returns the consents that have been awaiting authorization for too
+// long or that are authorized and reached the expiration date.
+func (st MongoStorage) expired(ctx context.Context) ([]Consent, error) {
+	now := time.Now().UTC()
+	filter := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{
+			{Key: "status", Value: api.ConsentStatusAWAITINGAUTHORISATION},
+			{Key: "created_at", Value: bson.D{
+				{Key: "$lt", Value: now.Add(-time.Second * maxTimeAwaitingAuthorizationSecs)},
+			}},
+		},
+		bson.D{
+			{Key: "status", Value: api.ConsentStatusAUTHORISED},
+			{Key: "expires_at", Value: bson.D{{Key: "$lt", Value: now}}},
+		},
+	}}}
+
+	cursor, err := st.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var consents []Consent
+	if err := cursor.All(ctx, &consents); err != nil {
+		return nil, err
+	}
+
+	return consents, nil
+}
+
+func (st MongoStorage) all(ctx context.Context) ([]Consent, error) {
+	return api.FindAll[Consent](ctx, st.collection)
+}
+
+func (st MongoStorage) replaceAll(ctx context.Context, consents []Consent) error {
+	return api.ReplaceAll(ctx, st.collection, consents)
+}
@@ -0,0 +1,201 @@
+package consent
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+)
+
+// SQLiteStorage keeps the consents in a SQLite table.
+type SQLiteStorage struct {
+	db *sql.DB
+}
+
+func NewSQLiteStorage(db *sql.DB) SQLiteStorage {
+	return SQLiteStorage{
+		db: db,
+	}
+}
+
+// CreateTables creates the consents table if it doesn't exist yet.
+func (st SQLiteStorage) CreateTables(ctx context.Context) error {
+	return api.SQLiteExec(
+		ctx,
+		st.db,
+		`CREATE TABLE IF NOT EXISTS consents (
+			id TEXT PRIMARY KEY,
+			status TEXT NOT NULL,
+			created_at INTEGER NOT NULL,
+			expires_at INTEGER NOT NULL,
+			version INTEGER NOT NULL,
+			data TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS consents_status ON consents (status)`,
+	)
+}
+
+func (st SQLiteStorage) save(ctx context.Context, consent *Consent) error {
+	updated := *consent
+	updated.Version++
+	data, err := json.Marshal(updated)
+	if err != nil {
+		return err
+	}
+
+	var result sql.Result
+	// A new consent is inserted, unless one with the same ID was inserted
+	// concurrently.
+	if consent.Version == 0 {
+		result, err = st.db.ExecContext(
+			ctx,
+			`INSERT INTO consents (id, status, created_at, expires_at, version, data)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (id) DO NOTHING`,
+			updated.ID,
+			updated.Status,
+			updated.CreatedAt.Unix(),
+			updated.ExpiresAt.Unix(),
+			updated.Version,
+			data,
+		)
+	} else {
+		result, err = st.db.ExecContext(
+			ctx,
+			`UPDATE consents
+			SET status = ?, created_at = ?, expires_at = ?, version = ?, data = ?
+			WHERE id = ? AND version = ?`,
+			updated.Status,
+			updated.CreatedAt.Unix(),
+			updated.ExpiresAt.Unix(),
+			updated.Version,
+			data,
+			updated.ID,
+			consent.Version,
+		)
+	}
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return errConsentVersionConflict
+	}
+
+	consent.Version = updated.Version
+	return nil
+}
+
+func (st SQLiteStorage) fetch(ctx context.Context, id string) (Consent, error) {
+	consent, err := api.SQLiteFindOne[Consent](
+		ctx,
+		st.db,
+		`SELECT data FROM consents WHERE id = ?`,
+		id,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Consent{}, errConsentNotFound
+	}
+	return consent, err
+}
+
+func (st SQLiteStorage) consume(ctx context.Context, id string) (Consent, error) {
+	consent, err := api.SQLiteFindOne[Consent](
+		ctx,
+		st.db,
+		`UPDATE consents
+		SET status = ?, version = version + 1, data = json_set(
+			data,
+			'$.Status', ?,
+			'$.UpdatedAt', ?,
+			'$.Version', version + 1
+		)
+		WHERE id = ? AND status = ?
+		RETURNING data`,
+		api.ConsentStatusCONSUMED,
+		api.ConsentStatusCONSUMED,
+		time.Now().UTC().Format(time.RFC3339Nano),
+		id,
+		api.ConsentStatusAUTHORISED,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Consent{}, errConsentNotAuthorised
+	}
+	return consent, err
+}
+
+func (st SQLiteStorage) consumePreApproval(ctx context.Context, id string) error {
+	result, err := st.db.ExecContext(
+		ctx,
+		`UPDATE consents
+		SET version = version + 1, data = json_set(
+			data,
+			'$.PreApproved', json('false'),
+			'$.UpdatedAt', ?,
+			'$.Version', version + 1
+		)
+		WHERE id = ? AND status = ? AND json_extract(data, '$.PreApproved') = 1`,
+		time.Now().UTC().Format(time.RFC3339Nano),
+		id,
+		api.ConsentStatusAUTHORISED,
+	)
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return errConsentNotPreApproved
+	}
+	return nil
+}
+
+func (st SQLiteStorage) expired(ctx context.Context) ([]Consent, error) {
+	now := time.Now().UTC()
+	return api.SQLiteFindAll[Consent](
+		ctx,
+		st.db,
+		`SELECT data FROM consents
+		WHERE (status = ? AND created_at < ?) OR (status = ? AND expires_at < ?)`,
+		api.ConsentStatusAWAITINGAUTHORISATION,
+		now.Add(-time.Second*maxTimeAwaitingAuthorizationSecs).Unix(),
+		api.ConsentStatusAUTHORISED,
+		now.Unix(),
+	)
+}
+
+func (st SQLiteStorage) all(ctx context.Context) ([]Consent, error) {
+	return api.SQLiteFindAll[Consent](
+		ctx,
+		st.db,
+		`SELECT data FROM consents ORDER BY created_at, rowid`,
+	)
+}
+
+func (st SQLiteStorage) replaceAll(ctx context.Context, consents []Consent) error {
+	return api.SQLiteReplaceAll(ctx, st.db, "consents", consents, func(tx *sql.Tx, consent Consent) error {
+		data, err := json.Marshal(consent)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO consents (id, status, created_at, expires_at, version, data)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			consent.ID,
+			consent.Status,
+			consent.CreatedAt.Unix(),
+			consent.ExpiresAt.Unix(),
+			consent.Version,
+			data,
+		)
+		return err
+	})
+}
@@ -0,0 +1,64 @@
+package consent
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luikyv/go-open-insurance/internal/api"
+	"github.com/luikyv/go-open-insurance/internal/storagetest"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func testStorages(t *testing.T) map[string]Storage {
+	return storagetest.Storages(t,
+		func() Storage { return NewMemoryStorage() },
+		func(db *sql.DB) (Storage, error) {
+			st := NewSQLiteStorage(db)
+			return st, st.CreateTables(context.Background())
+		},
+		func(db *mongo.Database) Storage { return NewMongoStorage(db) },
+	)
+}
+
+// TestService_SaveConflict saves the same version of a consent twice at the
+// same time and expects exactly one of them to succeed.
+func TestService_SaveConflict(t *testing.T) {
+	cases := []struct {
+		name   string
+		stored bool
+	}{
+		{name: "double insert"},
+		{name: "version conflict", stored: true},
+	}
+
+	for name, st := range testStorages(t) {
+		for _, c := range cases {
+			t.Run(name+" "+c.name, func(t *testing.T) {
+				s := Service{storage: st}
+				ctx := context.Background()
+				consent := Consent{
+					ID:        "urn:mockin:" + uuid.NewString(),
+					Status:    api.ConsentStatusAWAITINGAUTHORISATION,
+					CreatedAt: time.Now().UTC(),
+					ExpiresAt: time.Now().UTC().Add(time.Hour),
+				}
+				if c.stored {
+					if err := s.save(ctx, &consent); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				errs := storagetest.Concurrently(2, func() error {
+					updated := consent
+					updated.Status = api.ConsentStatusAUTHORISED
+					return s.save(ctx, &updated)
+				})
+				storagetest.AssertOneSuccess(t, errs, http.StatusConflict)
+			})
+		}
+	}
+}
@@ -27,6 +27,10 @@ func validate(ctx context.Context, consent Consent) error {
 			"the expiration time cannot be greater than one year")
 	}
 
+	if err := validateDocuments(consent.Data); err != nil {
+		return err
+	}
+
 	if err := validatePermissions(ctx, consent.Permissions); err != nil {
 		return err
 	}
@@ -40,6 +44,30 @@ func validate(ctx context.Context, consent Consent) error {
 	return nil
 }
 
+// validateDocuments verifies the CPF of the logged user and the CNPJ of the
+// business entity, if informed.
+func validateDocuments(data api.ConsentData) error {
+	if data.LoggedUser.Document.Rel != api.DocumentRelCPF {
+		return api.NewError(api.ErrorCodeInvalidParameter, http.StatusUnprocessableEntity,
+			"the data.loggedUser.document.rel must be CPF")
+	}
+
+	if err := api.ValidateCPF("data.loggedUser.document.identification", data.LoggedUser.Document.Identification); err != nil {
+		return err
+	}
+
+	if data.BusinessEntity == nil {
+		return nil
+	}
+
+	if data.BusinessEntity.Document.Rel != api.DocumentRelCNPJ {
+		return api.NewError(api.ErrorCodeInvalidParameter, http.StatusUnprocessableEntity,
+			"the data.businessEntity.document.rel must be CNPJ")
+	}
+
+	return api.ValidateCNPJ("data.businessEntity.document.identification", data.BusinessEntity.Document.Identification)
+}
+
 func validatePermissions(_ context.Context, requestedPermissions []api.ConsentPermission) error {
 
 	isPhase2 := containsAny(permissionsPhase2, requestedPermissions...)
@@ -60,6 +88,28 @@ func validatePermissions(_ context.Context, requestedPermissions []api.ConsentPe
 	return nil
 }
 
+// validateGrantedPermissions checks the permissions granted by the user are
+// among the ones requested and that only optional permissions were left out.
+func validateGrantedPermissions(
+	ctx context.Context,
+	consent Consent,
+	grantedPermissions []api.ConsentPermission,
+) error {
+	if !containsAll(consent.Permissions, grantedPermissions...) {
+		return api.NewError("INVALID_REQUEST", http.StatusBadRequest,
+			"permissions not requested cannot be granted")
+	}
+
+	for _, p := range consent.Permissions {
+		if !IsPermissionOptional(p) && !slices.Contains(grantedPermissions, p) {
+			return api.NewError("INVALID_REQUEST", http.StatusBadRequest,
+				fmt.Sprintf("the permission %s must be granted", p))
+		}
+	}
+
+	return validatePermissions(ctx, grantedPermissions)
+}
+
 func validatePermissionsPhase2(requestedPermissions []api.ConsentPermission) error {
 
 	if !slices.Contains(requestedPermissions, api.ConsentPermissionRESOURCESREAD) {
@@ -24,7 +24,10 @@ func (s ServerV1) PersonalIdentificationsV1(
 	error,
 ) {
 	meta := api.NewRequestMeta(ctx)
-	resp := s.service.personalIdentifications(meta)
+	resp, err := s.service.personalIdentifications(ctx, meta)
+	if err != nil {
+		return nil, err
+	}
 	return api.PersonalIdentificationsV1200JSONResponse(resp), nil
 }
 
@@ -36,7 +39,10 @@ func (s ServerV1) PersonalQualificationsV1(
 	error,
 ) {
 	meta := api.NewRequestMeta(ctx)
-	resp := s.service.personalQualifications(meta)
+	resp, err := s.service.personalQualifications(ctx, meta)
+	if err != nil {
+		return nil, err
+	}
 	return api.PersonalQualificationsV1200JSONResponse(resp), nil
 }
 
@@ -48,6 +54,9 @@ func (s ServerV1) PersonalComplimentaryInfoV1(
 	error,
 ) {
 	meta := api.NewRequestMeta(ctx)
-	resp := s.service.personalComplimentaryInfos(meta)
+	resp, err := s.service.personalComplimentaryInfos(ctx, meta)
+	if err != nil {
+		return nil, err
+	}
 	return api.PersonalComplimentaryInfoV1200JSONResponse(resp), nil
 }
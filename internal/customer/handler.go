@@ -0,0 +1,49 @@
+package customer
+
+import (
+	"net/http"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+)
+
+// RegisterAdminHandlers adds the endpoints to manage the customer data of the
+// users under prefix.
+func RegisterAdminHandlers(
+	mux *http.ServeMux,
+	prefix string,
+	service Service,
+	validate api.SchemaValidator,
+) {
+	path := prefix + "/users/{username}/customers/personal"
+	api.RegisterListHandlers(
+		mux,
+		path+"/identifications",
+		"PersonalIdentificationData",
+		validate,
+		username,
+		service.PersonalIdentifications,
+		service.SetPersonalIdentifications,
+	)
+	api.RegisterListHandlers(
+		mux,
+		path+"/qualifications",
+		"PersonalQualificationData",
+		validate,
+		username,
+		service.PersonalQualifications,
+		service.SetPersonalQualifications,
+	)
+	api.RegisterListHandlers(
+		mux,
+		path+"/complimentary-information",
+		"PersonalComplimentaryInfoData",
+		validate,
+		username,
+		service.PersonalComplimentaryInfos,
+		service.SetPersonalComplimentaryInfos,
+	)
+}
+
+func username(r *http.Request) string {
+	return r.PathValue("username")
+}
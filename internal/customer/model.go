@@ -67,3 +67,10 @@ func newPersonalComplimentaryInfoResponse(
 
 	return resp
 }
+
+// Snapshot is the customer data of all users indexed by username.
+type Snapshot struct {
+	PersonalIdentifications   map[string][]api.PersonalIdentificationData    `json:"personal_identifications"`
+	PersonalQualifications    map[string][]api.PersonalQualificationData     `json:"personal_qualifications"`
+	PersonalComplimentaryInfo map[string][]api.PersonalComplimentaryInfoData `json:"personal_complimentary_info"`
+}
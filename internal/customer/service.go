@@ -1,115 +1,131 @@
 package customer
 
 import (
+	"context"
+
 	"github.com/luikyv/go-open-insurance/internal/api"
 )
 
 type Service struct {
-	storage *Storage
+	storage Storage
 }
 
-func NewService(storage *Storage) Service {
+func NewService(storage Storage) Service {
 	return Service{
 		storage: storage,
 	}
 }
 
-func (s Service) AddPersonalIdentification(
-	sub string,
-	identification api.PersonalIdentificationData,
-) {
-	s.storage.addPersonalIdentification(sub, identification)
-}
-
 func (s Service) personalIdentifications(
+	ctx context.Context,
 	meta api.RequestMeta,
-) api.GetPersonalIdentificationResponse {
-	identifications := s.storage.personalIdentifications(meta.Subject)
-	return newPersonalIdentificationsResponse(meta, identifications)
-}
-
-func (s Service) AddPersonalQualification(
-	sub string,
-	qualification api.PersonalQualificationData,
+) (
+	api.GetPersonalIdentificationResponse,
+	error,
 ) {
-	s.storage.addPersonalQualification(sub, qualification)
+	identifications, err := s.storage.personalIdentifications(ctx, meta.Subject)
+	if err != nil {
+		return api.GetPersonalIdentificationResponse{}, err
+	}
+	return newPersonalIdentificationsResponse(meta, identifications), nil
 }
 
 func (s Service) personalQualifications(
+	ctx context.Context,
 	meta api.RequestMeta,
-) api.GetPersonalQualificationResponse {
-	qualifications := s.storage.personalQualifications(meta.Subject)
-	return newPersonalQualificationsResponse(meta, qualifications)
-}
-
-func (s Service) AddPersonalComplimentaryInfo(
-	sub string,
-	info api.PersonalComplimentaryInfoData,
+) (
+	api.GetPersonalQualificationResponse,
+	error,
 ) {
-	s.storage.addPersonalComplimentaryInfo(sub, info)
+	qualifications, err := s.storage.personalQualifications(ctx, meta.Subject)
+	if err != nil {
+		return api.GetPersonalQualificationResponse{}, err
+	}
+	return newPersonalQualificationsResponse(meta, qualifications), nil
 }
 
 func (s Service) personalComplimentaryInfos(
+	ctx context.Context,
 	meta api.RequestMeta,
-) api.GetPersonalComplimentaryInfoResponse {
-	infos := s.storage.personalComplimentaryInfos(meta.Subject)
-	return newPersonalComplimentaryInfoResponse(meta, infos)
+) (
+	api.GetPersonalComplimentaryInfoResponse,
+	error,
+) {
+	infos, err := s.storage.personalComplimentaryInfos(ctx, meta.Subject)
+	if err != nil {
+		return api.GetPersonalComplimentaryInfoResponse{}, err
+	}
+	return newPersonalComplimentaryInfoResponse(meta, infos), nil
 }
 
-type Storage struct {
-	personalIdentificationsMap   map[string][]api.PersonalIdentificationData
-	personalQualificationsMap    map[string][]api.PersonalQualificationData
-	personalComplimentaryInfoMap map[string][]api.PersonalComplimentaryInfoData
+// PersonalIdentifications returns the identifications of the user.
+func (s Service) PersonalIdentifications(
+	ctx context.Context,
+	sub string,
+) (
+	[]api.PersonalIdentificationData,
+	error,
+) {
+	return s.storage.personalIdentifications(ctx, sub)
 }
 
-func NewStorage() *Storage {
-	return &Storage{
-		personalIdentificationsMap:   make(map[string][]api.PersonalIdentificationData),
-		personalQualificationsMap:    make(map[string][]api.PersonalQualificationData),
-		personalComplimentaryInfoMap: make(map[string][]api.PersonalComplimentaryInfoData),
-	}
+// SetPersonalIdentifications replaces all the identifications of the user.
+func (s Service) SetPersonalIdentifications(
+	ctx context.Context,
+	sub string,
+	identifications []api.PersonalIdentificationData,
+) error {
+	return s.storage.setPersonalIdentifications(ctx, sub, identifications)
 }
 
-func (s *Storage) addPersonalIdentification(
+// PersonalQualifications returns the qualifications of the user.
+func (s Service) PersonalQualifications(
+	ctx context.Context,
 	sub string,
-	identification api.PersonalIdentificationData,
+) (
+	[]api.PersonalQualificationData,
+	error,
 ) {
-	s.personalIdentificationsMap[sub] = append(
-		s.personalIdentificationsMap[sub],
-		identification,
-	)
+	return s.storage.personalQualifications(ctx, sub)
 }
 
-func (s *Storage) personalIdentifications(
+// SetPersonalQualifications replaces all the qualifications of the user.
+func (s Service) SetPersonalQualifications(
+	ctx context.Context,
 	sub string,
-) []api.PersonalIdentificationData {
-	return s.personalIdentificationsMap[sub]
+	qualifications []api.PersonalQualificationData,
+) error {
+	return s.storage.setPersonalQualifications(ctx, sub, qualifications)
 }
 
-func (s *Storage) addPersonalQualification(
+// PersonalComplimentaryInfos returns the complimentary information of the
+// user.
+func (s Service) PersonalComplimentaryInfos(
+	ctx context.Context,
 	sub string,
-	qualification api.PersonalQualificationData,
+) (
+	[]api.PersonalComplimentaryInfoData,
+	error,
 ) {
-	s.personalQualificationsMap[sub] = append(
-		s.personalQualificationsMap[sub],
-		qualification,
-	)
+	return s.storage.personalComplimentaryInfos(ctx, sub)
 }
 
-func (s *Storage) personalQualifications(sub string) []api.PersonalQualificationData {
-	return s.personalQualificationsMap[sub]
+// SetPersonalComplimentaryInfos replaces all the complimentary information of
+// the user.
+func (s Service) SetPersonalComplimentaryInfos(
+	ctx context.Context,
+	sub string,
+	infos []api.PersonalComplimentaryInfoData,
+) error {
+	return s.storage.setPersonalComplimentaryInfos(ctx, sub, infos)
 }
 
-func (s *Storage) addPersonalComplimentaryInfo(
-	sub string,
-	info api.PersonalComplimentaryInfoData,
-) {
-	s.personalComplimentaryInfoMap[sub] = append(
-		s.personalComplimentaryInfoMap[sub],
-		info,
-	)
+// Snapshot returns the customer data of all users.
+func (s Service) Snapshot(ctx context.Context) (Snapshot, error) {
+	return s.storage.snapshot(ctx)
 }
 
-func (s *Storage) personalComplimentaryInfos(sub string) []api.PersonalComplimentaryInfoData {
-	return s.personalComplimentaryInfoMap[sub]
+// Restore replaces the customer data of all users by the one in the snapshot.
+func (s Service) Restore(ctx context.Context, snapshot Snapshot) error {
+	return s.storage.restore(ctx, snapshot)
 }
@@ -0,0 +1,20 @@
+package customer
+
+import (
+	"context"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+)
+
+// Storage persists the customer data of the users.
+// Setting the data of a user to nil removes it.
+type Storage interface {
+	personalIdentifications(ctx context.Context, sub string) ([]api.PersonalIdentificationData, error)
+	setPersonalIdentifications(ctx context.Context, sub string, identifications []api.PersonalIdentificationData) error
+	personalQualifications(ctx context.Context, sub string) ([]api.PersonalQualificationData, error)
+	setPersonalQualifications(ctx context.Context, sub string, qualifications []api.PersonalQualificationData) error
+	personalComplimentaryInfos(ctx context.Context, sub string) ([]api.PersonalComplimentaryInfoData, error)
+	setPersonalComplimentaryInfos(ctx context.Context, sub string, infos []api.PersonalComplimentaryInfoData) error
+	snapshot(ctx context.Context) (Snapshot, error)
+	restore(ctx context.Context, snapshot Snapshot) error
+}
@@ -0,0 +1,126 @@
+package customer
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+)
+
+// MemoryStorage keeps the customer data in memory, so it is lost when the
+// server stops.
+type MemoryStorage struct {
+	mu                           sync.RWMutex
+	personalIdentificationsMap   map[string][]api.PersonalIdentificationData
+	personalQualificationsMap    map[string][]api.PersonalQualificationData
+	personalComplimentaryInfoMap map[string][]api.PersonalComplimentaryInfoData
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		personalIdentificationsMap:   make(map[string][]api.PersonalIdentificationData),
+		personalQualificationsMap:    make(map[string][]api.PersonalQualificationData),
+		personalComplimentaryInfoMap: make(map[string][]api.PersonalComplimentaryInfoData),
+	}
+}
+
+func (s *MemoryStorage) setPersonalIdentifications(
+	_ context.Context,
+	sub string,
+	identifications []api.PersonalIdentificationData,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	setOrDelete(s.personalIdentificationsMap, sub, identifications)
+	return nil
+}
+
+func (s *MemoryStorage) personalIdentifications(
+	_ context.Context,
+	sub string,
+) (
+	[]api.PersonalIdentificationData,
+	error,
+) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clip(s.personalIdentificationsMap[sub]), nil
+}
+
+func (s *MemoryStorage) setPersonalQualifications(
+	_ context.Context,
+	sub string,
+	qualifications []api.PersonalQualificationData,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	setOrDelete(s.personalQualificationsMap, sub, qualifications)
+	return nil
+}
+
+func (s *MemoryStorage) personalQualifications(
+	_ context.Context,
+	sub string,
+) (
+	[]api.PersonalQualificationData,
+	error,
+) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clip(s.personalQualificationsMap[sub]), nil
+}
+
+func (s *MemoryStorage) setPersonalComplimentaryInfos(
+	_ context.Context,
+	sub string,
+	infos []api.PersonalComplimentaryInfoData,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	setOrDelete(s.personalComplimentaryInfoMap, sub, infos)
+	return nil
+}
+
+func (s *MemoryStorage) personalComplimentaryInfos(
+	_ context.Context,
+	sub string,
+) (
+	[]api.PersonalComplimentaryInfoData,
+	error,
+) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clip(s.personalComplimentaryInfoMap[sub]), nil
+}
+
+func (s *MemoryStorage) snapshot(_ context.Context) (Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return Snapshot{
+		PersonalIdentifications:   maps.Clone(s.personalIdentificationsMap),
+		PersonalQualifications:    maps.Clone(s.personalQualificationsMap),
+		PersonalComplimentaryInfo: maps.Clone(s.personalComplimentaryInfoMap),
+	}, nil
+}
+
+func (s *MemoryStorage) restore(_ context.Context, snapshot Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.personalIdentificationsMap = make(map[string][]api.PersonalIdentificationData)
+	s.personalQualificationsMap = make(map[string][]api.PersonalQualificationData)
+	s.personalComplimentaryInfoMap = make(map[string][]api.PersonalComplimentaryInfoData)
+	maps.Copy(s.personalIdentificationsMap, snapshot.PersonalIdentifications)
+	maps.Copy(s.personalQualificationsMap, snapshot.PersonalQualifications)
+	maps.Copy(s.personalComplimentaryInfoMap, snapshot.PersonalComplimentaryInfo)
+	return nil
+}
+
+func setOrDelete[T any](m map[string][]T, sub string, records []T) {
+	if records == nil {
+		delete(m, sub)
+		return
+	}
+	m[sub] = slices.Clip(records)
+}
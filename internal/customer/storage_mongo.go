@@ -0,0 +1,131 @@
+package customer
+
+import (
+	"context"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MongoStorage keeps the customer data in mongo collections, one document per
+// user in each of them.
+type MongoStorage struct {
+	personalIdentificationsKV   api.MongoKeyValue[[]api.PersonalIdentificationData]
+	personalQualificationsKV    api.MongoKeyValue[[]api.PersonalQualificationData]
+	personalComplimentaryInfoKV api.MongoKeyValue[[]api.PersonalComplimentaryInfoData]
+}
+
+func NewMongoStorage(db *mongo.Database) MongoStorage {
+	return MongoStorage{
+		personalIdentificationsKV: api.NewMongoKeyValue[[]api.PersonalIdentificationData](
+			db.Collection("customer_personal_identifications")),
+		personalQualificationsKV: api.NewMongoKeyValue[[]api.PersonalQualificationData](
+			db.Collection("customer_personal_qualifications")),
+		personalComplimentaryInfoKV: api.NewMongoKeyValue[[]api.PersonalComplimentaryInfoData](
+			db.Collection("customer_personal_complimentary_info")),
+	}
+}
+
+func (s MongoStorage) setPersonalIdentifications(
+	ctx context.Context,
+	sub string,
+	identifications []api.PersonalIdentificationData,
+) error {
+	return setOrDeleteKV(ctx, s.personalIdentificationsKV, sub, identifications)
+}
+
+func (s MongoStorage) personalIdentifications(
+	ctx context.Context,
+	sub string,
+) (
+	[]api.PersonalIdentificationData,
+	error,
+) {
+	identifications, _, err := s.personalIdentificationsKV.Get(ctx, sub)
+	return identifications, err
+}
+
+func (s MongoStorage) setPersonalQualifications(
+	ctx context.Context,
+	sub string,
+	qualifications []api.PersonalQualificationData,
+) error {
+	return setOrDeleteKV(ctx, s.personalQualificationsKV, sub, qualifications)
+}
+
+func (s MongoStorage) personalQualifications(
+	ctx context.Context,
+	sub string,
+) (
+	[]api.PersonalQualificationData,
+	error,
+) {
+	qualifications, _, err := s.personalQualificationsKV.Get(ctx, sub)
+	return qualifications, err
+}
+
+func (s MongoStorage) setPersonalComplimentaryInfos(
+	ctx context.Context,
+	sub string,
+	infos []api.PersonalComplimentaryInfoData,
+) error {
+	return setOrDeleteKV(ctx, s.personalComplimentaryInfoKV, sub, infos)
+}
+
+func (s MongoStorage) personalComplimentaryInfos(
+	ctx context.Context,
+	sub string,
+) (
+	[]api.PersonalComplimentaryInfoData,
+	error,
+) {
+	infos, _, err := s.personalComplimentaryInfoKV.Get(ctx, sub)
+	return infos, err
+}
+
+func (s MongoStorage) snapshot(ctx context.Context) (Snapshot, error) {
+	identifications, err := s.personalIdentificationsKV.All(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	qualifications, err := s.personalQualificationsKV.All(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	infos, err := s.personalComplimentaryInfoKV.All(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	return Snapshot{
+		PersonalIdentifications:   identifications,
+		PersonalQualifications:    qualifications,
+		PersonalComplimentaryInfo: infos,
+	}, nil
+}
+
+func (s MongoStorage) restore(ctx context.Context, snapshot Snapshot) error {
+	if err := s.personalIdentificationsKV.ReplaceAll(ctx, snapshot.PersonalIdentifications); err != nil {
+		return err
+	}
+
+	if err := s.personalQualificationsKV.ReplaceAll(ctx, snapshot.PersonalQualifications); err != nil {
+		return err
+	}
+
+	return s.personalComplimentaryInfoKV.ReplaceAll(ctx, snapshot.PersonalComplimentaryInfo)
+}
+
+func setOrDeleteKV[T any](
+	ctx context.Context,
+	kv api.MongoKeyValue[[]T],
+	sub string,
+	records []T,
+) error {
+	if records == nil {
+		return kv.Delete(ctx, sub)
+	}
+	return kv.Set(ctx, sub, records)
+}
@@ -2,7 +2,9 @@ package endorsement
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/luikyv/go-open-insurance/internal/api"
@@ -64,6 +66,10 @@ func (s Service) validate(
 			meta.Error.Error())
 	}
 
+	if err := validateDocuments(endorsement.CustomData); err != nil {
+		return err
+	}
+
 	info := *consent.Data.EndorsementInformation
 	if endorsement.PolicyNumber != info.PolicyNumber {
 		return api.NewError("NAO_INFORMADO", http.StatusUnprocessableEntity,
@@ -81,6 +87,58 @@ func (s Service) validate(
 	return nil
 }
 
+// validateDocuments verifies the CPFs and CNPJs informed in the custom data of
+// the endorsement, which are identified by their field IDs.
+func validateDocuments(data *api.EndorsementCustomData) error {
+	if data == nil {
+		return nil
+	}
+
+	groups := []struct {
+		name  string
+		infos *[]api.CustomInfoData
+	}{
+		{"beneficiaries", data.Beneficiaries},
+		{"businessComplimentaryInfo", data.BusinessComplimentaryInfo},
+		{"businessIdentification", data.BusinessIdentification},
+		{"businessQualification", data.BusinessQualification},
+		{"coverages", data.Coverages},
+		{"customerComplimentaryInfo", data.CustomerComplimentaryInfo},
+		{"customerIdentification", data.CustomerIdentification},
+		{"customerQualification", data.CustomerQualification},
+		{"generalQuoteInfo", data.GeneralQuoteInfo},
+		{"insuredObjects", data.InsuredObjects},
+		{"riskLocationInfo", data.RiskLocationInfo},
+	}
+	for _, group := range groups {
+		if group.infos == nil {
+			continue
+		}
+
+		for i, info := range *group.infos {
+			document, ok := info.Value.(string)
+			if !ok {
+				continue
+			}
+
+			field := fmt.Sprintf("data.customData.%s[%d].value", group.name, i)
+			fieldID := strings.ToLower(info.FieldId)
+			switch {
+			case strings.Contains(fieldID, "cnpj"):
+				if err := api.ValidateCNPJ(field, document); err != nil {
+					return err
+				}
+			case strings.Contains(fieldID, "cpf"):
+				if err := api.ValidateCPF(field, document); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
 func ID() string {
 	return uuid.NewString()
 }
@@ -0,0 +1,31 @@
+package fixture
+
+import "fmt"
+
+// Error points to the field of a fixture file that could not be loaded.
+type Error struct {
+	File string
+	// Field is the path to the offending field, e.g.
+	// users[0].customers.personal.identifications[1].cpfNumber.
+	Field  string
+	Reason string
+}
+
+func (err Error) Error() string {
+	if err.Field == "" {
+		return fmt.Sprintf("%s: %s", err.File, err.Reason)
+	}
+	return fmt.Sprintf("%s: %s: %s", err.File, err.Field, err.Reason)
+}
+
+// Errors gathers all the problems found in the fixture files so they can be
+// fixed at once.
+type Errors []Error
+
+func (errs Errors) Error() string {
+	msg := fmt.Sprintf("%d invalid fixture field(s)", len(errs))
+	for _, err := range errs {
+		msg += "\n\t" + err.Error()
+	}
+	return msg
+}
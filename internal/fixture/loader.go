@@ -0,0 +1,406 @@
+package fixture
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/luikyv/go-open-insurance/internal/api"
+	"github.com/luikyv/go-open-insurance/internal/capitalizationtitle"
+	"github.com/luikyv/go-open-insurance/internal/customer"
+	"github.com/luikyv/go-open-insurance/internal/resource"
+	"github.com/luikyv/go-open-insurance/internal/user"
+	"gopkg.in/yaml.v3"
+)
+
+// Loader reads the YAML and JSON fixture files of a directory and creates the
+// data described in them.
+type Loader struct {
+	dir                        string
+	schemas                    openapi3.Schemas
+	userService                user.Service
+	customerService            customer.Service
+	resourceService            resource.Service
+	capitalizationTitleService capitalizationtitle.Service
+	// mu serializes the loads, so a reload doesn't interleave with the
+	// fixtures applied through the admin API.
+	mu sync.Mutex
+	// modTimes is when each fixture file was last modified as of the last
+	// successful load.
+	modTimes map[string]time.Time
+	// users are the users defined in the fixture files as of the last
+	// successful load, so unchanged users are not saved again and removed ones
+	// are deleted.
+	users map[string]User
+}
+
+func NewLoader(
+	dir string,
+	swagger *openapi3.T,
+	userService user.Service,
+	customerService customer.Service,
+	resourceService resource.Service,
+	capitalizationTitleService capitalizationtitle.Service,
+) *Loader {
+	return &Loader{
+		dir:                        dir,
+		schemas:                    swagger.Components.Schemas,
+		userService:                userService,
+		customerService:            customerService,
+		resourceService:            resourceService,
+		capitalizationTitleService: capitalizationTitleService,
+	}
+}
+
+// Load validates all the fixture files and then creates or replaces the data
+// described in them.
+// Users whose definition didn't change since the last load are skipped, so
+// their password is not hashed again and their lockout is kept, and users no
+// longer defined are deleted.
+// If any file is invalid, nothing is loaded and an [Errors] is returned
+// listing all the offending fields.
+func (l *Loader) Load(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.load(ctx)
+}
+
+func (l *Loader) load(ctx context.Context) error {
+	modTimes, err := l.files()
+	if err != nil {
+		return err
+	}
+
+	var files []string
+	for file := range modTimes {
+		files = append(files, file)
+	}
+	slices.Sort(files)
+
+	fixtures := make(map[string]Fixture, len(files))
+	var errs Errors
+	for _, file := range files {
+		f, fileErrs := parse(l.schemas, file)
+		if len(fileErrs) != 0 {
+			errs = append(errs, fileErrs...)
+			continue
+		}
+		fixtures[file] = f
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+
+	// Companies go first as users can represent companies defined in other
+	// files.
+	for _, file := range files {
+		for i, c := range fixtures[file].Companies {
+			if err := l.saveCompany(ctx, c); err != nil {
+				return Error{File: file, Field: fmt.Sprintf("companies[%d]", i), Reason: err.Error()}
+			}
+		}
+	}
+
+	users := make(map[string]User)
+	for _, file := range files {
+		for i, u := range fixtures[file].Users {
+			users[u.UserName] = u
+			if previous, ok := l.users[u.UserName]; ok && reflect.DeepEqual(previous, u) {
+				continue
+			}
+			if err := l.saveUser(ctx, u); err != nil {
+				return Error{File: file, Field: fmt.Sprintf("users[%d]", i), Reason: err.Error()}
+			}
+		}
+	}
+
+	for username := range l.users {
+		if _, ok := users[username]; ok {
+			continue
+		}
+		if err := l.deleteUser(ctx, username); err != nil {
+			return fmt.Errorf("could not delete the user %s: %w", username, err)
+		}
+	}
+
+	l.users = users
+	l.modTimes = modTimes
+	api.Logger(ctx).Info("fixtures loaded", slog.Int("files", len(files)))
+	return nil
+}
+
+// Apply validates f and then creates or replaces the data described in it as
+// if it was loaded from a fixture file called name.
+func (l *Loader) Apply(ctx context.Context, name string, f Fixture) error {
+	if err := Validate(l.schemas, name, f); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i, c := range f.Companies {
+		if err := l.saveCompany(ctx, c); err != nil {
+			return Error{File: name, Field: fmt.Sprintf("companies[%d]", i), Reason: err.Error()}
+		}
+	}
+
+	for i, u := range f.Users {
+		if err := l.saveUser(ctx, u); err != nil {
+			return Error{File: name, Field: fmt.Sprintf("users[%d]", i), Reason: err.Error()}
+		}
+		// The user no longer matches the fixture files, so the next load
+		// saves it again.
+		delete(l.users, u.UserName)
+	}
+
+	return nil
+}
+
+// Reload loads the fixtures again if any file was created, modified or
+// removed since the last load.
+func (l *Loader) Reload(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	modTimes, err := l.files()
+	if err != nil {
+		return err
+	}
+
+	if maps.Equal(modTimes, l.modTimes) {
+		return nil
+	}
+
+	api.Logger(ctx).Info("fixture files changed, reloading them", slog.String("dir", l.dir))
+	return l.load(ctx)
+}
+
+// files returns when each fixture file in the directory was last modified.
+func (l *Loader) files() (map[string]time.Time, error) {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the fixtures directory: %w", err)
+	}
+
+	modTimes := make(map[string]time.Time)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		switch filepath.Ext(entry.Name()) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		modTimes[filepath.Join(l.dir, entry.Name())] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+func (l *Loader) saveCompany(ctx context.Context, c Company) error {
+	company := user.Company(c)
+	if _, err := l.userService.Company(ctx, company.CNPJ); err == nil {
+		return l.userService.UpdateCompany(ctx, company)
+	}
+	return l.userService.CreateCompany(ctx, company)
+}
+
+// saveUser creates or replaces the user and all of their data.
+func (l *Loader) saveUser(ctx context.Context, u User) error {
+	usr := user.User{
+		UserName:     u.UserName,
+		Email:        u.Email,
+		CPF:          u.CPF,
+		Name:         u.Name,
+		CompanyCNPJs: u.CompanyCNPJs,
+	}
+	if u.PasswordExpiresAt != nil {
+		usr.PasswordExpiresAt = *u.PasswordExpiresAt
+	}
+	if u.LockedUntil != nil {
+		usr.LockedUntil = *u.LockedUntil
+	}
+
+	if _, err := l.userService.User(ctx, usr.UserName); err == nil {
+		if err := l.userService.Update(ctx, usr, u.Password); err != nil {
+			return err
+		}
+	} else if err := l.userService.Create(ctx, usr, u.Password); err != nil {
+		return err
+	}
+
+	sub := usr.UserName
+	personal := u.Customers.Personal
+	if err := l.customerService.SetPersonalIdentifications(ctx, sub, personal.Identifications); err != nil {
+		return err
+	}
+	if err := l.customerService.SetPersonalQualifications(ctx, sub, personal.Qualifications); err != nil {
+		return err
+	}
+	if err := l.customerService.SetPersonalComplimentaryInfos(ctx, sub, personal.ComplimentaryInformation); err != nil {
+		return err
+	}
+
+	// Resources removed from the fixture are deleted, so the user ends up as
+	// if it was loaded for the first time.
+	if err := l.deleteResources(ctx, sub, u.Resources); err != nil {
+		return err
+	}
+	for _, rs := range u.Resources {
+		if err := l.resourceService.Save(ctx, sub, rs); err != nil {
+			return err
+		}
+	}
+
+	capTitle := u.CapitalizationTitle
+	if err := l.capitalizationTitleService.SetPlans(ctx, sub, capTitle.Plans); err != nil {
+		return err
+	}
+	for planID, info := range capTitle.PlanInfo {
+		if err := l.capitalizationTitleService.SetPlanInfo(ctx, sub, planID, info); err != nil {
+			return err
+		}
+	}
+	for planID, events := range capTitle.Events {
+		if err := l.capitalizationTitleService.SetPlanEvents(ctx, sub, planID, events); err != nil {
+			return err
+		}
+	}
+	for planID, settlements := range capTitle.Settlements {
+		if err := l.capitalizationTitleService.SetPlanSettlements(ctx, sub, planID, settlements); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteUser deletes the user and all of their data.
+func (l *Loader) deleteUser(ctx context.Context, username string) error {
+	if err := l.customerService.SetPersonalIdentifications(ctx, username, nil); err != nil {
+		return err
+	}
+	if err := l.customerService.SetPersonalQualifications(ctx, username, nil); err != nil {
+		return err
+	}
+	if err := l.customerService.SetPersonalComplimentaryInfos(ctx, username, nil); err != nil {
+		return err
+	}
+
+	if err := l.capitalizationTitleService.SetPlans(ctx, username, nil); err != nil {
+		return err
+	}
+
+	if err := l.deleteResources(ctx, username, nil); err != nil {
+		return err
+	}
+
+	// The user may have been deleted through the admin API already.
+	err := l.userService.Delete(ctx, username)
+	var apiErr api.Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+// deleteResources deletes the resources of the user that are not in keep.
+func (l *Loader) deleteResources(ctx context.Context, sub string, keep []api.ResourceData) error {
+	resources, err := l.resourceService.All(ctx, sub)
+	if err != nil {
+		return err
+	}
+
+	for _, rs := range resources {
+		if slices.ContainsFunc(keep, func(k api.ResourceData) bool {
+			return k.ResourceId == rs.ResourceId
+		}) {
+			continue
+		}
+		if err := l.resourceService.Delete(ctx, sub, rs.ResourceId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parse reads a YAML or JSON fixture file and validates it.
+func parse(schemas openapi3.Schemas, file string) (Fixture, []Error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return Fixture{}, []Error{{File: file, Reason: err.Error()}}
+	}
+
+	// JSON is also valid YAML, so both formats are parsed the same way.
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return Fixture{}, []Error{{File: file, Reason: err.Error()}}
+	}
+	if node.Kind == 0 {
+		return Fixture{}, nil
+	}
+	keepTimestampsAsStrings(&node)
+
+	var raw any
+	if err := node.Decode(&raw); err != nil {
+		return Fixture{}, []Error{{File: file, Reason: err.Error()}}
+	}
+
+	// Go through JSON so the values have the same types the schema validation
+	// expects, e.g. float64 for numbers.
+	jsonData, err := json.Marshal(raw)
+	if err != nil {
+		return Fixture{}, []Error{{File: file, Reason: err.Error()}}
+	}
+	if err := json.Unmarshal(jsonData, &raw); err != nil {
+		return Fixture{}, []Error{{File: file, Reason: err.Error()}}
+	}
+
+	if errs := validate(schemas, file, raw); len(errs) != 0 {
+		return Fixture{}, errs
+	}
+
+	var f Fixture
+	dec := json.NewDecoder(bytes.NewReader(jsonData))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		fixtureErr := Error{File: file, Reason: err.Error()}
+		if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+			fixtureErr.Field = typeErr.Field
+			fixtureErr.Reason = fmt.Sprintf("must be of type %s", typeErr.Type)
+		}
+		return Fixture{}, []Error{fixtureErr}
+	}
+
+	return f, nil
+}
+
+// keepTimestampsAsStrings prevents dates such as 2024-01-01 from being decoded
+// as time.Time, since the schemas expect them as strings.
+func keepTimestampsAsStrings(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!timestamp" {
+		node.Tag = "!!str"
+	}
+	for _, child := range node.Content {
+		keepTimestampsAsStrings(child)
+	}
+}
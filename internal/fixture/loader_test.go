@@ -0,0 +1,207 @@
+package fixture
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+	"github.com/luikyv/go-open-insurance/internal/capitalizationtitle"
+	"github.com/luikyv/go-open-insurance/internal/consent"
+	"github.com/luikyv/go-open-insurance/internal/customer"
+	"github.com/luikyv/go-open-insurance/internal/resource"
+	"github.com/luikyv/go-open-insurance/internal/user"
+)
+
+const (
+	bobFixture = `
+  - username: bob
+    cpf: "78628584099"
+    name: Bob
+    password: pass
+    resources:
+      - resourceId: resource-1
+        type: CAPITALIZATION_TITLES
+        status: AVAILABLE
+      - resourceId: resource-2
+        type: CAPITALIZATION_TITLES
+        status: AVAILABLE
+`
+	bobWithoutResource2Fixture = `
+  - username: bob
+    cpf: "78628584099"
+    name: Bob
+    password: pass
+    resources:
+      - resourceId: resource-1
+        type: CAPITALIZATION_TITLES
+        status: AVAILABLE
+`
+	aliceFixture = `
+  - username: alice
+    cpf: "76109277673"
+    name: Alice
+    password: pass
+`
+	renamedAliceFixture = `
+  - username: alice
+    cpf: "76109277673"
+    name: Alice Smith
+    password: pass
+`
+)
+
+func TestLoader_Reload(t *testing.T) {
+	l, dir := testLoader(t)
+	ctx := context.Background()
+
+	writeFixture(t, dir, "users.yaml", "users:"+bobFixture+aliceFixture)
+	if err := l.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertResources(t, l, "bob", "resource-1", "resource-2")
+
+	// A failed login is kept only if bob is not saved again.
+	if _, err := l.userService.Authenticate(ctx, "bob", "wrong"); !errors.Is(err, user.ErrInvalidCredentials) {
+		t.Fatalf("got %v, want %v", err, user.ErrInvalidCredentials)
+	}
+
+	// Nothing changed, so nothing is loaded.
+	if err := l.Reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFixture(t, dir, "users.yaml", "users:"+bobFixture+renamedAliceFixture)
+	if err := l.Reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if u := mustUser(t, l, "alice"); u.Name != "Alice Smith" {
+		t.Errorf("got name %q, want the updated one", u.Name)
+	}
+	if u := mustUser(t, l, "bob"); u.FailedLogins != 1 {
+		t.Errorf("got %d failed logins, want bob to be skipped and keep 1", u.FailedLogins)
+	}
+
+	writeFixture(t, dir, "users.yaml", "users:"+bobWithoutResource2Fixture+renamedAliceFixture)
+	if err := l.Reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertResources(t, l, "bob", "resource-1")
+
+	writeFixture(t, dir, "users.yaml", "users:"+bobWithoutResource2Fixture)
+	if err := l.Reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.userService.User(ctx, "alice"); err == nil {
+		t.Error("alice was removed from the fixtures, but was not deleted")
+	}
+
+	if err := os.Remove(filepath.Join(dir, "users.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.userService.User(ctx, "bob"); err == nil {
+		t.Error("bob was removed from the fixtures, but was not deleted")
+	}
+	assertResources(t, l, "bob")
+}
+
+func TestLoader_LoadInvalid(t *testing.T) {
+	l, dir := testLoader(t)
+	ctx := context.Background()
+
+	writeFixture(t, dir, "alice.yaml", "users:"+aliceFixture)
+	writeFixture(t, dir, "bob.yaml", `
+users:
+  - username: bob
+    cpf: "78628584099"
+    name: Bob
+    password: pass
+    resources:
+      - resourceId: resource-1
+        type: INVALID
+        status: AVAILABLE
+`)
+
+	var errs Errors
+	if err := l.Load(ctx); !errors.As(err, &errs) {
+		t.Fatalf("got %v, want the invalid fields", err)
+	}
+	if len(errs) != 1 || errs[0].File != filepath.Join(dir, "bob.yaml") {
+		t.Errorf("got %v, want one error in bob.yaml", errs)
+	}
+	if _, err := l.userService.User(ctx, "alice"); err == nil {
+		t.Error("alice was loaded even though another file is invalid")
+	}
+}
+
+func testLoader(t *testing.T) (*Loader, string) {
+	t.Helper()
+	swagger, err := api.GetSwagger()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userService := user.NewService(user.NewMemoryStorage())
+	consentService := consent.NewService(consent.NewMemoryStorage(), userService, nil)
+	resourceService := resource.NewService(resource.NewMemoryStorage(), consentService)
+	dir := t.TempDir()
+	return NewLoader(
+		dir,
+		swagger,
+		userService,
+		customer.NewService(customer.NewMemoryStorage()),
+		resourceService,
+		capitalizationtitle.NewService(capitalizationtitle.NewMemoryStorage(), resourceService),
+	), dir
+}
+
+// writeFixture writes the file with a modification time later than the
+// previous one, so reloads notice the change.
+func writeFixture(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	modTime := time.Now()
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime().Add(time.Second)
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustUser(t *testing.T, l *Loader, username string) user.User {
+	t.Helper()
+	u, err := l.userService.User(context.Background(), username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func assertResources(t *testing.T, l *Loader, sub string, want ...string) {
+	t.Helper()
+	resources, err := l.resourceService.All(context.Background(), sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, rs := range resources {
+		got = append(got, rs.ResourceId)
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("got resources %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,52 @@
+package fixture
+
+import (
+	"time"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+)
+
+// Fixture is the content of a fixture file.
+type Fixture struct {
+	Companies []Company `json:"companies"`
+	Users     []User    `json:"users"`
+}
+
+type Company struct {
+	CNPJ string `json:"cnpj"`
+	Name string `json:"name"`
+}
+
+// User describes a user and all the data available to be shared by them.
+type User struct {
+	UserName            string              `json:"username"`
+	Email               string              `json:"email"`
+	CPF                 string              `json:"cpf"`
+	Name                string              `json:"name"`
+	CompanyCNPJs        []string            `json:"company_cnpjs,omitempty"`
+	Password            string              `json:"password"`
+	PasswordExpiresAt   *time.Time          `json:"password_expires_at,omitempty"`
+	LockedUntil         *time.Time          `json:"locked_until,omitempty"`
+	Customers           Customers           `json:"customers"`
+	Resources           []api.ResourceData  `json:"resources,omitempty"`
+	CapitalizationTitle CapitalizationTitle `json:"capitalization_title"`
+}
+
+type Customers struct {
+	Personal PersonalCustomer `json:"personal"`
+}
+
+type PersonalCustomer struct {
+	Identifications          []api.PersonalIdentificationData    `json:"identifications,omitempty"`
+	Qualifications           []api.PersonalQualificationData     `json:"qualifications,omitempty"`
+	ComplimentaryInformation []api.PersonalComplimentaryInfoData `json:"complimentary_information,omitempty"`
+}
+
+// CapitalizationTitle holds the plans of the user. The information, events
+// and settlements are indexed by plan ID.
+type CapitalizationTitle struct {
+	Plans       []api.CapitalizationTitlePlanData              `json:"plans,omitempty"`
+	PlanInfo    map[string]api.CapitalizationTitlePlanInfo     `json:"plan_info,omitempty"`
+	Events      map[string][]api.CapitalizationTitleEvent      `json:"events,omitempty"`
+	Settlements map[string][]api.CapitalizationTitleSettlement `json:"settlements,omitempty"`
+}
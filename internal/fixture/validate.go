@@ -0,0 +1,269 @@
+package fixture
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/luikyv/go-open-insurance/internal/api"
+)
+
+type schemaRuleKind int
+
+const (
+	// listOf is a list whose items follow the schema.
+	listOf schemaRuleKind = iota
+	// mapOf is an object indexed by plan ID whose values follow the schema.
+	mapOf
+	// mapOfLists is an object indexed by plan ID whose values are lists of
+	// items following the schema.
+	mapOfLists
+)
+
+// userSchemaRules maps the fields of a user fixture to the OpenAPI schemas
+// their content must follow.
+var userSchemaRules = []struct {
+	field  string
+	schema string
+	kind   schemaRuleKind
+}{
+	{"customers.personal.identifications", "PersonalIdentificationData", listOf},
+	{"customers.personal.qualifications", "PersonalQualificationData", listOf},
+	{"customers.personal.complimentary_information", "PersonalComplimentaryInfoData", listOf},
+	{"resources", "ResourceData", listOf},
+	{"capitalization_title.plans", "CapitalizationTitlePlanData", listOf},
+	{"capitalization_title.plan_info", "CapitalizationTitlePlanInfo", mapOf},
+	{"capitalization_title.events", "CapitalizationTitleEvent", mapOfLists},
+	{"capitalization_title.settlements", "CapitalizationTitleSettlement", mapOfLists},
+}
+
+// Validate checks the product data of every user in f against the OpenAPI
+// schemas. name identifies f in the errors returned.
+func Validate(schemas openapi3.Schemas, name string, f Fixture) error {
+	raw, err := toRaw(f)
+	if err != nil {
+		return err
+	}
+
+	if errs := validate(schemas, name, raw); len(errs) != 0 {
+		return Errors(errs)
+	}
+	return nil
+}
+
+// SchemaValidator validates the payloads of the admin API against the OpenAPI
+// schemas the same way the product data of the fixture files is.
+func SchemaValidator(schemas openapi3.Schemas) api.SchemaValidator {
+	return func(schema string, value any) error {
+		schemaRef := schemas[schema]
+		if schemaRef == nil || schemaRef.Value == nil {
+			return fmt.Errorf("schema %s not found", schema)
+		}
+
+		raw, err := toRaw(value)
+		if err != nil {
+			return err
+		}
+
+		var errs []Error
+		if _, ok := raw.([]any); ok {
+			errs = validateList(schemaRef.Value, "request body", "data", raw)
+		} else {
+			errs = validateValue(schemaRef.Value, "request body", "data", raw)
+		}
+		if len(errs) != 0 {
+			msgs := make([]string, len(errs))
+			for i, err := range errs {
+				msgs[i] = err.Error()
+			}
+			return api.NewError(api.ErrorCodeInvalidParameter, http.StatusUnprocessableEntity,
+				strings.Join(msgs, "; "))
+		}
+		return nil
+	}
+}
+
+// toRaw converts v into the values JSON decodes to, i.e. maps, slices,
+// strings, float64 and bools, which is what the schemas validate.
+func toRaw(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// validate checks the product data of every user in the fixture against the
+// OpenAPI schemas.
+// raw is the fixture as decoded from JSON, i.e. made of maps, slices, strings,
+// float64 and bools.
+func validate(schemas openapi3.Schemas, file string, raw any) []Error {
+	root, ok := raw.(map[string]any)
+	if !ok {
+		return []Error{{File: file, Reason: "the fixture must be an object"}}
+	}
+
+	if root["users"] == nil {
+		return nil
+	}
+
+	users, ok := root["users"].([]any)
+	if !ok {
+		return []Error{{File: file, Field: "users", Reason: "must be a list"}}
+	}
+
+	var errs []Error
+	for i, u := range users {
+		for _, rule := range userSchemaRules {
+			field := fmt.Sprintf("users[%d].%s", i, rule.field)
+			value := lookup(u, strings.Split(rule.field, "."))
+			if value == nil {
+				continue
+			}
+
+			schemaRef := schemas[rule.schema]
+			if schemaRef == nil || schemaRef.Value == nil {
+				errs = append(errs, Error{File: file, Field: field,
+					Reason: fmt.Sprintf("schema %s not found", rule.schema)})
+				continue
+			}
+			schema := schemaRef.Value
+
+			switch rule.kind {
+			case listOf:
+				errs = append(errs, validateList(schema, file, field, value)...)
+			case mapOf:
+				errs = append(errs, validateMap(file, field, value,
+					func(field string, value any) []Error {
+						return validateValue(schema, file, field, value)
+					})...)
+			case mapOfLists:
+				errs = append(errs, validateMap(file, field, value,
+					func(field string, value any) []Error {
+						return validateList(schema, file, field, value)
+					})...)
+			}
+		}
+	}
+	return errs
+}
+
+func validateList(
+	schema *openapi3.Schema,
+	file string,
+	field string,
+	value any,
+) []Error {
+	items, ok := value.([]any)
+	if !ok {
+		return []Error{{File: file, Field: field, Reason: "must be a list"}}
+	}
+
+	var errs []Error
+	for i, item := range items {
+		errs = append(errs, validateValue(schema, file, fmt.Sprintf("%s[%d]", field, i), item)...)
+	}
+	return errs
+}
+
+// validateMap calls validateEntry for each entry of the object in key order,
+// so errors are reported always in the same order.
+func validateMap(
+	file string,
+	field string,
+	value any,
+	validateEntry func(field string, value any) []Error,
+) []Error {
+	entries, ok := value.(map[string]any)
+	if !ok {
+		return []Error{{File: file, Field: field, Reason: "must be an object"}}
+	}
+
+	var keys []string
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var errs []Error
+	for _, key := range keys {
+		errs = append(errs, validateEntry(field+"."+key, entries[key])...)
+	}
+	return errs
+}
+
+func validateValue(
+	schema *openapi3.Schema,
+	file string,
+	field string,
+	value any,
+) []Error {
+	err := schema.VisitJSON(value, openapi3.MultiErrors())
+	if err == nil {
+		return nil
+	}
+
+	var errs []Error
+	for _, err := range flatten(err) {
+		var schemaErr *openapi3.SchemaError
+		if !errors.As(err, &schemaErr) {
+			errs = append(errs, Error{File: file, Field: field, Reason: err.Error()})
+			continue
+		}
+		errs = append(errs, Error{
+			File:   file,
+			Field:  field + pointerPath(schemaErr.JSONPointer()),
+			Reason: schemaErr.Reason,
+		})
+	}
+	return errs
+}
+
+// flatten expands the multi errors returned by the schema validation.
+func flatten(err error) []error {
+	var multiErr openapi3.MultiError
+	if !errors.As(err, &multiErr) {
+		return []error{err}
+	}
+
+	var errs []error
+	for _, err := range multiErr {
+		errs = append(errs, flatten(err)...)
+	}
+	return errs
+}
+
+// pointerPath renders a JSON pointer in the same notation used for the
+// fixture fields, e.g. ".contact.postalAddresses[0].townName".
+func pointerPath(pointer []string) string {
+	var path string
+	for _, p := range pointer {
+		if _, err := strconv.Atoi(p); err == nil {
+			path += "[" + p + "]"
+		} else {
+			path += "." + p
+		}
+	}
+	return path
+}
+
+func lookup(value any, keys []string) any {
+	for _, key := range keys {
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = obj[key]
+	}
+	return value
+}
@@ -0,0 +1,261 @@
+// Package generator creates synthetic users and product data from the OpenAPI
+// schemas.
+// The data generated is deterministic for a given seed.
+package generator
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/google/uuid"
+	"github.com/luikyv/go-open-insurance/internal/api"
+	"github.com/luikyv/go-open-insurance/internal/fixture"
+)
+
+const defaultPassword = "pass"
+
+// referenceDate anchors the dates generated, so the same seed generates the
+// same data regardless of when it runs.
+var referenceDate = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Options define what is generated.
+type Options struct {
+	Seed         uint64 `json:"seed"`
+	Users        int    `json:"users"`
+	PlansPerUser int    `json:"plans_per_user"`
+	// Password is set for all the users generated. If empty, defaultPassword
+	// is used.
+	Password string `json:"password"`
+}
+
+// Generate creates opts.Users users, each one representing a company and
+// holding customer data and opts.PlansPerUser capitalization title plans.
+// The documents, names and plan IDs are consistent across the data of each
+// user.
+func Generate(swagger *openapi3.T, opts Options) (fixture.Fixture, error) {
+	if opts.Password == "" {
+		opts.Password = defaultPassword
+	}
+
+	g := &generator{
+		rnd:     rand.New(rand.NewPCG(opts.Seed, opts.Seed)),
+		schemas: swagger.Components.Schemas,
+	}
+
+	var f fixture.Fixture
+	for i := range opts.Users {
+		company, u, err := g.user(i, opts)
+		if err != nil {
+			return fixture.Fixture{}, err
+		}
+		f.Companies = append(f.Companies, company)
+		f.Users = append(f.Users, u)
+	}
+
+	if err := fixture.Validate(g.schemas, "generated", f); err != nil {
+		return fixture.Fixture{}, err
+	}
+	return f, nil
+}
+
+type generator struct {
+	rnd     *rand.Rand
+	schemas openapi3.Schemas
+}
+
+func (g *generator) user(i int, opts Options) (fixture.Company, fixture.User, error) {
+	firstName := firstNames[g.rnd.IntN(len(firstNames))]
+	lastName := lastNames[g.rnd.IntN(len(lastNames))]
+	name := firstName + " " + lastName
+	email := fmt.Sprintf("%s.%s.%d@mail.com", emailName(firstName), emailName(lastName), i)
+	cpf := g.cpf()
+	company := fixture.Company{
+		CNPJ: g.cnpj(),
+		Name: lastName + " " + companySuffixes[g.rnd.IntN(len(companySuffixes))],
+	}
+
+	u := fixture.User{
+		UserName:     email,
+		Email:        email,
+		CPF:          cpf,
+		Name:         name,
+		CompanyCNPJs: []string{company.CNPJ},
+		Password:     opts.Password,
+	}
+
+	customerValues := map[string]any{
+		"cpfNumber":  cpf,
+		"civilName":  name,
+		"socialName": name,
+		"email":      email,
+		"companyInfo": map[string]any{
+			"cnpjNumber": company.CNPJ,
+			"name":       company.Name,
+		},
+	}
+	personal := &u.Customers.Personal
+	if err := g.component("PersonalIdentificationData", customerValues, &personal.Identifications); err != nil {
+		return fixture.Company{}, fixture.User{}, err
+	}
+	if err := g.component("PersonalQualificationData", customerValues, &personal.Qualifications); err != nil {
+		return fixture.Company{}, fixture.User{}, err
+	}
+	if err := g.component("PersonalComplimentaryInfoData", customerValues, &personal.ComplimentaryInformation); err != nil {
+		return fixture.Company{}, fixture.User{}, err
+	}
+
+	capTitle := &u.CapitalizationTitle
+	capTitle.PlanInfo = map[string]api.CapitalizationTitlePlanInfo{}
+	capTitle.Events = map[string][]api.CapitalizationTitleEvent{}
+	capTitle.Settlements = map[string][]api.CapitalizationTitleSettlement{}
+	for range opts.PlansPerUser {
+		planID := g.uuid()
+		insurer := lastNames[g.rnd.IntN(len(lastNames))] + " Insurance"
+		productWord := g.word()
+		capTitle.Plans = append(capTitle.Plans, api.CapitalizationTitlePlanData{
+			Brand: api.CapitalizationTitleBrand{
+				Name: insurer,
+				Companies: []api.CapitalizationTitleCompany{
+					{
+						CnpjNumber:  g.cnpj(),
+						CompanyName: insurer,
+						Products: []api.CapitalizationTitleProduct{
+							{
+								PlanId:      planID,
+								ProductName: strings.ToUpper(productWord[:1]) + productWord[1:] + " Capitalization Title",
+							},
+						},
+					},
+				},
+			},
+		})
+
+		planValues := map[string]any{
+			"planId":  planID,
+			"titleId": g.uuid(),
+		}
+		var infos []api.CapitalizationTitlePlanInfo
+		if err := g.component("CapitalizationTitlePlanInfo", planValues, &infos); err != nil {
+			return fixture.Company{}, fixture.User{}, err
+		}
+		capTitle.PlanInfo[planID] = infos[0]
+
+		var events []api.CapitalizationTitleEvent
+		if err := g.component("CapitalizationTitleEvent", planValues, &events); err != nil {
+			return fixture.Company{}, fixture.User{}, err
+		}
+		capTitle.Events[planID] = events
+
+		var settlements []api.CapitalizationTitleSettlement
+		if err := g.component("CapitalizationTitleSettlement", planValues, &settlements); err != nil {
+			return fixture.Company{}, fixture.User{}, err
+		}
+		capTitle.Settlements[planID] = settlements
+	}
+
+	return company, u, nil
+}
+
+// component generates between one and maxItems records following the schema
+// and decodes them into records, which must point to a slice.
+// The properties present in values are replaced wherever they appear in the
+// records to keep the data consistent.
+func (g *generator) component(schemaName string, values map[string]any, records any) error {
+	schemaRef := g.schemas[schemaName]
+	if schemaRef == nil || schemaRef.Value == nil {
+		return fmt.Errorf("schema %s not found", schemaName)
+	}
+
+	var raw []any
+	for range 1 + g.rnd.IntN(maxItems) {
+		raw = append(raw, replaceValues(g.value("", schemaRef.Value, 0), values))
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, records)
+}
+
+// replaceValues sets the properties of v present in values, in any nesting
+// level. Properties absent from v are not added.
+func replaceValues(v any, values map[string]any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, item := range v {
+			if value, ok := values[k]; ok {
+				v[k] = value
+			} else {
+				v[k] = replaceValues(item, values)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = replaceValues(item, values)
+		}
+	}
+	return v
+}
+
+func (g *generator) cpf() string {
+	var digits [9]int
+	for {
+		for i := range digits {
+			digits[i] = g.rnd.IntN(10)
+		}
+		if cpf := api.NewCPF(digits); api.ValidateCPF("cpf", cpf) == nil {
+			return cpf
+		}
+	}
+}
+
+func (g *generator) cnpj() string {
+	var digits [12]int
+	for {
+		// The last four digits identify the branch and are usually 0001 for
+		// the headquarters.
+		for i := range 8 {
+			digits[i] = g.rnd.IntN(10)
+		}
+		digits[11] = 1
+		if cnpj := api.NewCNPJ(digits); api.ValidateCNPJ("cnpj", cnpj) == nil {
+			return cnpj
+		}
+	}
+}
+
+// uuid generates a version 4 UUID from the seeded source.
+func (g *generator) uuid() string {
+	var id uuid.UUID
+	binary.BigEndian.PutUint64(id[:8], g.rnd.Uint64())
+	binary.BigEndian.PutUint64(id[8:], g.rnd.Uint64())
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return id.String()
+}
+
+// emailName removes the accents and capital letters from name so it can be
+// used in email addresses.
+func emailName(name string) string {
+	return accentReplacer.Replace(strings.ToLower(name))
+}
+
+var accentReplacer = strings.NewReplacer("á", "a", "ã", "a", "é", "e", "í", "i", "ó", "o", "ô", "o", "ú", "u", "ç", "c")
+
+var firstNames = []string{
+	"Ana", "Bruno", "Carla", "Diego", "Eduarda", "Felipe", "Gabriela", "Henrique",
+	"Isabela", "João", "Larissa", "Marcos", "Natália", "Otávio", "Paula", "Rafael",
+}
+
+var lastNames = []string{
+	"Almeida", "Barbosa", "Cardoso", "Costa", "Ferreira", "Gomes", "Lima", "Martins",
+	"Oliveira", "Pereira", "Ribeiro", "Rocha", "Santos", "Silva", "Souza", "Teixeira",
+}
+
+var companySuffixes = []string{"Ltda", "S.A.", "Comércio", "Serviços", "Tecnologia"}
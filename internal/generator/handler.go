@@ -0,0 +1,70 @@
+package generator
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/luikyv/go-open-insurance/internal/api"
+	"github.com/luikyv/go-open-insurance/internal/fixture"
+)
+
+const (
+	maxUsers        = 1000
+	maxPlansPerUser = 100
+)
+
+// RegisterAdminHandlers adds the endpoint to generate synthetic data and load
+// it into MockIn under prefix.
+func RegisterAdminHandlers(
+	mux *http.ServeMux,
+	prefix string,
+	swagger *openapi3.T,
+	loader *fixture.Loader,
+) {
+	mux.HandleFunc("POST "+prefix+"/generate", generateHandler(swagger, loader))
+}
+
+// generateHandler loads the data generated and returns it in the fixture
+// format, so it can be saved and loaded again later.
+func generateHandler(swagger *openapi3.T, loader *fixture.Loader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var opts Options
+		if err := api.DecodeJSON(r, &opts); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		if err := validateOptions(opts); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		f, err := Generate(swagger, opts)
+		if err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		if err := loader.Apply(r.Context(), fmt.Sprintf("generated with seed %d", opts.Seed), f); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		api.WriteJSON(w, http.StatusCreated, f)
+	}
+}
+
+func validateOptions(opts Options) error {
+	if opts.Users < 1 || opts.Users > maxUsers {
+		return api.NewError("NAO_INFORMADO", http.StatusUnprocessableEntity,
+			fmt.Sprintf("users must be between 1 and %d", maxUsers))
+	}
+
+	if opts.PlansPerUser < 0 || opts.PlansPerUser > maxPlansPerUser {
+		return api.NewError("NAO_INFORMADO", http.StatusUnprocessableEntity,
+			fmt.Sprintf("plans_per_user must be between 0 and %d", maxPlansPerUser))
+	}
+
+	return nil
+}
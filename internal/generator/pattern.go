@@ -0,0 +1,104 @@
+package generator
+
+import (
+	"errors"
+	"regexp/syntax"
+	"strings"
+)
+
+// maxRepetitions limits how many times unbounded repetitions, e.g. \d+, are
+// repeated.
+const maxRepetitions = 3
+
+var errUnsupportedPattern = errors.New("unsupported pattern")
+
+// fromPattern generates a string matching the regular expression.
+// Only printable ASCII characters are generated.
+func (g *generator) fromPattern(pattern string) (string, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := g.writeRegexp(&b, re.Simplify()); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func (g *generator) writeRegexp(b *strings.Builder, re *syntax.Regexp) error {
+	switch re.Op {
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine,
+		syntax.OpBeginText, syntax.OpEndText, syntax.OpWordBoundary,
+		syntax.OpNoWordBoundary:
+		return nil
+	case syntax.OpLiteral:
+		b.WriteString(string(re.Rune))
+		return nil
+	case syntax.OpCharClass:
+		r, ok := g.runeFromClass(re.Rune)
+		if !ok {
+			return errUnsupportedPattern
+		}
+		b.WriteRune(r)
+		return nil
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		b.WriteRune(rune('a' + g.rnd.IntN(26)))
+		return nil
+	case syntax.OpCapture:
+		return g.writeRegexp(b, re.Sub[0])
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if err := g.writeRegexp(b, sub); err != nil {
+				return err
+			}
+		}
+		return nil
+	case syntax.OpAlternate:
+		return g.writeRegexp(b, re.Sub[g.rnd.IntN(len(re.Sub))])
+	case syntax.OpStar:
+		return g.repeat(b, re.Sub[0], 0, maxRepetitions)
+	case syntax.OpPlus:
+		return g.repeat(b, re.Sub[0], 1, maxRepetitions)
+	case syntax.OpQuest:
+		return g.repeat(b, re.Sub[0], 0, 1)
+	case syntax.OpRepeat:
+		max := re.Max
+		if max == -1 {
+			max = re.Min + maxRepetitions
+		}
+		return g.repeat(b, re.Sub[0], re.Min, max)
+	default:
+		return errUnsupportedPattern
+	}
+}
+
+func (g *generator) repeat(b *strings.Builder, re *syntax.Regexp, min, max int) error {
+	for range min + g.rnd.IntN(max-min+1) {
+		if err := g.writeRegexp(b, re); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runeFromClass picks a printable ASCII character from the class, which is
+// informed as pairs of inclusive ranges.
+func (g *generator) runeFromClass(ranges []rune) (rune, bool) {
+	type runeRange struct{ lo, hi rune }
+	var printable []runeRange
+	for i := 0; i+1 < len(ranges); i += 2 {
+		lo, hi := max(ranges[i], ' '), min(ranges[i+1], '~')
+		if lo <= hi {
+			printable = append(printable, runeRange{lo, hi})
+		}
+	}
+
+	if len(printable) == 0 {
+		return 0, false
+	}
+
+	r := printable[g.rnd.IntN(len(printable))]
+	return r.lo + rune(g.rnd.IntN(int(r.hi-r.lo+1))), true
+}
@@ -0,0 +1,226 @@
+package generator
+
+import (
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+const (
+	// maxDepth limits how deep optional properties are generated.
+	maxDepth = 6
+	// maxItems limits the number of items generated for arrays.
+	maxItems = 3
+	// maxPatternAttempts limits how many times a string is generated from a
+	// pattern before giving up on it.
+	maxPatternAttempts = 10
+)
+
+// knownValues fixes the value of properties that would be hard to read if
+// generated from their patterns.
+var knownValues = map[string]any{
+	"currency": "BRL",
+	"country":  "BRA",
+}
+
+// value generates a value that follows the schema.
+// Required properties are always generated, the optional ones only sometimes.
+func (g *generator) value(name string, schema *openapi3.Schema, depth int) any {
+	if v, ok := knownValues[name]; ok && schema.VisitJSON(v) == nil {
+		return v
+	}
+
+	if len(schema.AllOf) != 0 {
+		merged := map[string]any{}
+		for _, ref := range schema.AllOf {
+			if obj, ok := g.value(name, ref.Value, depth).(map[string]any); ok {
+				for k, v := range obj {
+					merged[k] = v
+				}
+			}
+		}
+		return merged
+	}
+
+	if refs := slices.Concat(schema.OneOf, schema.AnyOf); len(refs) != 0 {
+		return g.value(name, refs[g.rnd.IntN(len(refs))].Value, depth)
+	}
+
+	if len(schema.Enum) != 0 {
+		return schema.Enum[g.rnd.IntN(len(schema.Enum))]
+	}
+
+	switch {
+	case schema.Type.Is(openapi3.TypeObject) || len(schema.Properties) != 0:
+		return g.object(schema, depth)
+	case schema.Type.Is(openapi3.TypeArray):
+		return g.array(name, schema, depth)
+	case schema.Type.Is(openapi3.TypeNumber):
+		if n, ok := g.numberFromPattern(schema); ok {
+			return n
+		}
+		return g.number(schema, 100, 10000)
+	case schema.Type.Is(openapi3.TypeInteger):
+		return math.Trunc(g.number(schema, 1, 100))
+	case schema.Type.Is(openapi3.TypeBoolean):
+		return g.rnd.IntN(2) == 0
+	default:
+		return g.string(schema)
+	}
+}
+
+func (g *generator) object(schema *openapi3.Schema, depth int) map[string]any {
+	var names []string
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	// Iterate in a fixed order so the same seed generates the same data.
+	slices.Sort(names)
+
+	obj := map[string]any{}
+	for _, name := range names {
+		isRequired := slices.Contains(schema.Required, name)
+		if !isRequired && (depth >= maxDepth || g.rnd.IntN(2) == 0) {
+			continue
+		}
+		obj[name] = g.value(name, schema.Properties[name].Value, depth+1)
+	}
+	return obj
+}
+
+func (g *generator) array(name string, schema *openapi3.Schema, depth int) []any {
+	min, max := int(schema.MinItems), maxItems
+	if min == 0 {
+		min = 1
+	}
+	if schema.MaxItems != nil && int(*schema.MaxItems) < max {
+		max = int(*schema.MaxItems)
+	}
+	if max < min {
+		max = min
+	}
+
+	items := []any{}
+	for range min + g.rnd.IntN(max-min+1) {
+		items = append(items, g.value(name, schema.Items.Value, depth+1))
+	}
+	return items
+}
+
+// number generates a number with two decimal places between the schema
+// limits or between min and max if the schema has none.
+func (g *generator) number(schema *openapi3.Schema, min, max float64) float64 {
+	if schema.Min != nil {
+		min = *schema.Min
+	}
+	if schema.Max != nil {
+		max = *schema.Max
+	}
+	if max < min {
+		max = min
+	}
+
+	return math.Round((min+g.rnd.Float64()*(max-min))*100) / 100
+}
+
+// numberFromPattern generates a number following the pattern of the schema,
+// e.g. percentages with at most three integer digits.
+func (g *generator) numberFromPattern(schema *openapi3.Schema) (float64, bool) {
+	if schema.Pattern == "" {
+		return 0, false
+	}
+
+	s, err := g.fromPattern(schema.Pattern)
+	if err != nil {
+		return 0, false
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || schema.VisitJSON(n) != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+func (g *generator) string(schema *openapi3.Schema) string {
+	switch schema.Format {
+	case "date":
+		return g.date().Format(time.DateOnly)
+	case "date-time":
+		return g.date().Add(time.Duration(g.rnd.IntN(86400)) * time.Second).Format(time.RFC3339)
+	case "uri":
+		return "https://mockin.local/" + g.word()
+	}
+
+	// Prefer readable words when the pattern accepts them.
+	if s := g.text(schema); isValidString(schema, s) {
+		return s
+	}
+
+	if schema.Pattern != "" {
+		for range maxPatternAttempts {
+			if s, err := g.fromPattern(schema.Pattern); err == nil && isValidString(schema, s) {
+				return s
+			}
+		}
+	}
+
+	if example, ok := schema.Example.(string); ok {
+		return example
+	}
+	return g.text(schema)
+}
+
+// text generates words respecting the length limits of the schema.
+func (g *generator) text(schema *openapi3.Schema) string {
+	s := g.word()
+	for range g.rnd.IntN(3) {
+		s += " " + g.word()
+	}
+
+	if schema.MaxLength != nil && uint64(len(s)) > *schema.MaxLength {
+		s = strings.TrimSpace(s[:*schema.MaxLength])
+	}
+	for uint64(len(s)) < schema.MinLength {
+		s += "x"
+	}
+	return s
+}
+
+func isValidString(schema *openapi3.Schema, s string) bool {
+	if uint64(len([]rune(s))) < schema.MinLength {
+		return false
+	}
+
+	if schema.MaxLength != nil && uint64(len([]rune(s))) > *schema.MaxLength {
+		return false
+	}
+
+	if schema.Pattern == "" {
+		return true
+	}
+
+	re, err := regexp.Compile(schema.Pattern)
+	return err == nil && re.MatchString(s)
+}
+
+// date returns a day in the year before the reference date.
+func (g *generator) date() time.Time {
+	return referenceDate.AddDate(0, 0, -g.rnd.IntN(365))
+}
+
+func (g *generator) word() string {
+	return words[g.rnd.IntN(len(words))]
+}
+
+var words = []string{
+	"alpha", "amber", "anchor", "aurora", "beacon", "breeze", "canyon", "cedar",
+	"coral", "delta", "ember", "falcon", "forest", "harbor", "horizon", "island",
+	"jasper", "lagoon", "maple", "meadow", "nebula", "ocean", "orchid", "pioneer",
+	"prairie", "quartz", "river", "sierra", "summit", "thunder", "valley", "willow",
+}
@@ -0,0 +1,170 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+	"github.com/luikyv/go-open-insurance/internal/consent"
+	"github.com/luikyv/go-open-insurance/internal/resource"
+	"github.com/luikyv/go-open-insurance/internal/user"
+)
+
+// ClientAttrAutoApproveUsername is the client attribute that makes consents
+// be authorized automatically on behalf of the user informed, so the
+// authorization flow can be completed without a browser.
+const ClientAttrAutoApproveUsername = "auto_approve_username"
+
+// approver authorizes consents on behalf of users without them going through
+// the login and consent pages.
+type approver struct {
+	userService     user.Service
+	consentService  consent.Service
+	resourceService resource.Service
+}
+
+// approval is what is granted when authorizing a consent.
+type approval struct {
+	permissions []api.ConsentPermission
+	resourceIDs []string
+}
+
+// approval validates the user can authorize the consent and resolves what will
+// be granted.
+// If permissions is empty, all the permissions requested are granted. If
+// resourceIDs is empty, all the user's resources covered by the permissions
+// are shared.
+func (ap approver) approval(
+	ctx context.Context,
+	c consent.Consent,
+	username string,
+	permissions []api.ConsentPermission,
+	resourceIDs []string,
+) (
+	approval,
+	error,
+) {
+	u, err := ap.userService.User(ctx, username)
+	if err != nil {
+		return approval{}, err
+	}
+
+	if u.CPF != c.UserCPF {
+		return approval{}, api.NewError("NAO_INFORMADO", http.StatusUnprocessableEntity,
+			"the user is not the one informed in the consent")
+	}
+
+	if c.BusinessCNPJ != "" && !u.IsRepresentative(c.BusinessCNPJ) {
+		return approval{}, api.NewError("NAO_INFORMADO", http.StatusUnprocessableEntity,
+			"the user is not a representative of the business entity")
+	}
+
+	if len(permissions) == 0 {
+		permissions = c.Permissions
+	}
+
+	consentableResources, err := ap.resourceService.ConsentableResources(ctx, username, permissions)
+	if err != nil {
+		return approval{}, err
+	}
+
+	var grantedResourceIDs []string
+	for _, rs := range consentableResources {
+		if len(resourceIDs) == 0 || slices.Contains(resourceIDs, rs.ResourceId) {
+			grantedResourceIDs = append(grantedResourceIDs, rs.ResourceId)
+		}
+	}
+
+	return approval{
+		permissions: permissions,
+		resourceIDs: grantedResourceIDs,
+	}, nil
+}
+
+// RegisterAdminHandlers adds the endpoints to approve and reject consents under
+// prefix.
+func RegisterAdminHandlers(
+	mux *http.ServeMux,
+	prefix string,
+	userService user.Service,
+	consentService consent.Service,
+	resourceService resource.Service,
+) {
+	ap := approver{
+		userService:     userService,
+		consentService:  consentService,
+		resourceService: resourceService,
+	}
+	mux.HandleFunc("POST "+prefix+"/consents/{id}/approve", approveConsentHandler(ap))
+	mux.HandleFunc("POST "+prefix+"/consents/{id}/reject", rejectConsentHandler(consentService))
+}
+
+type approveConsentRequest struct {
+	Username    string                  `json:"username"`
+	Permissions []api.ConsentPermission `json:"permissions"`
+	ResourceIDs []string                `json:"resource_ids"`
+}
+
+// approveConsentHandler pre approves a consent awaiting authorization so the
+// next authorization request for it completes without user interaction.
+func approveConsentHandler(ap approver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req approveConsentRequest
+		if err := api.DecodeJSON(r, &req); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		consentID := r.PathValue("id")
+		c, err := ap.consentService.Consent(r.Context(), consentID)
+		if err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		approval, err := ap.approval(r.Context(), c, req.Username, req.Permissions, req.ResourceIDs)
+		if err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		if err := ap.consentService.PreApprove(
+			r.Context(),
+			consentID,
+			req.Username,
+			approval.permissions,
+			approval.resourceIDs,
+		); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func rejectConsentHandler(consentService consent.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, err := consentService.Consent(r.Context(), r.PathValue("id"))
+		if err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		if err := consentService.Reject(
+			r.Context(),
+			api.RequestMeta{ClientID: c.ClientId},
+			c.ID,
+			consent.RejectionInfo{
+				RejectedBy: api.ConsentRejectedByUSER,
+				Reason:     api.ConsentRejectedReasonCodeCUSTOMERMANUALLYREJECTED,
+			},
+		); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -3,6 +3,7 @@ package oidc
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/luikyv/go-oidc/pkg/goidc"
 	"go.mongodb.org/mongo-driver/bson"
@@ -10,17 +11,26 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-type AuthnSessionManager struct {
+// AuthnSessionManager stores the sessions of the authorization flows in
+// progress.
+type AuthnSessionManager interface {
+	goidc.AuthnSessionManager
+	// DeleteExpired removes all the sessions that reached their expiration.
+	DeleteExpired(ctx context.Context) error
+}
+
+// MongoAuthnSessionManager keeps the authn sessions in a mongo collection.
+type MongoAuthnSessionManager struct {
 	Collection *mongo.Collection
 }
 
-func NewAuthnSessionManager(database *mongo.Database) AuthnSessionManager {
-	return AuthnSessionManager{
+func NewMongoAuthnSessionManager(database *mongo.Database) MongoAuthnSessionManager {
+	return MongoAuthnSessionManager{
 		Collection: database.Collection("authentication_sessions"),
 	}
 }
 
-func (manager AuthnSessionManager) Save(
+func (manager MongoAuthnSessionManager) Save(
 	ctx context.Context,
 	session *goidc.AuthnSession,
 ) error {
@@ -29,7 +39,7 @@ func (manager AuthnSessionManager) Save(
 	if _, err := manager.Collection.ReplaceOne(
 		ctx,
 		filter,
-		session,
+		newAuthnSessionDocument(session),
 		&options.ReplaceOptions{Upsert: &shouldUpsert},
 	); err != nil {
 		return err
@@ -38,7 +48,7 @@ func (manager AuthnSessionManager) Save(
 	return nil
 }
 
-func (manager AuthnSessionManager) SessionByCallbackID(
+func (manager MongoAuthnSessionManager) SessionByCallbackID(
 	ctx context.Context,
 	callbackID string,
 ) (
@@ -51,7 +61,7 @@ func (manager AuthnSessionManager) SessionByCallbackID(
 	)
 }
 
-func (manager AuthnSessionManager) SessionByAuthCode(
+func (manager MongoAuthnSessionManager) SessionByAuthCode(
 	ctx context.Context,
 	authorizationCode string,
 ) (
@@ -64,7 +74,7 @@ func (manager AuthnSessionManager) SessionByAuthCode(
 	)
 }
 
-func (manager AuthnSessionManager) SessionByPushedAuthReqID(
+func (manager MongoAuthnSessionManager) SessionByPushedAuthReqID(
 	ctx context.Context,
 	id string,
 ) (
@@ -74,7 +84,7 @@ func (manager AuthnSessionManager) SessionByPushedAuthReqID(
 	return manager.getWithFilter(ctx, bson.D{{Key: "pushed_auth_req_id", Value: id}})
 }
 
-func (manager AuthnSessionManager) SessionByCIBAAuthID(
+func (manager MongoAuthnSessionManager) SessionByCIBAAuthID(
 	ctx context.Context,
 	id string,
 ) (
@@ -84,7 +94,7 @@ func (manager AuthnSessionManager) SessionByCIBAAuthID(
 	return nil, errors.ErrUnsupported
 }
 
-func (manager AuthnSessionManager) Delete(
+func (manager MongoAuthnSessionManager) Delete(
 	ctx context.Context,
 	id string,
 ) error {
@@ -96,7 +106,7 @@ func (manager AuthnSessionManager) Delete(
 	return nil
 }
 
-func (manager AuthnSessionManager) getWithFilter(
+func (manager MongoAuthnSessionManager) getWithFilter(
 	ctx context.Context,
 	filter any,
 ) (
@@ -116,3 +126,38 @@ func (manager AuthnSessionManager) getWithFilter(
 
 	return &authnSession, nil
 }
+
+// CreateIndexes creates a TTL index so mongo removes the sessions once they
+// expire.
+func (manager MongoAuthnSessionManager) CreateIndexes(ctx context.Context) error {
+	_, err := manager.Collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at_date", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+func (manager MongoAuthnSessionManager) DeleteExpired(ctx context.Context) error {
+	filter := bson.D{{Key: "expires_at", Value: bson.D{
+		{Key: "$lt", Value: time.Now().Unix()},
+	}}}
+	if _, err := manager.Collection.DeleteMany(ctx, filter); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// authnSessionDocument is how a session is persisted. The expiration is also
+// stored as a date, since that is what mongo TTL indexes work with.
+type authnSessionDocument struct {
+	goidc.AuthnSession `bson:",inline"`
+	ExpiresAt          time.Time `bson:"expires_at_date"`
+}
+
+func newAuthnSessionDocument(session *goidc.AuthnSession) authnSessionDocument {
+	return authnSessionDocument{
+		AuthnSession: *session,
+		ExpiresAt:    time.Unix(int64(session.ExpiresAtTimestamp), 0).UTC(),
+	}
+}
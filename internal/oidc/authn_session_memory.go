@@ -0,0 +1,134 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+var errAuthnSessionNotFound = errors.New("authn session not found")
+
+// MemoryAuthnSessionManager keeps the authn sessions in memory, so they are
+// lost when the server stops.
+type MemoryAuthnSessionManager struct {
+	mu       sync.RWMutex
+	sessions map[string]goidc.AuthnSession
+}
+
+func NewMemoryAuthnSessionManager() *MemoryAuthnSessionManager {
+	return &MemoryAuthnSessionManager{
+		sessions: make(map[string]goidc.AuthnSession),
+	}
+}
+
+func (manager *MemoryAuthnSessionManager) Save(
+	_ context.Context,
+	session *goidc.AuthnSession,
+) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.sessions[session.ID] = cloneAuthnSession(*session)
+	return nil
+}
+
+func (manager *MemoryAuthnSessionManager) SessionByCallbackID(
+	_ context.Context,
+	callbackID string,
+) (
+	*goidc.AuthnSession,
+	error,
+) {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+	return manager.find(func(s goidc.AuthnSession) bool {
+		return s.CallbackID == callbackID
+	})
+}
+
+func (manager *MemoryAuthnSessionManager) SessionByAuthCode(
+	_ context.Context,
+	authorizationCode string,
+) (
+	*goidc.AuthnSession,
+	error,
+) {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+	return manager.find(func(s goidc.AuthnSession) bool {
+		return s.AuthCode == authorizationCode
+	})
+}
+
+func (manager *MemoryAuthnSessionManager) SessionByPushedAuthReqID(
+	_ context.Context,
+	id string,
+) (
+	*goidc.AuthnSession,
+	error,
+) {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+	return manager.find(func(s goidc.AuthnSession) bool {
+		return s.PushedAuthReqID == id
+	})
+}
+
+func (manager *MemoryAuthnSessionManager) SessionByCIBAAuthID(
+	_ context.Context,
+	_ string,
+) (
+	*goidc.AuthnSession,
+	error,
+) {
+	return nil, errors.ErrUnsupported
+}
+
+func (manager *MemoryAuthnSessionManager) Delete(_ context.Context, id string) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	delete(manager.sessions, id)
+	return nil
+}
+
+func (manager *MemoryAuthnSessionManager) DeleteExpired(_ context.Context) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	for id, session := range manager.sessions {
+		if session.IsExpired() {
+			delete(manager.sessions, id)
+		}
+	}
+
+	return nil
+}
+
+// find must be called with the lock held.
+func (manager *MemoryAuthnSessionManager) find(
+	matches func(goidc.AuthnSession) bool,
+) (
+	*goidc.AuthnSession,
+	error,
+) {
+	for _, session := range manager.sessions {
+		if matches(session) {
+			session = cloneAuthnSession(session)
+			return &session, nil
+		}
+	}
+
+	return nil, errAuthnSessionNotFound
+}
+
+// cloneAuthnSession copies the maps of the session so the one stored is not
+// modified through the ones returned.
+func cloneAuthnSession(session goidc.AuthnSession) goidc.AuthnSession {
+	session.Storage = maps.Clone(session.Storage)
+	session.AdditionalTokenClaims = maps.Clone(session.AdditionalTokenClaims)
+	session.AdditionalIDTokenClaims = maps.Clone(session.AdditionalIDTokenClaims)
+	session.AdditionalUserInfoClaims = maps.Clone(session.AdditionalUserInfoClaims)
+	session.IDTokenHintClaims = maps.Clone(session.IDTokenHintClaims)
+	return session
+}
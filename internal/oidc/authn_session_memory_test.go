@@ -0,0 +1,44 @@
+package oidc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-open-insurance/internal/storagetest"
+)
+
+// TestMemoryAuthnSessionManager_Concurrent modifies the maps of the same
+// session fetched at the same time, so the race detector reports if they are
+// shared with the session stored.
+func TestMemoryAuthnSessionManager_Concurrent(t *testing.T) {
+	manager := NewMemoryAuthnSessionManager()
+	ctx := context.Background()
+	session := &goidc.AuthnSession{ID: "session", CallbackID: "callback", Storage: map[string]any{"step": 1}}
+	if err := manager.Save(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+	session.Storage["step"] = 2
+
+	errs := storagetest.Concurrently(50, func() error {
+		stored, err := manager.SessionByCallbackID(ctx, "callback")
+		if err != nil {
+			return err
+		}
+		stored.Storage["step"] = 3
+		return nil
+	})
+	for _, err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	stored, err := manager.SessionByCallbackID(ctx, "callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Storage["step"] != 1 {
+		t.Errorf("got step %v, want the session stored to be kept as 1", stored.Storage["step"])
+	}
+}
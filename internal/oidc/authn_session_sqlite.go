@@ -0,0 +1,176 @@
+package oidc
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-open-insurance/internal/api"
+)
+
+// SQLiteAuthnSessionManager keeps the authn sessions in a SQLite table.
+type SQLiteAuthnSessionManager struct {
+	db *sql.DB
+}
+
+func NewSQLiteAuthnSessionManager(db *sql.DB) SQLiteAuthnSessionManager {
+	return SQLiteAuthnSessionManager{
+		db: db,
+	}
+}
+
+// CreateTables creates the authn sessions table if it doesn't exist yet.
+func (manager SQLiteAuthnSessionManager) CreateTables(ctx context.Context) error {
+	return api.SQLiteExec(
+		ctx,
+		manager.db,
+		`CREATE TABLE IF NOT EXISTS authentication_sessions (
+			id TEXT PRIMARY KEY,
+			callback_id TEXT,
+			auth_code TEXT,
+			pushed_auth_req_id TEXT,
+			expires_at INTEGER NOT NULL,
+			data TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS authentication_sessions_callback_id
+			ON authentication_sessions (callback_id)`,
+		`CREATE INDEX IF NOT EXISTS authentication_sessions_auth_code
+			ON authentication_sessions (auth_code)`,
+		`CREATE INDEX IF NOT EXISTS authentication_sessions_pushed_auth_req_id
+			ON authentication_sessions (pushed_auth_req_id)`,
+	)
+}
+
+func (manager SQLiteAuthnSessionManager) Save(
+	ctx context.Context,
+	session *goidc.AuthnSession,
+) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	_, err = manager.db.ExecContext(
+		ctx,
+		`INSERT INTO authentication_sessions
+			(id, callback_id, auth_code, pushed_auth_req_id, expires_at, data)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			callback_id = excluded.callback_id,
+			auth_code = excluded.auth_code,
+			pushed_auth_req_id = excluded.pushed_auth_req_id,
+			expires_at = excluded.expires_at,
+			data = excluded.data`,
+		session.ID,
+		nullIfEmpty(session.CallbackID),
+		nullIfEmpty(session.AuthCode),
+		nullIfEmpty(session.PushedAuthReqID),
+		session.ExpiresAtTimestamp,
+		data,
+	)
+	return err
+}
+
+func (manager SQLiteAuthnSessionManager) SessionByCallbackID(
+	ctx context.Context,
+	callbackID string,
+) (
+	*goidc.AuthnSession,
+	error,
+) {
+	return manager.getWithFilter(ctx, "callback_id", callbackID)
+}
+
+func (manager SQLiteAuthnSessionManager) SessionByAuthCode(
+	ctx context.Context,
+	authorizationCode string,
+) (
+	*goidc.AuthnSession,
+	error,
+) {
+	return manager.getWithFilter(ctx, "auth_code", authorizationCode)
+}
+
+func (manager SQLiteAuthnSessionManager) SessionByPushedAuthReqID(
+	ctx context.Context,
+	id string,
+) (
+	*goidc.AuthnSession,
+	error,
+) {
+	return manager.getWithFilter(ctx, "pushed_auth_req_id", id)
+}
+
+func (manager SQLiteAuthnSessionManager) SessionByCIBAAuthID(
+	_ context.Context,
+	_ string,
+) (
+	*goidc.AuthnSession,
+	error,
+) {
+	return nil, errors.ErrUnsupported
+}
+
+func (manager SQLiteAuthnSessionManager) Delete(ctx context.Context, id string) error {
+	_, err := manager.db.ExecContext(ctx, `DELETE FROM authentication_sessions WHERE id = ?`, id)
+	return err
+}
+
+func (manager SQLiteAuthnSessionManager) DeleteExpired(ctx context.Context) error {
+	_, err := manager.db.ExecContext(
+		ctx,
+		`DELETE FROM authentication_sessions WHERE expires_at < ?`,
+		time.Now().Unix(),
+	)
+	return err
+}
+
+// getWithFilter returns the session whose column matches the value. The
+// column must be one of the lookup columns of the table.
+func (manager SQLiteAuthnSessionManager) getWithFilter(
+	ctx context.Context,
+	column string,
+	value string,
+) (
+	*goidc.AuthnSession,
+	error,
+) {
+	session, err := api.SQLiteFindOne[goidc.AuthnSession](
+		ctx,
+		manager.db,
+		`SELECT data FROM authentication_sessions WHERE `+column+` = ?`,
+		value,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errAuthnSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	restoreIntegers(session.Storage)
+	return &session, nil
+}
+
+// restoreIntegers converts back to int64 the whole numbers that JSON decoded
+// as float64, since the values kept in the session stores are read with type
+// assertions.
+func restoreIntegers(store map[string]any) {
+	for k, v := range store {
+		if f, ok := v.(float64); ok && f == math.Trunc(f) {
+			store[k] = int64(f)
+		}
+	}
+}
+
+// nullIfEmpty stores empty lookup values as NULL so they never match a query.
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -4,22 +4,34 @@ import (
 	"context"
 
 	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-open-insurance/internal/api"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-type ClientManager struct {
+// ClientManager stores the clients registered dynamically. The static clients
+// are not stored.
+type ClientManager interface {
+	goidc.ClientManager
+	// All returns all the clients stored.
+	All(ctx context.Context) ([]*goidc.Client, error)
+	// ReplaceAll replaces all the clients stored by the ones informed.
+	ReplaceAll(ctx context.Context, clients []*goidc.Client) error
+}
+
+// MongoClientManager keeps the clients in a mongo collection.
+type MongoClientManager struct {
 	Collection *mongo.Collection
 }
 
-func NewClientManager(database *mongo.Database) ClientManager {
-	return ClientManager{
+func NewMongoClientManager(database *mongo.Database) MongoClientManager {
+	return MongoClientManager{
 		Collection: database.Collection("clients"),
 	}
 }
 
-func (manager ClientManager) Save(
+func (manager MongoClientManager) Save(
 	ctx context.Context,
 	client *goidc.Client,
 ) error {
@@ -32,7 +44,7 @@ func (manager ClientManager) Save(
 	return nil
 }
 
-func (manager ClientManager) Client(ctx context.Context, id string) (*goidc.Client, error) {
+func (manager MongoClientManager) Client(ctx context.Context, id string) (*goidc.Client, error) {
 	filter := bson.D{{Key: "_id", Value: id}}
 
 	result := manager.Collection.FindOne(ctx, filter)
@@ -48,7 +60,7 @@ func (manager ClientManager) Client(ctx context.Context, id string) (*goidc.Clie
 	return &client, nil
 }
 
-func (manager ClientManager) Delete(ctx context.Context, id string) error {
+func (manager MongoClientManager) Delete(ctx context.Context, id string) error {
 	filter := bson.D{{Key: "_id", Value: id}}
 	if _, err := manager.Collection.DeleteOne(ctx, filter); err != nil {
 		return err
@@ -56,3 +68,11 @@ func (manager ClientManager) Delete(ctx context.Context, id string) error {
 
 	return nil
 }
+
+func (manager MongoClientManager) All(ctx context.Context) ([]*goidc.Client, error) {
+	return api.FindAll[*goidc.Client](ctx, manager.Collection)
+}
+
+func (manager MongoClientManager) ReplaceAll(ctx context.Context, clients []*goidc.Client) error {
+	return api.ReplaceAll(ctx, manager.Collection, clients)
+}
@@ -0,0 +1,88 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+var errClientNotFound = errors.New("client not found")
+
+// MemoryClientManager keeps the clients in memory, so they are lost when the
+// server stops.
+// The clients are kept in the order they were registered.
+type MemoryClientManager struct {
+	mu      sync.RWMutex
+	clients []goidc.Client
+}
+
+func NewMemoryClientManager() *MemoryClientManager {
+	return &MemoryClientManager{}
+}
+
+func (manager *MemoryClientManager) Save(_ context.Context, client *goidc.Client) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	i := manager.index(client.ID)
+	if i == -1 {
+		manager.clients = append(manager.clients, *client)
+		return nil
+	}
+
+	manager.clients[i] = *client
+	return nil
+}
+
+func (manager *MemoryClientManager) Client(_ context.Context, id string) (*goidc.Client, error) {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+	i := manager.index(id)
+	if i == -1 {
+		return nil, errClientNotFound
+	}
+
+	client := manager.clients[i]
+	return &client, nil
+}
+
+func (manager *MemoryClientManager) Delete(_ context.Context, id string) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	if i := manager.index(id); i != -1 {
+		manager.clients = slices.Delete(manager.clients, i, i+1)
+	}
+
+	return nil
+}
+
+func (manager *MemoryClientManager) All(_ context.Context) ([]*goidc.Client, error) {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+	clients := []*goidc.Client{}
+	for _, client := range manager.clients {
+		clients = append(clients, &client)
+	}
+
+	return clients, nil
+}
+
+func (manager *MemoryClientManager) ReplaceAll(_ context.Context, clients []*goidc.Client) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.clients = nil
+	for _, client := range clients {
+		manager.clients = append(manager.clients, *client)
+	}
+
+	return nil
+}
+
+// index must be called with the lock held.
+func (manager *MemoryClientManager) index(id string) int {
+	return slices.IndexFunc(manager.clients, func(c goidc.Client) bool {
+		return c.ID == id
+	})
+}
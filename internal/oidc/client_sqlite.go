@@ -0,0 +1,86 @@
+package oidc
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-open-insurance/internal/api"
+)
+
+// SQLiteClientManager keeps the clients in a SQLite table.
+type SQLiteClientManager struct {
+	db *sql.DB
+}
+
+func NewSQLiteClientManager(db *sql.DB) SQLiteClientManager {
+	return SQLiteClientManager{
+		db: db,
+	}
+}
+
+// CreateTables creates the clients table if it doesn't exist yet.
+func (manager SQLiteClientManager) CreateTables(ctx context.Context) error {
+	return api.SQLiteExec(
+		ctx,
+		manager.db,
+		`CREATE TABLE IF NOT EXISTS clients (
+			id TEXT PRIMARY KEY,
+			data TEXT NOT NULL
+		)`,
+	)
+}
+
+func (manager SQLiteClientManager) Save(ctx context.Context, client *goidc.Client) error {
+	return manager.upsert(ctx, manager.db, client)
+}
+
+func (manager SQLiteClientManager) upsert(
+	ctx context.Context,
+	db api.SQLiteExecer,
+	client *goidc.Client,
+) error {
+	data, err := json.Marshal(client)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(
+		ctx,
+		`INSERT INTO clients (id, data) VALUES (?, ?)
+		ON CONFLICT (id) DO UPDATE SET data = excluded.data`,
+		client.ID,
+		data,
+	)
+	return err
+}
+
+func (manager SQLiteClientManager) Client(ctx context.Context, id string) (*goidc.Client, error) {
+	client, err := api.SQLiteFindOne[*goidc.Client](
+		ctx,
+		manager.db,
+		`SELECT data FROM clients WHERE id = ?`,
+		id,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errClientNotFound
+	}
+	return client, err
+}
+
+func (manager SQLiteClientManager) Delete(ctx context.Context, id string) error {
+	_, err := manager.db.ExecContext(ctx, `DELETE FROM clients WHERE id = ?`, id)
+	return err
+}
+
+func (manager SQLiteClientManager) All(ctx context.Context) ([]*goidc.Client, error) {
+	return api.SQLiteFindAll[*goidc.Client](ctx, manager.db, `SELECT data FROM clients ORDER BY rowid`)
+}
+
+func (manager SQLiteClientManager) ReplaceAll(ctx context.Context, clients []*goidc.Client) error {
+	return api.SQLiteReplaceAll(ctx, manager.db, "clients", clients, func(tx *sql.Tx, client *goidc.Client) error {
+		return manager.upsert(ctx, tx, client)
+	})
+}
@@ -4,145 +4,269 @@ import (
 	"context"
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/x509"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 
-	"github.com/aws/aws-sdk-go-v2/service/kms"
-	"github.com/aws/aws-sdk-go-v2/service/kms/types"
 	"github.com/go-jose/go-jose/v4"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
 
-func SignFunc(kmsClient *kms.Client, kmsSigKeyAlias string) goidc.SignFunc {
-	return func(ctx context.Context, claims map[string]any, opts goidc.SignatureOptions) (string, error) {
-		headerJSON, _ := json.Marshal(map[string]any{
-			"alg": opts.Algorithm,
-			"typ": opts.JWTType,
-			"kid": kmsSigKeyAlias,
-		})
-		claimsJSON, _ := json.Marshal(claims)
-
-		headerB64 := base64.RawURLEncoding.EncodeToString(headerJSON)
-		claimsB64 := base64.RawURLEncoding.EncodeToString(claimsJSON)
-
-		message := headerB64 + "." + claimsB64
-		signOutput, err := kmsClient.Sign(ctx, &kms.SignInput{
-			KeyId:            &kmsSigKeyAlias,
-			Message:          []byte(message),
-			MessageType:      types.MessageTypeRaw,
-			SigningAlgorithm: signingAlg(opts.Algorithm),
-		})
-		if err != nil {
-			return "", fmt.Errorf("failed to sign using KMS: %w", err)
-		}
+// KeyProvider holds the keys the authorization server uses to sign the tokens
+// it issues and to decrypt the JWTs sent to it.
+type KeyProvider interface {
+	Sign(ctx context.Context, claims map[string]any, opts goidc.SignatureOptions) (string, error)
+	Decrypt(ctx context.Context, jwe string, opts goidc.DecryptionOptions) (string, error)
+	// JWKS returns the public keys, which are published to the clients.
+	JWKS(ctx context.Context) (jose.JSONWebKeySet, error)
+	// Keys returns the keys with their rotation status.
+	Keys(ctx context.Context) ([]KeyInfo, error)
+	// Rotate publishes a new signing and encryption key, which replace the
+	// active ones once the publish period of the rotation policy ends.
+	Rotate(ctx context.Context) error
+	// AdvanceRotation applies the rotation policy and is meant to be run
+	// periodically.
+	AdvanceRotation(ctx context.Context) error
+}
 
-		signatureB64 := base64.RawURLEncoding.EncodeToString(signOutput.Signature)
-		return headerB64 + "." + claimsB64 + "." + signatureB64, nil
-	}
+var (
+	// KeyEncryptionAlgs are the algorithms supported to encrypt the content
+	// encryption key of the JWEs sent to the server.
+	KeyEncryptionAlgs = []jose.KeyAlgorithm{jose.RSA_OAEP, jose.RSA_OAEP_256}
+	// ContentEncryptionAlgs are the algorithms supported to encrypt the content
+	// of the JWEs sent to the server.
+	ContentEncryptionAlgs = []jose.ContentEncryption{jose.A256GCM, jose.A128CBC_HS256}
+)
+
+// compactJWE is a JWE in compact serialization with its parts decoded.
+type compactJWE struct {
+	header jweHeader
+	// protected is the encoded protected header, which is the additional
+	// authenticated data of the content encryption.
+	protected    string
+	encryptedKey []byte
+	iv           []byte
+	ciphertext   []byte
+	tag          []byte
+	// keyIDs are the IDs of the keys that may decrypt the JWE, which is the
+	// one in its header or, if it doesn't inform one, all the published ones.
+	keyIDs []string
 }
 
-func signingAlg(alg jose.SignatureAlgorithm) types.SigningAlgorithmSpec {
-	switch alg {
-	case jose.ES256:
-		return types.SigningAlgorithmSpecEcdsaSha256
-	case jose.PS256:
-		return types.SigningAlgorithmSpecRsassaPssSha256
-	default:
-		return types.SigningAlgorithmSpecRsassaPssSha256
-	}
+type jweHeader struct {
+	Algorithm  jose.KeyAlgorithm         `json:"alg"`
+	Encryption jose.ContentEncryption    `json:"enc"`
+	KeyID      string                    `json:"kid"`
+	Zip        jose.CompressionAlgorithm `json:"zip"`
 }
 
-func DecryptFunc(kmsClient *kms.Client, kmsEncKeyAlias string) goidc.DecryptFunc {
-	return func(ctx context.Context, jwe string, opts goidc.DecryptionOptions) (string, error) {
-		jweParts := strings.Split(jwe, ".")
-
-		encodedEncryptedKey := jweParts[1]
-		encodedIV := jweParts[2]
-		encodedCiphertext := jweParts[3]
-		encodedTag := jweParts[4]
-
-		encryptedKey, _ := base64.RawURLEncoding.DecodeString(encodedEncryptedKey)
-		decryptOutput, err := kmsClient.Decrypt(ctx, &kms.DecryptInput{
-			CiphertextBlob:      encryptedKey,
-			KeyId:               &kmsEncKeyAlias,
-			EncryptionAlgorithm: types.EncryptionAlgorithmSpecRsaesOaepSha1,
-		})
+// parseCompactJWE decodes the JWE and validates its protected header against
+// the algorithms supported, the options informed and the IDs of the keys that
+// can decrypt it.
+// The key ID is optional in the header, in which case all the published keys
+// are tried.
+func parseCompactJWE(
+	jwe string,
+	opts goidc.DecryptionOptions,
+	keyIDs []string,
+) (
+	compactJWE,
+	error,
+) {
+	parts := strings.Split(jwe, ".")
+	if len(parts) != 5 {
+		return compactJWE{}, fmt.Errorf("malformed jwe: expected 5 parts, got %d", len(parts))
+	}
+
+	decoded := make([][]byte, len(parts))
+	for i, name := range []string{"protected header", "encrypted key", "initialization vector", "ciphertext", "authentication tag"} {
+		part, err := base64.RawURLEncoding.DecodeString(parts[i])
 		if err != nil {
-			return "", fmt.Errorf("failed to decrypt CEK using KMS: %w", err)
+			return compactJWE{}, fmt.Errorf("malformed jwe: invalid %s encoding: %w", name, err)
+		}
+		if len(part) == 0 {
+			return compactJWE{}, fmt.Errorf("malformed jwe: the %s is empty", name)
 		}
+		decoded[i] = part
+	}
 
-		iv, _ := base64.RawURLEncoding.DecodeString(encodedIV)
-		ciphertext, _ := base64.RawURLEncoding.DecodeString(encodedCiphertext)
-		authTag, _ := base64.RawURLEncoding.DecodeString(encodedTag)
+	var header jweHeader
+	if err := json.Unmarshal(decoded[0], &header); err != nil {
+		return compactJWE{}, fmt.Errorf("malformed jwe: invalid protected header: %w", err)
+	}
 
-		cek := decryptOutput.Plaintext
-		fullCiphertext := append(ciphertext, authTag...)
-		block, err := aes.NewCipher(cek)
-		if err != nil {
-			return "", fmt.Errorf("failed to create AES cipher block: %w", err)
+	if !slices.Contains(KeyEncryptionAlgs, header.Algorithm) {
+		return compactJWE{}, fmt.Errorf("unsupported jwe key encryption algorithm %q", header.Algorithm)
+	}
+	if opts.KeyAlgorithm != "" && opts.KeyAlgorithm != header.Algorithm {
+		return compactJWE{}, fmt.Errorf("jwe key encryption algorithm %q doesn't match the expected %q",
+			header.Algorithm, opts.KeyAlgorithm)
+	}
+
+	if !slices.Contains(ContentEncryptionAlgs, header.Encryption) {
+		return compactJWE{}, fmt.Errorf("unsupported jwe content encryption algorithm %q", header.Encryption)
+	}
+	if opts.ContentAlgorithm != "" && opts.ContentAlgorithm != header.Encryption {
+		return compactJWE{}, fmt.Errorf("jwe content encryption algorithm %q doesn't match the expected %q",
+			header.Encryption, opts.ContentAlgorithm)
+	}
+
+	if header.Zip != "" {
+		return compactJWE{}, fmt.Errorf("unsupported jwe compression algorithm %q", header.Zip)
+	}
+
+	kid := header.KeyID
+	if kid == "" {
+		kid = opts.KeyID
+	}
+	if opts.KeyID != "" && opts.KeyID != kid {
+		return compactJWE{}, fmt.Errorf("jwe key id %q doesn't match the expected %q", kid, opts.KeyID)
+	}
+	if kid != "" {
+		if !slices.Contains(keyIDs, kid) {
+			return compactJWE{}, fmt.Errorf("unknown jwe key id %q", kid)
 		}
+		keyIDs = []string{kid}
+	}
+	if len(keyIDs) == 0 {
+		return compactJWE{}, errors.New("no key can decrypt the jwe")
+	}
+
+	return compactJWE{
+		header:       header,
+		protected:    parts[0],
+		encryptedKey: decoded[1],
+		iv:           decoded[2],
+		ciphertext:   decoded[3],
+		tag:          decoded[4],
+		keyIDs:       keyIDs,
+	}, nil
+}
 
-		aesgcm, err := cipher.NewGCM(block)
+// decryptCompactJWE tries the keys that may decrypt the JWE until one of them
+// succeeds. decryptCEK decrypts the content encryption key with the key
+// identified by kid.
+func decryptCompactJWE(jwe compactJWE, decryptCEK func(kid string) ([]byte, error)) (string, error) {
+	var errs []error
+	for _, kid := range jwe.keyIDs {
+		cek, err := decryptCEK(kid)
 		if err != nil {
-			return "", fmt.Errorf("failed to create AES-GCM: %w", err)
+			errs = append(errs, err)
+			continue
 		}
 
-		plaintext, err := aesgcm.Open(nil, iv, fullCiphertext, nil)
+		plaintext, err := decryptContent(jwe, cek)
 		if err != nil {
-			return "", fmt.Errorf("failed to decrypt payload: %w", err)
+			errs = append(errs, err)
+			continue
 		}
 
 		return string(plaintext), nil
 	}
+
+	if len(errs) == 1 {
+		return "", errs[0]
+	}
+	return "", fmt.Errorf("none of the published keys could decrypt the jwe: %w", errors.Join(errs...))
 }
 
-func JWKSFunc(kmsClient *kms.Client, kmsSigKeyAlias, kmsEncKeyAlias string) goidc.JWKSFunc {
-	return func(ctx context.Context) (jose.JSONWebKeySet, error) {
-		signingJWK, err := fetchPublicKeyAsJWK(ctx, kmsClient, kmsSigKeyAlias, string(jose.PS256), string(goidc.KeyUsageSignature))
-		if err != nil {
-			return jose.JSONWebKeySet{}, fmt.Errorf("failed to fetch the signing key: %w", err)
-		}
+// decryptContent decrypts the ciphertext of the JWE with the content
+// encryption key and verifies its authentication tag.
+func decryptContent(jwe compactJWE, cek []byte) ([]byte, error) {
+	switch jwe.header.Encryption {
+	case jose.A256GCM:
+		return decryptAESGCM(jwe, cek)
+	case jose.A128CBC_HS256:
+		return decryptAESCBCHMAC(jwe, cek)
+	default:
+		return nil, fmt.Errorf("unsupported jwe content encryption algorithm %q", jwe.header.Encryption)
+	}
+}
 
-		encryptionJWK, err := fetchPublicKeyAsJWK(ctx, kmsClient, kmsEncKeyAlias, string(jose.RSA_OAEP), string(goidc.KeyUsageEncryption))
-		if err != nil {
-			return jose.JSONWebKeySet{}, fmt.Errorf("failed to fetch the encryption key: %w", err)
-		}
+func decryptAESGCM(jwe compactJWE, cek []byte) ([]byte, error) {
+	if len(cek) != 32 {
+		return nil, fmt.Errorf("invalid content encryption key length %d for %s", len(cek), jose.A256GCM)
+	}
 
-		return jose.JSONWebKeySet{
-			Keys: []jose.JSONWebKey{*signingJWK, *encryptionJWK},
-		}, nil
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES cipher block: %w", err)
 	}
-}
 
-// fetchPublicKeyAsJWK retrieves the public key for a given alias and converts it to a JWK
-func fetchPublicKeyAsJWK(
-	ctx context.Context,
-	kmsClient *kms.Client,
-	alias, algorithm, keyUse string,
-) (
-	*jose.JSONWebKey,
-	error,
-) {
+	aesgcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES-GCM: %w", err)
+	}
+
+	if len(jwe.iv) != aesgcm.NonceSize() {
+		return nil, fmt.Errorf("invalid initialization vector length %d for %s", len(jwe.iv), jose.A256GCM)
+	}
+	if len(jwe.tag) != aesgcm.Overhead() {
+		return nil, fmt.Errorf("invalid authentication tag length %d for %s", len(jwe.tag), jose.A256GCM)
+	}
 
-	kmsOutput, err := kmsClient.GetPublicKey(ctx, &kms.GetPublicKeyInput{
-		KeyId: &alias,
-	})
+	plaintext, err := aesgcm.Open(nil, jwe.iv, append(jwe.ciphertext, jwe.tag...), []byte(jwe.protected))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get public key: %w", err)
+		return nil, fmt.Errorf("failed to decrypt payload: %w", err)
 	}
 
-	pub, err := x509.ParsePKIXPublicKey(kmsOutput.PublicKey)
+	return plaintext, nil
+}
+
+// decryptAESCBCHMAC implements AES_128_CBC_HMAC_SHA_256 as defined in RFC 7518,
+// section 5.2.
+func decryptAESCBCHMAC(jwe compactJWE, cek []byte) ([]byte, error) {
+	if len(cek) != 32 {
+		return nil, fmt.Errorf("invalid content encryption key length %d for %s", len(cek), jose.A128CBC_HS256)
+	}
+	macKey, encKey := cek[:16], cek[16:]
+
+	if len(jwe.iv) != aes.BlockSize {
+		return nil, fmt.Errorf("invalid initialization vector length %d for %s", len(jwe.iv), jose.A128CBC_HS256)
+	}
+	if len(jwe.tag) != 16 {
+		return nil, fmt.Errorf("invalid authentication tag length %d for %s", len(jwe.tag), jose.A128CBC_HS256)
+	}
+
+	aad := []byte(jwe.protected)
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(aad)
+	mac.Write(jwe.iv)
+	mac.Write(jwe.ciphertext)
+	_ = binary.Write(mac, binary.BigEndian, uint64(len(aad))*8)
+	if subtle.ConstantTimeCompare(mac.Sum(nil)[:16], jwe.tag) != 1 {
+		return nil, errors.New("failed to decrypt payload: invalid authentication tag")
+	}
+
+	if len(jwe.ciphertext)%aes.BlockSize != 0 {
+		return nil, errors.New("failed to decrypt payload: the ciphertext is not a multiple of the block size")
+	}
+
+	block, err := aes.NewCipher(encKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse public key: %w", err)
+		return nil, fmt.Errorf("failed to create AES cipher block: %w", err)
 	}
 
-	return &jose.JSONWebKey{
-		Key:       pub,
-		KeyID:     alias,
-		Algorithm: algorithm,
-		Use:       keyUse,
-	}, nil
+	plaintext := make([]byte, len(jwe.ciphertext))
+	cipher.NewCBCDecrypter(block, jwe.iv).CryptBlocks(plaintext, jwe.ciphertext)
+
+	// Remove the PKCS #7 padding.
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, errors.New("failed to decrypt payload: invalid padding")
+	}
+	for _, b := range plaintext[len(plaintext)-padding:] {
+		if int(b) != padding {
+			return nil, errors.New("failed to decrypt payload: invalid padding")
+		}
+	}
+
+	return plaintext[:len(plaintext)-padding], nil
 }
@@ -0,0 +1,239 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/google/uuid"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// FileKeyProvider keeps the keys of the server in a private JWKS file, such as
+// the one generated by cmd/keymaker, so no external key management service is
+// needed.
+// The keys created when rotating and the status of the keys are written back
+// to the file, so they survive restarts.
+type FileKeyProvider struct {
+	ring *keyRing
+}
+
+// fileJWKS is the content of the JWKS file. The status of the keys is written
+// along with them, so the file is still a valid JWKS.
+type fileJWKS struct {
+	Keys     []jose.JSONWebKey `json:"keys"`
+	KeyInfos []KeyInfo         `json:"mockin_key_infos,omitempty"`
+}
+
+// NewFileKeyProvider loads the private JWKS at path. It must contain at least
+// one signing key and one encryption key.
+func NewFileKeyProvider(path string, policy KeyRotationPolicy) (FileKeyProvider, error) {
+	jwksBytes, err := os.ReadFile(path)
+	if err != nil {
+		return FileKeyProvider{}, err
+	}
+
+	var jwks fileJWKS
+	if err := json.Unmarshal(jwksBytes, &jwks); err != nil {
+		return FileKeyProvider{}, fmt.Errorf("could not parse the jwks at %s: %w", path, err)
+	}
+
+	for _, usage := range []goidc.KeyUsage{goidc.KeyUsageSignature, goidc.KeyUsageEncryption} {
+		if !containsKey(jwks.Keys, usage) {
+			return FileKeyProvider{}, fmt.Errorf("no %s key found in %s", usage, path)
+		}
+	}
+
+	return FileKeyProvider{
+		ring: &keyRing{
+			policy: policy,
+			load: func(_ context.Context) ([]ringKey, error) {
+				var keys []ringKey
+				for _, jwk := range jwks.Keys {
+					key := ringKey{jwk: jwk}
+					for _, info := range jwks.KeyInfos {
+						if info.ID == jwk.KeyID {
+							key.info = info
+						}
+					}
+					keys = append(keys, key)
+				}
+				return keys, nil
+			},
+			save: func(_ context.Context, keys []ringKey) error {
+				return writeFileJWKS(path, keys)
+			},
+			create: generateKey,
+			// The keys are removed from the file when the ring is saved.
+			remove: func(_ context.Context, _ string) error {
+				return nil
+			},
+		},
+	}, nil
+}
+
+// writeFileJWKS replaces the JWKS file by the keys informed. The file is
+// written to a temporary file first, so it is never left half written.
+func writeFileJWKS(path string, keys []ringKey) error {
+	var jwks fileJWKS
+	for _, key := range keys {
+		jwks.Keys = append(jwks.Keys, key.jwk)
+		jwks.KeyInfos = append(jwks.KeyInfos, key.info)
+	}
+
+	jwksBytes, err := json.MarshalIndent(jwks, "", " ")
+	if err != nil {
+		return err
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(stat.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(jwksBytes); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (p FileKeyProvider) Sign(
+	ctx context.Context,
+	claims map[string]any,
+	opts goidc.SignatureOptions,
+) (
+	string,
+	error,
+) {
+	jwk, err := p.ring.active(ctx, goidc.KeyUsageSignature)
+	if err != nil {
+		return "", err
+	}
+
+	if jwk.Algorithm != string(opts.Algorithm) {
+		return "", fmt.Errorf("the active signing key %s doesn't support the algorithm %s", jwk.KeyID, opts.Algorithm)
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: opts.Algorithm, Key: jwk},
+		(&jose.SignerOptions{}).WithType(jose.ContentType(opts.JWTType)),
+	)
+	if err != nil {
+		return "", fmt.Errorf("could not create the signer: %w", err)
+	}
+
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	jws, err := signer.Sign(claimsJSON)
+	if err != nil {
+		return "", fmt.Errorf("could not sign the claims: %w", err)
+	}
+
+	return jws.CompactSerialize()
+}
+
+func (p FileKeyProvider) Decrypt(
+	ctx context.Context,
+	jwe string,
+	opts goidc.DecryptionOptions,
+) (
+	string,
+	error,
+) {
+	keyIDs, err := p.ring.ids(ctx, goidc.KeyUsageEncryption)
+	if err != nil {
+		return "", err
+	}
+
+	parsedJWE, err := parseCompactJWE(jwe, opts, keyIDs)
+	if err != nil {
+		return "", err
+	}
+
+	return decryptCompactJWE(parsedJWE, func(kid string) ([]byte, error) {
+		jwk, err := p.ring.key(ctx, goidc.KeyUsageEncryption, kid)
+		if err != nil {
+			return nil, err
+		}
+
+		privateKey, ok := jwk.Key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("the key %s is not a RSA private key", jwk.KeyID)
+		}
+
+		hash := sha1.New()
+		if parsedJWE.header.Algorithm == jose.RSA_OAEP_256 {
+			hash = sha256.New()
+		}
+		cek, err := rsa.DecryptOAEP(hash, nil, privateKey, parsedJWE.encryptedKey, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt CEK with the key %s: %w", kid, err)
+		}
+		return cek, nil
+	})
+}
+
+func (p FileKeyProvider) JWKS(ctx context.Context) (jose.JSONWebKeySet, error) {
+	return p.ring.jwks(ctx)
+}
+
+func (p FileKeyProvider) Keys(ctx context.Context) ([]KeyInfo, error) {
+	return p.ring.infos(ctx)
+}
+
+func (p FileKeyProvider) Rotate(ctx context.Context) error {
+	return p.ring.rotate(ctx)
+}
+
+func (p FileKeyProvider) AdvanceRotation(ctx context.Context) error {
+	return p.ring.advance(ctx)
+}
+
+// generateKey creates a RSA key with the same algorithms as the ones generated
+// by cmd/keymaker.
+func generateKey(_ context.Context, usage goidc.KeyUsage) (jose.JSONWebKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return jose.JSONWebKey{}, err
+	}
+
+	return jose.JSONWebKey{
+		Key:       key,
+		KeyID:     uuid.NewString(),
+		Algorithm: keyAlgorithm(usage),
+		Use:       string(usage),
+	}, nil
+}
+
+func containsKey(jwks []jose.JSONWebKey, usage goidc.KeyUsage) bool {
+	for _, jwk := range jwks {
+		if jwk.Use == string(usage) {
+			return true
+		}
+	}
+	return false
+}
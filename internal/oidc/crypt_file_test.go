@@ -0,0 +1,190 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+func TestFileKeyProvider_Decrypt(t *testing.T) {
+	// Two encryption keys are published, so JWEs without a key id only
+	// decrypt if every published key is tried.
+	signingKey := testKey(t, goidc.KeyUsageSignature)
+	encKey1 := testKey(t, goidc.KeyUsageEncryption)
+	encKey2 := testKey(t, goidc.KeyUsageEncryption)
+	unknownKey := testKey(t, goidc.KeyUsageEncryption)
+
+	path := filepath.Join(t.TempDir(), "server.jwks")
+	jwks, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{signingKey, encKey1, encKey2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, jwks, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	provider, err := NewFileKeyProvider(path, KeyRotationPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type testCase struct {
+		name    string
+		key     jose.JSONWebKey
+		kid     string
+		alg     jose.KeyAlgorithm
+		enc     jose.ContentEncryption
+		opts    goidc.DecryptionOptions
+		tamper  func(jwe string) string
+		wantErr bool
+	}
+
+	var cases []testCase
+	for _, alg := range KeyEncryptionAlgs {
+		for _, enc := range ContentEncryptionAlgs {
+			cases = append(cases,
+				testCase{
+					name: string(alg) + " " + string(enc),
+					key:  encKey1,
+					kid:  encKey1.KeyID,
+					alg:  alg,
+					enc:  enc,
+				},
+				testCase{
+					name: string(alg) + " " + string(enc) + " without key id",
+					key:  encKey2,
+					alg:  alg,
+					enc:  enc,
+				},
+			)
+		}
+	}
+	cases = append(cases,
+		testCase{
+			name: "tampered tag",
+			key:  encKey1,
+			kid:  encKey1.KeyID,
+			alg:  jose.RSA_OAEP_256,
+			enc:  jose.A256GCM,
+			tamper: func(jwe string) string {
+				parts := strings.Split(jwe, ".")
+				tag := []byte(parts[4])
+				if tag[0] == 'A' {
+					tag[0] = 'B'
+				} else {
+					tag[0] = 'A'
+				}
+				parts[4] = string(tag)
+				return strings.Join(parts, ".")
+			},
+			wantErr: true,
+		},
+		testCase{
+			name:    "wrong key id",
+			key:     encKey1,
+			kid:     encKey2.KeyID,
+			alg:     jose.RSA_OAEP_256,
+			enc:     jose.A256GCM,
+			wantErr: true,
+		},
+		testCase{
+			name:    "unknown key id",
+			key:     unknownKey,
+			kid:     unknownKey.KeyID,
+			alg:     jose.RSA_OAEP_256,
+			enc:     jose.A256GCM,
+			wantErr: true,
+		},
+		testCase{
+			name:    "unknown key without key id",
+			key:     unknownKey,
+			alg:     jose.RSA_OAEP_256,
+			enc:     jose.A256GCM,
+			wantErr: true,
+		},
+		testCase{
+			name:    "wrong algorithm",
+			key:     encKey1,
+			kid:     encKey1.KeyID,
+			alg:     jose.RSA_OAEP,
+			enc:     jose.A256GCM,
+			opts:    goidc.DecryptionOptions{KeyAlgorithm: jose.RSA_OAEP_256},
+			wantErr: true,
+		},
+		testCase{
+			name:    "unsupported algorithm",
+			key:     encKey1,
+			kid:     encKey1.KeyID,
+			alg:     jose.RSA1_5,
+			enc:     jose.A256GCM,
+			wantErr: true,
+		},
+		testCase{
+			name: "four parts",
+			key:  encKey1,
+			kid:  encKey1.KeyID,
+			alg:  jose.RSA_OAEP_256,
+			enc:  jose.A256GCM,
+			tamper: func(jwe string) string {
+				return jwe[:strings.LastIndex(jwe, ".")]
+			},
+			wantErr: true,
+		},
+	)
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			encrypter, err := jose.NewEncrypter(c.enc, jose.Recipient{
+				Algorithm: c.alg,
+				Key:       c.key.Key.(*rsa.PrivateKey).Public(),
+				KeyID:     c.kid,
+			}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			payload := `{"sub":"random@gmail.com"}`
+			encrypted, err := encrypter.Encrypt([]byte(payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+			jwe, err := encrypted.CompactSerialize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.tamper != nil {
+				jwe = c.tamper(jwe)
+			}
+
+			got, err := provider.Decrypt(context.Background(), jwe, c.opts)
+			if c.wantErr {
+				if err == nil {
+					t.Fatal("the jwe was decrypted, but an error was expected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("could not decrypt the jwe: %v", err)
+			}
+			if got != payload {
+				t.Errorf("got %s, want %s", got, payload)
+			}
+		})
+	}
+}
+
+func testKey(t *testing.T, usage goidc.KeyUsage) jose.JSONWebKey {
+	t.Helper()
+	key, err := generateKey(context.Background(), usage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
@@ -0,0 +1,446 @@
+package oidc
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// kmsKeyDeletionWindowDays is the minimum waiting period accepted by KMS
+// before deleting a key.
+const kmsKeyDeletionWindowDays = 7
+
+// The keys are tagged with their rotation status, so the keys created when
+// rotating are found again when the server restarts.
+const (
+	// kmsTagRing identifies the keys of a provider. Its value is the alias of
+	// the initial signing key.
+	kmsTagRing        = "mockin:ring"
+	kmsTagStatus      = "mockin:status"
+	kmsTagCreatedAt   = "mockin:created_at"
+	kmsTagActivatedAt = "mockin:activated_at"
+	kmsTagRetiredAt   = "mockin:retired_at"
+	// kmsKeyStatusRemoved is the status of the keys referenced by the aliases
+	// once they are replaced, since they are not deleted.
+	kmsKeyStatusRemoved = "removed"
+)
+
+// KMSKeyProvider keeps the keys of the server in AWS KMS, so the private
+// keys never leave it.
+// The keys referenced by the aliases are the initial ones, the keys created
+// when rotating are identified by their KMS key IDs. All of them are tagged
+// with their status, so the rotations survive restarts.
+type KMSKeyProvider struct {
+	client      *kms.Client
+	sigKeyAlias string
+	encKeyAlias string
+	ring        *keyRing
+}
+
+func NewKMSKeyProvider(
+	client *kms.Client,
+	sigKeyAlias, encKeyAlias string,
+	policy KeyRotationPolicy,
+) KMSKeyProvider {
+	p := KMSKeyProvider{
+		client:      client,
+		sigKeyAlias: sigKeyAlias,
+		encKeyAlias: encKeyAlias,
+	}
+	p.ring = &keyRing{
+		policy: policy,
+		load:   p.loadKeys,
+		save:   p.saveKeys,
+		create: p.createKey,
+		remove: p.removeKey,
+	}
+	return p
+}
+
+func (p KMSKeyProvider) Sign(
+	ctx context.Context,
+	claims map[string]any,
+	opts goidc.SignatureOptions,
+) (
+	string,
+	error,
+) {
+	jwk, err := p.ring.active(ctx, goidc.KeyUsageSignature)
+	if err != nil {
+		return "", err
+	}
+
+	headerJSON, _ := json.Marshal(map[string]any{
+		"alg": opts.Algorithm,
+		"typ": opts.JWTType,
+		"kid": jwk.KeyID,
+	})
+	claimsJSON, _ := json.Marshal(claims)
+
+	headerB64 := base64.RawURLEncoding.EncodeToString(headerJSON)
+	claimsB64 := base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	message := headerB64 + "." + claimsB64
+	signOutput, err := p.client.Sign(ctx, &kms.SignInput{
+		KeyId:            &jwk.KeyID,
+		Message:          []byte(message),
+		MessageType:      types.MessageTypeRaw,
+		SigningAlgorithm: signingAlg(opts.Algorithm),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to sign using KMS: %w", err)
+	}
+
+	signatureB64 := base64.RawURLEncoding.EncodeToString(signOutput.Signature)
+	return headerB64 + "." + claimsB64 + "." + signatureB64, nil
+}
+
+func signingAlg(alg jose.SignatureAlgorithm) types.SigningAlgorithmSpec {
+	switch alg {
+	case jose.ES256:
+		return types.SigningAlgorithmSpecEcdsaSha256
+	case jose.PS256:
+		return types.SigningAlgorithmSpecRsassaPssSha256
+	default:
+		return types.SigningAlgorithmSpecRsassaPssSha256
+	}
+}
+
+func (p KMSKeyProvider) Decrypt(
+	ctx context.Context,
+	jwe string,
+	opts goidc.DecryptionOptions,
+) (
+	string,
+	error,
+) {
+	keyIDs, err := p.ring.ids(ctx, goidc.KeyUsageEncryption)
+	if err != nil {
+		return "", err
+	}
+
+	parsedJWE, err := parseCompactJWE(jwe, opts, keyIDs)
+	if err != nil {
+		return "", err
+	}
+
+	return decryptCompactJWE(parsedJWE, func(kid string) ([]byte, error) {
+		decryptOutput, err := p.client.Decrypt(ctx, &kms.DecryptInput{
+			CiphertextBlob:      parsedJWE.encryptedKey,
+			KeyId:               &kid,
+			EncryptionAlgorithm: encryptionAlg(parsedJWE.header.Algorithm),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt CEK using the KMS key %s: %w", kid, err)
+		}
+		return decryptOutput.Plaintext, nil
+	})
+}
+
+func encryptionAlg(alg jose.KeyAlgorithm) types.EncryptionAlgorithmSpec {
+	switch alg {
+	case jose.RSA_OAEP_256:
+		return types.EncryptionAlgorithmSpecRsaesOaepSha256
+	default:
+		return types.EncryptionAlgorithmSpecRsaesOaepSha1
+	}
+}
+
+func (p KMSKeyProvider) JWKS(ctx context.Context) (jose.JSONWebKeySet, error) {
+	return p.ring.jwks(ctx)
+}
+
+func (p KMSKeyProvider) Keys(ctx context.Context) ([]KeyInfo, error) {
+	return p.ring.infos(ctx)
+}
+
+func (p KMSKeyProvider) Rotate(ctx context.Context) error {
+	return p.ring.rotate(ctx)
+}
+
+func (p KMSKeyProvider) AdvanceRotation(ctx context.Context) error {
+	return p.ring.advance(ctx)
+}
+
+// loadKeys fetches the keys referenced by the aliases and the ones created
+// when rotating, which are found by their tags.
+// The keys referenced by the aliases are skipped once removed, unless no other
+// key of their usage is left.
+func (p KMSKeyProvider) loadKeys(ctx context.Context) ([]ringKey, error) {
+	var keys, removedKeys []ringKey
+	aliasKeyIDs := map[string]bool{}
+	for _, alias := range []string{p.sigKeyAlias, p.encKeyAlias} {
+		describeOutput, err := p.client.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: &alias})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe the key %s: %w", alias, err)
+		}
+		aliasKeyIDs[*describeOutput.KeyMetadata.KeyId] = true
+
+		tags, err := p.keyTags(ctx, *describeOutput.KeyMetadata.KeyId)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := p.loadKey(ctx, alias, describeOutput.KeyMetadata, tags)
+		if err != nil {
+			return nil, err
+		}
+		if tags[kmsTagStatus] == kmsKeyStatusRemoved {
+			removedKeys = append(removedKeys, key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	paginator := kms.NewListKeysPaginator(p.client, &kms.ListKeysInput{})
+	for paginator.HasMorePages() {
+		listOutput, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the keys using KMS: %w", err)
+		}
+
+		for _, entry := range listOutput.Keys {
+			if aliasKeyIDs[*entry.KeyId] {
+				continue
+			}
+
+			tags, err := p.keyTags(ctx, *entry.KeyId)
+			if err != nil {
+				return nil, err
+			}
+			if tags[kmsTagRing] != p.sigKeyAlias {
+				continue
+			}
+
+			describeOutput, err := p.client.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: entry.KeyId})
+			if err != nil {
+				return nil, fmt.Errorf("failed to describe the key %s: %w", *entry.KeyId, err)
+			}
+			// Keys pending deletion were removed.
+			if describeOutput.KeyMetadata.KeyState != types.KeyStateEnabled {
+				continue
+			}
+
+			key, err := p.loadKey(ctx, *entry.KeyId, describeOutput.KeyMetadata, tags)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+	}
+
+	for _, key := range removedKeys {
+		if !slices.ContainsFunc(keys, func(k ringKey) bool { return k.jwk.Use == key.jwk.Use }) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+// loadKey fetches the public key identified by kid and reads its status from
+// its tags. Removed keys are returned without status.
+func (p KMSKeyProvider) loadKey(
+	ctx context.Context,
+	kid string,
+	metadata *types.KeyMetadata,
+	tags map[string]string,
+) (
+	ringKey,
+	error,
+) {
+	usage := goidc.KeyUsageSignature
+	if metadata.KeyUsage == types.KeyUsageTypeEncryptDecrypt {
+		usage = goidc.KeyUsageEncryption
+	}
+
+	jwk, err := fetchPublicKeyAsJWK(ctx, p.client, kid, keyAlgorithm(usage), string(usage))
+	if err != nil {
+		return ringKey{}, fmt.Errorf("failed to fetch the %s key %s: %w", usage, kid, err)
+	}
+
+	if tags[kmsTagStatus] == kmsKeyStatusRemoved {
+		return ringKey{jwk: *jwk}, nil
+	}
+
+	info, err := keyInfoFromTags(tags)
+	if err != nil {
+		return ringKey{}, fmt.Errorf("invalid tags for the key %s: %w", kid, err)
+	}
+	return ringKey{info: info, jwk: *jwk}, nil
+}
+
+// saveKeys tags the keys with their status. The ring tag is only added when
+// creating keys, so the keys referenced by the aliases are never found by it.
+func (p KMSKeyProvider) saveKeys(ctx context.Context, keys []ringKey) error {
+	for _, key := range keys {
+		tags := []types.Tag{
+			kmsTag(kmsTagStatus, string(key.info.Status)),
+			kmsTag(kmsTagCreatedAt, key.info.CreatedAt.Format(time.RFC3339Nano)),
+		}
+		if key.info.ActivatedAt != nil {
+			tags = append(tags, kmsTag(kmsTagActivatedAt, key.info.ActivatedAt.Format(time.RFC3339Nano)))
+		}
+		if key.info.RetiredAt != nil {
+			tags = append(tags, kmsTag(kmsTagRetiredAt, key.info.RetiredAt.Format(time.RFC3339Nano)))
+		}
+
+		if err := p.tagKey(ctx, key.info.ID, tags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p KMSKeyProvider) createKey(ctx context.Context, usage goidc.KeyUsage) (jose.JSONWebKey, error) {
+	keyUsage := types.KeyUsageTypeSignVerify
+	if usage == goidc.KeyUsageEncryption {
+		keyUsage = types.KeyUsageTypeEncryptDecrypt
+	}
+
+	description := fmt.Sprintf("mockin %s key", usage)
+	createOutput, err := p.client.CreateKey(ctx, &kms.CreateKeyInput{
+		KeySpec:     types.KeySpecRsa2048,
+		KeyUsage:    keyUsage,
+		Description: &description,
+		Tags:        []types.Tag{kmsTag(kmsTagRing, p.sigKeyAlias)},
+	})
+	if err != nil {
+		return jose.JSONWebKey{}, fmt.Errorf("failed to create key using KMS: %w", err)
+	}
+
+	jwk, err := fetchPublicKeyAsJWK(ctx, p.client, *createOutput.KeyMetadata.KeyId, keyAlgorithm(usage), string(usage))
+	if err != nil {
+		return jose.JSONWebKey{}, err
+	}
+
+	return *jwk, nil
+}
+
+// removeKey schedules the deletion of keys created when rotating. The keys
+// referenced by the aliases are kept and tagged as removed, so they are not
+// loaded again when the server restarts.
+func (p KMSKeyProvider) removeKey(ctx context.Context, kid string) error {
+	if kid == p.sigKeyAlias || kid == p.encKeyAlias {
+		return p.tagKey(ctx, kid, []types.Tag{kmsTag(kmsTagStatus, kmsKeyStatusRemoved)})
+	}
+
+	_, err := p.client.ScheduleKeyDeletion(ctx, &kms.ScheduleKeyDeletionInput{
+		KeyId:               &kid,
+		PendingWindowInDays: aws.Int32(kmsKeyDeletionWindowDays),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to schedule the key deletion using KMS: %w", err)
+	}
+
+	return nil
+}
+
+// tagKey adds the tags to the key identified by kid, which may be an alias.
+func (p KMSKeyProvider) tagKey(ctx context.Context, kid string, tags []types.Tag) error {
+	// Only key IDs are accepted when tagging.
+	describeOutput, err := p.client.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: &kid})
+	if err != nil {
+		return fmt.Errorf("failed to describe the key %s: %w", kid, err)
+	}
+
+	_, err = p.client.TagResource(ctx, &kms.TagResourceInput{
+		KeyId: describeOutput.KeyMetadata.KeyId,
+		Tags:  tags,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to tag the key %s using KMS: %w", kid, err)
+	}
+	return nil
+}
+
+func (p KMSKeyProvider) keyTags(ctx context.Context, keyID string) (map[string]string, error) {
+	tags := map[string]string{}
+	input := &kms.ListResourceTagsInput{KeyId: &keyID}
+	for {
+		listOutput, err := p.client.ListResourceTags(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the tags of the key %s using KMS: %w", keyID, err)
+		}
+		for _, tag := range listOutput.Tags {
+			tags[*tag.TagKey] = *tag.TagValue
+		}
+		if !listOutput.Truncated {
+			return tags, nil
+		}
+		input.Marker = listOutput.NextMarker
+	}
+}
+
+func kmsTag(key, value string) types.Tag {
+	return types.Tag{TagKey: &key, TagValue: &value}
+}
+
+// keyInfoFromTags reads the status of a key from its tags. The status is
+// empty if the key was never saved.
+func keyInfoFromTags(tags map[string]string) (KeyInfo, error) {
+	info := KeyInfo{Status: KeyStatus(tags[kmsTagStatus])}
+	if info.Status == "" {
+		return info, nil
+	}
+
+	var err error
+	if info.CreatedAt, err = time.Parse(time.RFC3339Nano, tags[kmsTagCreatedAt]); err != nil {
+		return KeyInfo{}, err
+	}
+	if v, ok := tags[kmsTagActivatedAt]; ok {
+		activatedAt, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return KeyInfo{}, err
+		}
+		info.ActivatedAt = &activatedAt
+	}
+	if v, ok := tags[kmsTagRetiredAt]; ok {
+		retiredAt, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return KeyInfo{}, err
+		}
+		info.RetiredAt = &retiredAt
+	}
+	return info, nil
+}
+
+// fetchPublicKeyAsJWK retrieves the public key for a given alias and converts it to a JWK
+func fetchPublicKeyAsJWK(
+	ctx context.Context,
+	kmsClient *kms.Client,
+	alias, algorithm, keyUse string,
+) (
+	*jose.JSONWebKey,
+	error,
+) {
+
+	kmsOutput, err := kmsClient.GetPublicKey(ctx, &kms.GetPublicKeyInput{
+		KeyId: &alias,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get public key: %w", err)
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(kmsOutput.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+
+	return &jose.JSONWebKey{
+		Key:       pub,
+		KeyID:     alias,
+		Algorithm: algorithm,
+		Use:       keyUse,
+	}, nil
+}
@@ -0,0 +1,436 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-open-insurance/internal/api"
+)
+
+var errKeyRotationInProgress = errors.New("a key rotation is already in progress")
+
+type KeyStatus string
+
+const (
+	// KeyStatusNext keys are published, but not used to sign yet, so clients
+	// have time to refresh their cached JWKS before they are.
+	KeyStatusNext KeyStatus = "next"
+	// KeyStatusActive keys are the ones used to sign and the ones clients
+	// should encrypt with.
+	KeyStatusActive KeyStatus = "active"
+	// KeyStatusRetired keys were replaced, but are still published and accepted
+	// for decryption until the grace period ends.
+	KeyStatusRetired KeyStatus = "retired"
+)
+
+// KeyInfo describes a key of the server without its key material.
+type KeyInfo struct {
+	ID          string         `json:"kid"`
+	Usage       goidc.KeyUsage `json:"use"`
+	Algorithm   string         `json:"alg"`
+	Status      KeyStatus      `json:"status"`
+	CreatedAt   time.Time      `json:"created_at"`
+	ActivatedAt *time.Time     `json:"activated_at,omitempty"`
+	RetiredAt   *time.Time     `json:"retired_at,omitempty"`
+}
+
+// KeyRotationPolicy defines how the keys of the server are rotated.
+type KeyRotationPolicy struct {
+	// Interval is how often new keys are created. Zero disables the automatic
+	// rotation, so keys are only rotated on demand.
+	Interval time.Duration
+	// PublishPeriod is how long new keys are published before being activated.
+	PublishPeriod time.Duration
+	// GracePeriod is how long retired keys are still published after being
+	// replaced.
+	GracePeriod time.Duration
+}
+
+type ringKey struct {
+	info KeyInfo
+	jwk  jose.JSONWebKey
+}
+
+// keyRing holds the keys of a key provider and moves them through the
+// rotation statuses.
+// The key material is kept by the ring. For providers where the private keys
+// are held elsewhere, such as KMS, the JWK is public.
+type keyRing struct {
+	policy KeyRotationPolicy
+	// load returns the keys saved before with their status. The ID, usage and
+	// algorithm are taken from the JWK, so only the status and its times need
+	// to be informed. Keys without a status, such as the initial ones, are
+	// activated if no other key of their usage is active and retired otherwise.
+	load func(ctx context.Context) ([]ringKey, error)
+	// save persists the keys and their status whenever they change, so they
+	// survive restarts.
+	save func(ctx context.Context, keys []ringKey) error
+	// create generates a new key for the usage.
+	create func(ctx context.Context, usage goidc.KeyUsage) (jose.JSONWebKey, error)
+	// remove is called when a key is no longer published.
+	remove func(ctx context.Context, kid string) error
+	// now returns the current time. It defaults to time.Now.
+	now func() time.Time
+
+	// rotationMu serializes the operations that create and remove keys, which
+	// may be slow, so mu is only held while the keys are modified.
+	rotationMu sync.Mutex
+	mu         sync.RWMutex
+	loaded     bool
+	keys       []ringKey
+}
+
+func (r *keyRing) ensureLoaded(ctx context.Context) error {
+	r.mu.RLock()
+	loaded := r.loaded
+	r.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+
+	r.rotationMu.Lock()
+	defer r.rotationMu.Unlock()
+
+	r.mu.RLock()
+	loaded = r.loaded
+	r.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+
+	keys, err := r.load(ctx)
+	if err != nil {
+		return fmt.Errorf("could not load the keys: %w", err)
+	}
+
+	now := r.clock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// The keys with a status are added first, so they decide which keys are
+	// active.
+	for _, key := range keys {
+		if key.info.Status == "" {
+			continue
+		}
+		key.info.ID = key.jwk.KeyID
+		key.info.Usage = goidc.KeyUsage(key.jwk.Use)
+		key.info.Algorithm = key.jwk.Algorithm
+		r.keys = append(r.keys, key)
+	}
+	for _, key := range keys {
+		if key.info.Status != "" {
+			continue
+		}
+		key.info = KeyInfo{
+			ID:        key.jwk.KeyID,
+			Usage:     goidc.KeyUsage(key.jwk.Use),
+			Algorithm: key.jwk.Algorithm,
+			Status:    KeyStatusRetired,
+			CreatedAt: now,
+			RetiredAt: &now,
+		}
+		if !r.hasKey(key.info.Usage, KeyStatusActive) {
+			key.info.Status = KeyStatusActive
+			key.info.ActivatedAt = &now
+			key.info.RetiredAt = nil
+		}
+		r.keys = append(r.keys, key)
+	}
+	r.loaded = true
+	return nil
+}
+
+// active returns the key currently in use for the usage.
+func (r *keyRing) active(ctx context.Context, usage goidc.KeyUsage) (jose.JSONWebKey, error) {
+	if err := r.ensureLoaded(ctx); err != nil {
+		return jose.JSONWebKey{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, key := range r.keys {
+		if key.info.Usage == usage && key.info.Status == KeyStatusActive {
+			return key.jwk, nil
+		}
+	}
+	return jose.JSONWebKey{}, fmt.Errorf("no active %s key found", usage)
+}
+
+// key returns the published key for the usage with the key ID informed.
+func (r *keyRing) key(ctx context.Context, usage goidc.KeyUsage, kid string) (jose.JSONWebKey, error) {
+	if err := r.ensureLoaded(ctx); err != nil {
+		return jose.JSONWebKey{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, key := range r.keys {
+		if key.info.Usage == usage && key.info.ID == kid {
+			return key.jwk, nil
+		}
+	}
+	return jose.JSONWebKey{}, fmt.Errorf("no %s key found with id %q", usage, kid)
+}
+
+// ids returns the IDs of all the published keys for the usage.
+func (r *keyRing) ids(ctx context.Context, usage goidc.KeyUsage) ([]string, error) {
+	if err := r.ensureLoaded(ctx); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var ids []string
+	for _, key := range r.keys {
+		if key.info.Usage == usage {
+			ids = append(ids, key.info.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (r *keyRing) jwks(ctx context.Context) (jose.JSONWebKeySet, error) {
+	if err := r.ensureLoaded(ctx); err != nil {
+		return jose.JSONWebKeySet{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	jwks := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{}}
+	for _, key := range r.keys {
+		jwks.Keys = append(jwks.Keys, key.jwk.Public())
+	}
+	return jwks, nil
+}
+
+func (r *keyRing) infos(ctx context.Context) ([]KeyInfo, error) {
+	if err := r.ensureLoaded(ctx); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	infos := []KeyInfo{}
+	for _, key := range r.keys {
+		infos = append(infos, key.info)
+	}
+	return infos, nil
+}
+
+// rotate publishes a new signing and encryption key. They replace the active
+// ones once the publish period ends.
+func (r *keyRing) rotate(ctx context.Context) error {
+	if err := r.ensureLoaded(ctx); err != nil {
+		return err
+	}
+
+	r.rotationMu.Lock()
+	defer r.rotationMu.Unlock()
+	return r.rotateLocked(ctx)
+}
+
+func (r *keyRing) rotateLocked(ctx context.Context) error {
+	r.mu.RLock()
+	inProgress := r.hasKey(goidc.KeyUsageSignature, KeyStatusNext) ||
+		r.hasKey(goidc.KeyUsageEncryption, KeyStatusNext)
+	keys := slices.Clone(r.keys)
+	r.mu.RUnlock()
+	if inProgress {
+		return errKeyRotationInProgress
+	}
+
+	var newKeys []ringKey
+	now := r.clock()
+	for _, usage := range []goidc.KeyUsage{goidc.KeyUsageSignature, goidc.KeyUsageEncryption} {
+		jwk, err := r.create(ctx, usage)
+		if err != nil {
+			return errors.Join(fmt.Errorf("could not create a new %s key: %w", usage, err), r.removeAll(ctx, newKeys))
+		}
+		newKeys = append(newKeys, ringKey{
+			info: KeyInfo{
+				ID:        jwk.KeyID,
+				Usage:     usage,
+				Algorithm: jwk.Algorithm,
+				Status:    KeyStatusNext,
+				CreatedAt: now,
+			},
+			jwk: jwk,
+		})
+	}
+
+	keys = append(keys, newKeys...)
+	if err := r.save(ctx, keys); err != nil {
+		return errors.Join(fmt.Errorf("could not save the keys: %w", err), r.removeAll(ctx, newKeys))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = keys
+	return nil
+}
+
+// removeAll removes keys that were created, but couldn't be added to the ring.
+func (r *keyRing) removeAll(ctx context.Context, keys []ringKey) error {
+	var errs []error
+	for _, key := range keys {
+		if err := r.remove(ctx, key.info.ID); err != nil {
+			errs = append(errs, fmt.Errorf("could not remove the key %s: %w", key.info.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// advance applies the rotation policy. It activates the next keys whose
+// publish period ended, removes the retired keys whose grace period ended and
+// starts a new rotation when the active keys are older than the interval.
+func (r *keyRing) advance(ctx context.Context) error {
+	if err := r.ensureLoaded(ctx); err != nil {
+		return err
+	}
+
+	r.rotationMu.Lock()
+	defer r.rotationMu.Unlock()
+
+	// The keys are changed in a copy, which replaces them once saved. This is
+	// safe because only the operations holding rotationMu change the keys.
+	r.mu.RLock()
+	keys := slices.Clone(r.keys)
+	r.mu.RUnlock()
+
+	now := r.clock()
+	changed := false
+	for _, usage := range []goidc.KeyUsage{goidc.KeyUsageSignature, goidc.KeyUsageEncryption} {
+		next := slices.IndexFunc(keys, func(key ringKey) bool {
+			return key.info.Usage == usage && key.info.Status == KeyStatusNext
+		})
+		if next == -1 || now.Before(keys[next].info.CreatedAt.Add(r.policy.PublishPeriod)) {
+			continue
+		}
+
+		for i := range keys {
+			if keys[i].info.Usage == usage && keys[i].info.Status == KeyStatusActive {
+				keys[i].info.Status = KeyStatusRetired
+				keys[i].info.RetiredAt = &now
+			}
+		}
+		keys[next].info.Status = KeyStatusActive
+		keys[next].info.ActivatedAt = &now
+		changed = true
+	}
+
+	var expiredIDs []string
+	for _, key := range keys {
+		if key.info.Status == KeyStatusRetired && !now.Before(key.info.RetiredAt.Add(r.policy.GracePeriod)) {
+			expiredIDs = append(expiredIDs, key.info.ID)
+		}
+	}
+	if len(expiredIDs) != 0 {
+		keys = slices.DeleteFunc(keys, func(key ringKey) bool {
+			return slices.Contains(expiredIDs, key.info.ID)
+		})
+		changed = true
+	}
+
+	rotationDue := false
+	if r.policy.Interval > 0 {
+		for _, key := range keys {
+			if key.info.Status == KeyStatusActive && !now.Before(key.info.ActivatedAt.Add(r.policy.Interval)) {
+				rotationDue = true
+			}
+		}
+	}
+
+	if changed {
+		if err := r.save(ctx, keys); err != nil {
+			return fmt.Errorf("could not save the keys: %w", err)
+		}
+		r.mu.Lock()
+		r.keys = keys
+		r.mu.Unlock()
+	}
+
+	var errs []error
+	for _, kid := range expiredIDs {
+		if err := r.remove(ctx, kid); err != nil {
+			errs = append(errs, fmt.Errorf("could not remove the key %s: %w", kid, err))
+		}
+	}
+
+	if rotationDue {
+		if err := r.rotateLocked(ctx); err != nil && !errors.Is(err, errKeyRotationInProgress) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// keyAlgorithm returns the algorithm of the keys created for the usage.
+func keyAlgorithm(usage goidc.KeyUsage) string {
+	if usage == goidc.KeyUsageEncryption {
+		return string(jose.RSA_OAEP)
+	}
+	return string(jose.PS256)
+}
+
+func (r *keyRing) clock() time.Time {
+	if r.now == nil {
+		return time.Now().UTC()
+	}
+	return r.now().UTC()
+}
+
+// hasKey must be called with mu held.
+func (r *keyRing) hasKey(usage goidc.KeyUsage, status KeyStatus) bool {
+	return slices.ContainsFunc(r.keys, func(key ringKey) bool {
+		return key.info.Usage == usage && key.info.Status == status
+	})
+}
+
+// RegisterKeyAdminHandlers adds the endpoints to list and rotate the server
+// keys under prefix.
+func RegisterKeyAdminHandlers(mux *http.ServeMux, prefix string, keys KeyProvider) {
+	mux.HandleFunc("GET "+prefix+"/keys", keysHandler(keys))
+	mux.HandleFunc("POST "+prefix+"/keys/rotate", rotateKeysHandler(keys))
+}
+
+func keysHandler(keys KeyProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		infos, err := keys.Keys(r.Context())
+		if err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		api.WriteJSON(w, http.StatusOK, infos)
+	}
+}
+
+// rotateKeysHandler publishes new keys and responds with all the keys, so the
+// new key IDs are known.
+func rotateKeysHandler(keys KeyProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := keys.Rotate(r.Context()); err != nil {
+			if errors.Is(err, errKeyRotationInProgress) {
+				err = api.NewError("CONFLICT", http.StatusConflict, err.Error())
+			}
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		infos, err := keys.Keys(r.Context())
+		if err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		api.WriteJSON(w, http.StatusAccepted, infos)
+	}
+}
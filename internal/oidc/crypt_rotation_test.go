@@ -0,0 +1,247 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+func TestKeyRing(t *testing.T) {
+	policy := KeyRotationPolicy{
+		Interval:      time.Hour,
+		PublishPeriod: time.Minute,
+		GracePeriod:   10 * time.Minute,
+	}
+
+	type step struct {
+		// at is when the action runs, relative to when the keys are loaded.
+		at      time.Duration
+		rotate  bool
+		wantErr error
+	}
+
+	cases := []struct {
+		name        string
+		steps       []step
+		want        map[string]KeyStatus
+		wantRemoved []string
+	}{
+		{
+			name:  "rotated keys are published first",
+			steps: []step{{rotate: true}, {at: time.Minute - time.Second}},
+			want: map[string]KeyStatus{
+				"sig-0": KeyStatusActive, "enc-0": KeyStatusActive,
+				"sig-1": KeyStatusNext, "enc-1": KeyStatusNext,
+			},
+		},
+		{
+			name:  "rotated keys are activated after the publish period",
+			steps: []step{{rotate: true}, {at: time.Minute}},
+			want: map[string]KeyStatus{
+				"sig-0": KeyStatusRetired, "enc-0": KeyStatusRetired,
+				"sig-1": KeyStatusActive, "enc-1": KeyStatusActive,
+			},
+		},
+		{
+			name:  "retired keys are published during the grace period",
+			steps: []step{{rotate: true}, {at: time.Minute}, {at: 11*time.Minute - time.Second}},
+			want: map[string]KeyStatus{
+				"sig-0": KeyStatusRetired, "enc-0": KeyStatusRetired,
+				"sig-1": KeyStatusActive, "enc-1": KeyStatusActive,
+			},
+		},
+		{
+			name:  "retired keys are removed after the grace period",
+			steps: []step{{rotate: true}, {at: time.Minute}, {at: 11 * time.Minute}},
+			want: map[string]KeyStatus{
+				"sig-1": KeyStatusActive, "enc-1": KeyStatusActive,
+			},
+			wantRemoved: []string{"enc-0", "sig-0"},
+		},
+		{
+			name:  "keys are rotated after the interval",
+			steps: []step{{at: time.Hour - time.Second}, {at: time.Hour}},
+			want: map[string]KeyStatus{
+				"sig-0": KeyStatusActive, "enc-0": KeyStatusActive,
+				"sig-1": KeyStatusNext, "enc-1": KeyStatusNext,
+			},
+		},
+		{
+			name:  "a rotation in progress conflicts",
+			steps: []step{{rotate: true}, {rotate: true, wantErr: errKeyRotationInProgress}},
+			want: map[string]KeyStatus{
+				"sig-0": KeyStatusActive, "enc-0": KeyStatusActive,
+				"sig-1": KeyStatusNext, "enc-1": KeyStatusNext,
+			},
+		},
+		{
+			name:  "keys can be rotated again once activated",
+			steps: []step{{rotate: true}, {at: time.Minute}, {at: time.Minute, rotate: true}},
+			want: map[string]KeyStatus{
+				"sig-0": KeyStatusRetired, "enc-0": KeyStatusRetired,
+				"sig-1": KeyStatusActive, "enc-1": KeyStatusActive,
+				"sig-2": KeyStatusNext, "enc-2": KeyStatusNext,
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			st := newFakeKeyStore(testRingKey("sig-0", goidc.KeyUsageSignature), testRingKey("enc-0", goidc.KeyUsageEncryption))
+			start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			now := start
+			r := st.ring(policy, &now)
+			if err := r.ensureLoaded(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, s := range c.steps {
+				now = start.Add(s.at)
+				var err error
+				if s.rotate {
+					err = r.rotate(ctx)
+				} else {
+					err = r.advance(ctx)
+				}
+				if !errors.Is(err, s.wantErr) {
+					t.Fatalf("got error %v at %s, want %v", err, s.at, s.wantErr)
+				}
+			}
+
+			if got := keyStatuses(t, r); !maps.Equal(got, c.want) {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+			slices.Sort(st.removed)
+			if !slices.Equal(st.removed, c.wantRemoved) {
+				t.Errorf("got removed keys %v, want %v", st.removed, c.wantRemoved)
+			}
+
+			// The keys saved are loaded with the same status.
+			reloaded := st.ring(policy, &now)
+			if got := keyStatuses(t, reloaded); !maps.Equal(got, c.want) {
+				t.Errorf("got %v after reloading, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestKeyRing_Load(t *testing.T) {
+	activeSig := testRingKey("sig-1", goidc.KeyUsageSignature)
+	activeSig.info.Status = KeyStatusActive
+	activeSig.info.ActivatedAt = &time.Time{}
+
+	st := newFakeKeyStore(
+		testRingKey("sig-0", goidc.KeyUsageSignature),
+		testRingKey("enc-0", goidc.KeyUsageEncryption),
+		testRingKey("enc-1", goidc.KeyUsageEncryption),
+		activeSig,
+	)
+	now := time.Now()
+	r := st.ring(KeyRotationPolicy{}, &now)
+
+	// The keys with a status decide which keys are active, and among the
+	// others the first one of each usage is activated.
+	want := map[string]KeyStatus{
+		"sig-0": KeyStatusRetired,
+		"sig-1": KeyStatusActive,
+		"enc-0": KeyStatusActive,
+		"enc-1": KeyStatusRetired,
+	}
+	if got := keyStatuses(t, r); !maps.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestKeyRing_RotateSaveFailure(t *testing.T) {
+	ctx := context.Background()
+	st := newFakeKeyStore(testRingKey("sig-0", goidc.KeyUsageSignature), testRingKey("enc-0", goidc.KeyUsageEncryption))
+	now := time.Now()
+	r := st.ring(KeyRotationPolicy{}, &now)
+
+	st.saveErr = errors.New("save failed")
+	if err := r.rotate(ctx); !errors.Is(err, st.saveErr) {
+		t.Fatalf("got %v, want %v", err, st.saveErr)
+	}
+
+	want := map[string]KeyStatus{"sig-0": KeyStatusActive, "enc-0": KeyStatusActive}
+	if got := keyStatuses(t, r); !maps.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	slices.Sort(st.removed)
+	if wantRemoved := []string{"enc-1", "sig-1"}; !slices.Equal(st.removed, wantRemoved) {
+		t.Errorf("got removed keys %v, want the created ones %v", st.removed, wantRemoved)
+	}
+
+	// The ring is still usable once the keys can be saved.
+	st.saveErr = nil
+	if err := r.rotate(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeKeyStore keeps the keys of a ring in memory.
+type fakeKeyStore struct {
+	keys    []ringKey
+	created map[goidc.KeyUsage]int
+	removed []string
+	saveErr error
+}
+
+func newFakeKeyStore(keys ...ringKey) *fakeKeyStore {
+	return &fakeKeyStore{keys: keys, created: map[goidc.KeyUsage]int{}}
+}
+
+// ring returns a ring that loads the keys of the store and reads the time
+// from now.
+func (st *fakeKeyStore) ring(policy KeyRotationPolicy, now *time.Time) *keyRing {
+	return &keyRing{
+		policy: policy,
+		load: func(_ context.Context) ([]ringKey, error) {
+			return slices.Clone(st.keys), nil
+		},
+		save: func(_ context.Context, keys []ringKey) error {
+			if st.saveErr != nil {
+				return st.saveErr
+			}
+			st.keys = slices.Clone(keys)
+			return nil
+		},
+		create: func(_ context.Context, usage goidc.KeyUsage) (jose.JSONWebKey, error) {
+			st.created[usage]++
+			return testRingKey(fmt.Sprintf("%s-%d", usage, st.created[usage]), usage).jwk, nil
+		},
+		remove: func(_ context.Context, kid string) error {
+			st.removed = append(st.removed, kid)
+			return nil
+		},
+		now: func() time.Time {
+			return *now
+		},
+	}
+}
+
+func testRingKey(kid string, usage goidc.KeyUsage) ringKey {
+	return ringKey{jwk: jose.JSONWebKey{KeyID: kid, Use: string(usage), Algorithm: keyAlgorithm(usage)}}
+}
+
+func keyStatuses(t *testing.T, r *keyRing) map[string]KeyStatus {
+	t.Helper()
+	infos, err := r.infos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := map[string]KeyStatus{}
+	for _, info := range infos {
+		statuses[info.ID] = info.Status
+	}
+	return statuses
+}
@@ -2,8 +2,10 @@ package oidc
 
 import (
 	"context"
+	"time"
 
 	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-open-insurance/internal/api"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -11,26 +13,39 @@ import (
 
 // TODO: Make sure this is working as expected.
 
-type GrantSessionManager struct {
+// GrantSessionManager stores the grants issued to the clients.
+type GrantSessionManager interface {
+	goidc.GrantSessionManager
+	// DeleteByConsentID deletes all the grant sessions issued for the consent,
+	// invalidating its access and refresh tokens.
+	DeleteByConsentID(ctx context.Context, consentID string) error
+	// DeleteExpired removes all the sessions that reached their expiration.
+	DeleteExpired(ctx context.Context) error
+}
+
+// MongoGrantSessionManager keeps the grant sessions in a mongo collection.
+type MongoGrantSessionManager struct {
 	Collection *mongo.Collection
 }
 
-func NewGrantSessionManager(database *mongo.Database) GrantSessionManager {
-	return GrantSessionManager{
+func NewMongoGrantSessionManager(database *mongo.Database) MongoGrantSessionManager {
+	return MongoGrantSessionManager{
 		Collection: database.Collection("grant_sessions"),
 	}
 }
 
-func (manager GrantSessionManager) Save(
+func (manager MongoGrantSessionManager) Save(
 	ctx context.Context,
 	grantSession *goidc.GrantSession,
 ) error {
+	capExpirationAtConsent(grantSession)
+
 	shouldReplace := true
 	filter := bson.D{{Key: "_id", Value: grantSession.ID}}
 	if _, err := manager.Collection.ReplaceOne(
 		ctx,
 		filter,
-		grantSession,
+		newGrantSessionDocument(grantSession),
 		&options.ReplaceOptions{Upsert: &shouldReplace},
 	); err != nil {
 		return err
@@ -39,7 +54,7 @@ func (manager GrantSessionManager) Save(
 	return nil
 }
 
-func (manager GrantSessionManager) SessionByTokenID(
+func (manager MongoGrantSessionManager) SessionByTokenID(
 	ctx context.Context,
 	id string,
 ) (
@@ -49,7 +64,7 @@ func (manager GrantSessionManager) SessionByTokenID(
 	return manager.getWithFilter(ctx, bson.D{{Key: "token_id", Value: id}})
 }
 
-func (manager GrantSessionManager) SessionByRefreshToken(
+func (manager MongoGrantSessionManager) SessionByRefreshToken(
 	ctx context.Context,
 	token string,
 ) (
@@ -62,7 +77,7 @@ func (manager GrantSessionManager) SessionByRefreshToken(
 	)
 }
 
-func (manager GrantSessionManager) Delete(
+func (manager MongoGrantSessionManager) Delete(
 	ctx context.Context,
 	id string,
 ) error {
@@ -74,11 +89,23 @@ func (manager GrantSessionManager) Delete(
 	return nil
 }
 
-func (m GrantSessionManager) DeleteByAuthorizationCode(context.Context, string) error {
+func (m MongoGrantSessionManager) DeleteByAuthorizationCode(context.Context, string) error {
 	return nil
 }
 
-func (manager GrantSessionManager) getWithFilter(
+func (manager MongoGrantSessionManager) DeleteByConsentID(
+	ctx context.Context,
+	consentID string,
+) error {
+	filter := bson.D{{Key: "consent_id", Value: consentID}}
+	if _, err := manager.Collection.DeleteMany(ctx, filter); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (manager MongoGrantSessionManager) getWithFilter(
 	ctx context.Context,
 	filter any,
 ) (
@@ -98,3 +125,61 @@ func (manager GrantSessionManager) getWithFilter(
 
 	return &grantSession, nil
 }
+
+// CreateIndexes creates a TTL index so mongo removes the sessions once they
+// expire.
+func (manager MongoGrantSessionManager) CreateIndexes(ctx context.Context) error {
+	_, err := manager.Collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expires_at_date", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{
+			Keys: bson.D{{Key: "consent_id", Value: 1}},
+		},
+	})
+	return err
+}
+
+func (manager MongoGrantSessionManager) DeleteExpired(ctx context.Context) error {
+	filter := bson.D{{Key: "expires_at", Value: bson.D{
+		{Key: "$lt", Value: time.Now().Unix()},
+	}}}
+	if _, err := manager.Collection.DeleteMany(ctx, filter); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// grantSessionDocument is how a session is persisted. The expiration is also
+// stored as a date, since that is what mongo TTL indexes work with.
+// The consent ID is kept at the top level so all the grants of a consent can
+// be found when it is revoked.
+type grantSessionDocument struct {
+	goidc.GrantSession `bson:",inline"`
+	ExpiresAt          time.Time `bson:"expires_at_date"`
+	ConsentID          string    `bson:"consent_id,omitempty"`
+}
+
+func newGrantSessionDocument(session *goidc.GrantSession) grantSessionDocument {
+	consentID, _ := api.ConsentID(session.GrantedScopes)
+	return grantSessionDocument{
+		GrantSession: *session,
+		ExpiresAt:    time.Unix(int64(session.ExpiresAtTimestamp), 0).UTC(),
+		ConsentID:    consentID,
+	}
+}
+
+// capExpirationAtConsent makes sure a grant session, and therefore its
+// refresh token, doesn't outlive the consent it was issued for.
+func capExpirationAtConsent(session *goidc.GrantSession) {
+	consentExpiresAt, ok := session.Store[storeKeyConsentExpiresAt].(int64)
+	if !ok {
+		return
+	}
+
+	if int64(session.ExpiresAtTimestamp) > consentExpiresAt {
+		session.ExpiresAtTimestamp = int(consentExpiresAt)
+	}
+}
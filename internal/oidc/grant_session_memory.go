@@ -0,0 +1,131 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-open-insurance/internal/api"
+)
+
+var errGrantSessionNotFound = errors.New("grant session not found")
+
+// MemoryGrantSessionManager keeps the grant sessions in memory, so they are
+// lost when the server stops.
+type MemoryGrantSessionManager struct {
+	mu       sync.RWMutex
+	sessions map[string]goidc.GrantSession
+}
+
+func NewMemoryGrantSessionManager() *MemoryGrantSessionManager {
+	return &MemoryGrantSessionManager{
+		sessions: make(map[string]goidc.GrantSession),
+	}
+}
+
+func (manager *MemoryGrantSessionManager) Save(
+	_ context.Context,
+	grantSession *goidc.GrantSession,
+) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	capExpirationAtConsent(grantSession)
+	manager.sessions[grantSession.ID] = cloneGrantSession(*grantSession)
+	return nil
+}
+
+func (manager *MemoryGrantSessionManager) SessionByTokenID(
+	_ context.Context,
+	id string,
+) (
+	*goidc.GrantSession,
+	error,
+) {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+	return manager.find(func(s goidc.GrantSession) bool {
+		return s.TokenID == id
+	})
+}
+
+func (manager *MemoryGrantSessionManager) SessionByRefreshToken(
+	_ context.Context,
+	token string,
+) (
+	*goidc.GrantSession,
+	error,
+) {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+	return manager.find(func(s goidc.GrantSession) bool {
+		return s.RefreshToken == token
+	})
+}
+
+func (manager *MemoryGrantSessionManager) Delete(_ context.Context, id string) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	delete(manager.sessions, id)
+	return nil
+}
+
+func (manager *MemoryGrantSessionManager) DeleteByAuthorizationCode(context.Context, string) error {
+	return nil
+}
+
+func (manager *MemoryGrantSessionManager) DeleteByConsentID(
+	_ context.Context,
+	consentID string,
+) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	for id, session := range manager.sessions {
+		if sessionConsentID, ok := api.ConsentID(session.GrantedScopes); ok &&
+			sessionConsentID == consentID {
+			delete(manager.sessions, id)
+		}
+	}
+
+	return nil
+}
+
+func (manager *MemoryGrantSessionManager) DeleteExpired(_ context.Context) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	for id, session := range manager.sessions {
+		if session.IsExpired() {
+			delete(manager.sessions, id)
+		}
+	}
+
+	return nil
+}
+
+// find must be called with the lock held.
+func (manager *MemoryGrantSessionManager) find(
+	matches func(goidc.GrantSession) bool,
+) (
+	*goidc.GrantSession,
+	error,
+) {
+	for _, session := range manager.sessions {
+		if matches(session) {
+			session = cloneGrantSession(session)
+			return &session, nil
+		}
+	}
+
+	return nil, errGrantSessionNotFound
+}
+
+// cloneGrantSession copies the maps of the session so the one stored is not
+// modified through the ones returned.
+func cloneGrantSession(session goidc.GrantSession) goidc.GrantSession {
+	session.Store = maps.Clone(session.Store)
+	session.AdditionalTokenClaims = maps.Clone(session.AdditionalTokenClaims)
+	session.AdditionalIDTokenClaims = maps.Clone(session.AdditionalIDTokenClaims)
+	session.AdditionalUserInfoClaims = maps.Clone(session.AdditionalUserInfoClaims)
+	return session
+}
@@ -0,0 +1,45 @@
+package oidc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-open-insurance/internal/storagetest"
+)
+
+// TestMemoryGrantSessionManager_Concurrent modifies the maps of the same
+// session fetched at the same time, so the race detector reports if they are
+// shared with the session stored.
+func TestMemoryGrantSessionManager_Concurrent(t *testing.T) {
+	manager := NewMemoryGrantSessionManager()
+	ctx := context.Background()
+	session := &goidc.GrantSession{ID: "session", TokenID: "token"}
+	session.Store = map[string]any{"step": 1}
+	if err := manager.Save(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+	session.Store["step"] = 2
+
+	errs := storagetest.Concurrently(50, func() error {
+		stored, err := manager.SessionByTokenID(ctx, "token")
+		if err != nil {
+			return err
+		}
+		stored.Store["step"] = 3
+		return nil
+	})
+	for _, err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	stored, err := manager.SessionByTokenID(ctx, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Store["step"] != 1 {
+		t.Errorf("got step %v, want the session stored to be kept as 1", stored.Store["step"])
+	}
+}
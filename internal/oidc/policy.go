@@ -1,18 +1,22 @@
 package oidc
 
 import (
+	"context"
 	"errors"
 	"html/template"
 	"log"
+	"log/slog"
 	"net/http"
 	"path/filepath"
 	"slices"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 	"github.com/luikyv/go-open-insurance/internal/api"
 	"github.com/luikyv/go-open-insurance/internal/consent"
+	"github.com/luikyv/go-open-insurance/internal/resource"
 	"github.com/luikyv/go-open-insurance/internal/user"
 )
 
@@ -20,25 +24,38 @@ func Policy(
 	templatesDir, baseURL string,
 	userService user.Service,
 	consentService consent.Service,
+	resourceService resource.Service,
+	userSessionManager UserSessionManager,
 ) goidc.AuthnPolicy {
 
 	loginTemplate := filepath.Join(templatesDir, "/login.html")
+	otpTemplate := filepath.Join(templatesDir, "/otp.html")
 	consentTemplate := filepath.Join(templatesDir, "/consent.html")
-	tmpl, err := template.ParseFiles(loginTemplate, consentTemplate)
+	tmpl, err := template.ParseFiles(loginTemplate, otpTemplate, consentTemplate)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	authenticator := authenticator{
-		tmpl:           tmpl,
-		baseURL:        baseURL,
-		userService:    userService,
-		consentService: consentService,
+		tmpl:               tmpl,
+		baseURL:            baseURL,
+		userService:        userService,
+		consentService:     consentService,
+		resourceService:    resourceService,
+		userSessionManager: userSessionManager,
+		approver: approver{
+			userService:     userService,
+			consentService:  consentService,
+			resourceService: resourceService,
+		},
 	}
 	return goidc.NewPolicy(
 		"main",
 		func(r *http.Request, c *goidc.Client, as *goidc.AuthnSession) bool {
 			as.StoreParameter(paramStepID, stepIDSetUp)
+			if username, ok := c.Attribute(ClientAttrAutoApproveUsername).(string); ok {
+				as.StoreParameter(paramAutoApproveUsername, username)
+			}
 			return true
 		},
 		authenticator.authenticate,
@@ -49,33 +66,57 @@ const (
 	paramConsentID   = "consent_id"
 	paramPermissions = "permissions"
 	paramConsentCPF  = "consent_cpf"
+	paramConsentCNPJ = "consent_cnpj"
 	paramUserID      = "user_id"
+	paramAuthTime    = "auth_time"
+	paramACR         = "acr"
 	paramStepID      = "step_id"
-
-	stepIDSetUp      = "setup"
-	stepIDLogin      = "login"
-	stepIDConsent    = "consent"
-	stepIDFinishFlow = "finish_flow"
-
-	usernameFormParam = "username"
-	passwordFormParam = "password"
-	loginFormParam    = "login"
-	consentFormParam  = "consent"
-
-	correctPassword = "pass"
+	// paramAutoApproveUsername is the user on behalf of which consents are
+	// authorized automatically.
+	paramAutoApproveUsername = "auto_approve_username"
+
+	stepIDSetUp       = "setup"
+	stepIDAutoApprove = "auto_approve"
+	stepIDLogin       = "login"
+	stepIDOTP         = "otp"
+	stepIDConsent     = "consent"
+	stepIDFinishFlow  = "finish_flow"
+
+	usernameFormParam    = "username"
+	passwordFormParam    = "password"
+	loginFormParam       = "login"
+	otpFormParam         = "otp"
+	verifyFormParam      = "verify"
+	consentFormParam     = "consent"
+	permissionsFormParam = "permissions"
+	resourcesFormParam   = "resources"
 )
 
 type authnPage struct {
 	CallbackID  string
-	Permissions []api.ConsentPermission
-	Error       string
+	Permissions []permissionOption
+	Resources   []api.ResourceData
+	// Business is the company on behalf of which the consent is granted, if
+	// any.
+	Business *user.Company
+	Error    string
+}
+
+// permissionOption is a permission displayed on the consent page.
+// Optional permissions can be deselected by the user.
+type permissionOption struct {
+	Permission api.ConsentPermission
+	Optional   bool
 }
 
 type authenticator struct {
-	tmpl           *template.Template
-	baseURL        string
-	userService    user.Service
-	consentService consent.Service
+	tmpl               *template.Template
+	baseURL            string
+	userService        user.Service
+	consentService     consent.Service
+	resourceService    resource.Service
+	userSessionManager UserSessionManager
+	approver           approver
 }
 
 func (a authenticator) authenticate(
@@ -94,13 +135,33 @@ func (a authenticator) authenticate(
 		if status, err := a.setUp(r, meta, session); status != goidc.StatusSuccess {
 			return status, err
 		}
-		session.StoreParameter(paramStepID, stepIDLogin)
+		session.StoreParameter(paramStepID, stepIDAutoApprove)
+	}
+
+	if session.StoredParameter(paramStepID) == stepIDAutoApprove {
+		isApproved, err := a.autoApprove(r, meta, session)
+		if err != nil {
+			return goidc.StatusFailure, err
+		}
+
+		if isApproved {
+			session.StoreParameter(paramStepID, stepIDFinishFlow)
+		} else {
+			session.StoreParameter(paramStepID, stepIDLogin)
+		}
 	}
 
 	if session.StoredParameter(paramStepID) == stepIDLogin {
 		if status, err := a.login(w, r, meta, session); status != goidc.StatusSuccess {
 			return status, err
 		}
+		session.StoreParameter(paramStepID, stepIDOTP)
+	}
+
+	if session.StoredParameter(paramStepID) == stepIDOTP {
+		if status, err := a.verifyOTP(w, r, meta, session); status != goidc.StatusSuccess {
+			return status, err
+		}
 		session.StoreParameter(paramStepID, stepIDConsent)
 	}
 
@@ -140,22 +201,91 @@ func (a authenticator) setUp(
 		return goidc.StatusFailure, err
 	}
 
-	if !consent.IsAwaitingAuthorization() {
+	if !consent.IsAwaitingAuthorization() && !consent.IsPreApproved() {
 		return goidc.StatusFailure, errors.New("consent not awaiting authorization")
 	}
 
-	// Convert permissions to []string for joining.
-	strPermissions := make([]string, len(consent.Permissions))
-	for i, permission := range consent.Permissions {
-		strPermissions[i] = string(permission)
-	}
-
 	session.StoreParameter(paramConsentID, consent.ID)
-	session.StoreParameter(paramPermissions, strings.Join(strPermissions, " "))
+	session.StoreParameter(paramPermissions, joinPermissions(consent.Permissions))
 	session.StoreParameter(paramConsentCPF, consent.UserCPF)
+	session.StoreParameter(paramConsentCNPJ, consent.BusinessCNPJ)
 	return goidc.StatusSuccess, nil
 }
 
+// autoApprove completes the authentication without user interaction when the
+// consent was pre approved or when the client is configured to have its
+// consents approved automatically.
+func (a authenticator) autoApprove(
+	r *http.Request,
+	meta api.RequestMeta,
+	session *goidc.AuthnSession,
+) (
+	bool,
+	error,
+) {
+	consentID := session.StoredParameter(paramConsentID).(string)
+	c, err := a.consentService.Fetch(r.Context(), meta, consentID)
+	if err != nil {
+		return false, err
+	}
+
+	if c.IsPreApproved() {
+		if err := a.consentService.ConsumePreApproval(r.Context(), consentID); err != nil {
+			return false, err
+		}
+		storeApproval(session, c.UserID, c.Permissions)
+		return true, nil
+	}
+
+	username, _ := session.StoredParameter(paramAutoApproveUsername).(string)
+	if username == "" {
+		return false, nil
+	}
+
+	api.Logger(r.Context()).Info("approving consent automatically",
+		slog.String("consent_id", consentID), slog.String("username", username))
+	approval, err := a.approver.approval(r.Context(), c, username, nil, nil)
+	if err != nil {
+		return false, err
+	}
+
+	if err := a.consentService.Authorize(
+		r.Context(),
+		consentID,
+		username,
+		approval.permissions,
+		approval.resourceIDs,
+	); err != nil {
+		return false, err
+	}
+
+	storeApproval(session, username, approval.permissions)
+	return true, nil
+}
+
+// storeApproval records the information required to finish the flow for a
+// consent authorized without user interaction.
+// No one-time password is verified in this case, so the authentication is
+// LOA2 even if the client requested LOA3.
+func storeApproval(
+	session *goidc.AuthnSession,
+	username string,
+	permissions []api.ConsentPermission,
+) {
+	session.StoreParameter(paramUserID, username)
+	session.StoreParameter(paramAuthTime, time.Now().UTC().Unix())
+	session.StoreParameter(paramACR, string(api.ACROpenInsuranceLOA2))
+	session.StoreParameter(paramPermissions, joinPermissions(permissions))
+}
+
+func joinPermissions(permissions []api.ConsentPermission) string {
+	strPermissions := make([]string, len(permissions))
+	for i, permission := range permissions {
+		strPermissions[i] = string(permission)
+	}
+	return strings.Join(strPermissions, " ")
+}
+
 func (a authenticator) login(
 	w http.ResponseWriter,
 	r *http.Request,
@@ -170,6 +300,17 @@ func (a authenticator) login(
 
 	isLogin := r.PostFormValue(loginFormParam)
 	if isLogin == "" {
+		// A user already logged in doesn't need to inform the credentials
+		// again as long as they belong to the consent's owner and could still
+		// log in with them.
+		if userSession, ok := a.activeUserSession(r, session); ok {
+			user, err := a.userService.User(r.Context(), userSession.Username)
+			if err == nil && user.CPF == session.StoredParameter(paramConsentCPF) &&
+				!user.IsLocked() && !user.IsPasswordExpired() {
+				return a.finishLogin(w, session, user, userSession.AuthenticatedAt)
+			}
+		}
+
 		return a.executeTemplate(w, "login.html", authnPage{
 			CallbackID: session.CallbackID,
 		})
@@ -177,22 +318,16 @@ func (a authenticator) login(
 
 	if isLogin != "true" {
 		consentID := session.StoredParameter(paramConsentID).(string)
-		_ = a.consentService.Reject(
-			r.Context(),
-			meta,
-			consentID,
-			consent.RejectionInfo{
-				RejectedBy: api.ConsentRejectedByUSER,
-				Reason:     api.ConsentRejectedReasonCodeCUSTOMERMANUALLYREJECTED,
-			},
-		)
+		a.rejectConsent(r.Context(), meta, consentID, consent.RejectionInfo{
+			RejectedBy: api.ConsentRejectedByUSER,
+			Reason:     api.ConsentRejectedReasonCodeCUSTOMERMANUALLYREJECTED,
+		})
 
 		return goidc.StatusFailure, errors.New("consent not granted")
 	}
 
 	username := r.PostFormValue(usernameFormParam)
-	user, err := a.userService.User(username)
-	if err != nil {
+	if _, err := a.userService.User(r.Context(), username); err != nil {
 		return a.executeTemplate(w, "login.html", authnPage{
 			CallbackID: session.CallbackID,
 			Error:      "invalid username",
@@ -200,17 +335,208 @@ func (a authenticator) login(
 	}
 
 	password := r.PostFormValue(passwordFormParam)
-	if user.CPF != session.StoredParameter(paramConsentCPF) || password != correctPassword {
+	authenticatedUser, err := a.userService.Authenticate(r.Context(), username, password)
+	if err != nil {
+		return a.executeTemplate(w, "login.html", authnPage{
+			CallbackID: session.CallbackID,
+			Error:      loginErrorMessage(err),
+		})
+	}
+
+	if authenticatedUser.CPF != session.StoredParameter(paramConsentCPF) {
 		return a.executeTemplate(w, "login.html", authnPage{
 			CallbackID: session.CallbackID,
 			Error:      "invalid credentials",
 		})
 	}
 
-	session.StoreParameter(paramUserID, username)
+	authTime := time.Now().UTC()
+	if err := a.startUserSession(w, r, username, authTime); err != nil {
+		return goidc.StatusFailure, err
+	}
+
+	return a.finishLogin(w, session, authenticatedUser, authTime)
+}
+
+// loginErrorMessage returns the message displayed to the user when the
+// authentication fails.
+func loginErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, user.ErrUserLocked):
+		return "too many failed attempts, the account is locked"
+	case errors.Is(err, user.ErrPasswordExpired):
+		return "the password is expired"
+	default:
+		return "invalid credentials"
+	}
+}
+
+// activeUserSession returns the session of the user logged in, unless the
+// client requires the user to authenticate again.
+func (a authenticator) activeUserSession(
+	r *http.Request,
+	session *goidc.AuthnSession,
+) (
+	UserSession,
+	bool,
+) {
+	if session.Prompt == goidc.PromptTypeLogin {
+		return UserSession{}, false
+	}
+
+	userSession, ok := userSessionFromRequest(a.userSessionManager, r)
+	if !ok {
+		return UserSession{}, false
+	}
+
+	if session.MaxAuthnAgeSecs != nil {
+		maxAge := time.Duration(*session.MaxAuthnAgeSecs) * time.Second
+		if time.Now().UTC().After(userSession.AuthenticatedAt.Add(maxAge)) {
+			return UserSession{}, false
+		}
+	}
+
+	return userSession, true
+}
+
+// startUserSession creates a session for the user and sets the cookie that
+// references it, so the user is kept logged in for the next authorizations.
+func (a authenticator) startUserSession(
+	w http.ResponseWriter,
+	r *http.Request,
+	username string,
+	authTime time.Time,
+) error {
+	userSession := UserSession{
+		ID:              uuid.NewString(),
+		Username:        username,
+		AuthenticatedAt: authTime,
+		ExpiresAt:       authTime.Add(userSessionLifetimeSecs * time.Second),
+	}
+	if err := a.userSessionManager.save(r.Context(), userSession); err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     userSessionCookie,
+		Value:    userSession.ID,
+		Path:     "/",
+		Expires:  userSession.ExpiresAt,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	// The session cookie must be sent when clients redirect the user to the
+	// authorization endpoint, so it can't be strict. This one is only sent in
+	// requests started by MockIn itself and protects the logout.
+	http.SetCookie(w, &http.Cookie{
+		Name:     userSessionLogoutCookie,
+		Value:    userSession.ID,
+		Path:     "/",
+		Expires:  userSession.ExpiresAt,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return nil
+}
+
+func (a authenticator) finishLogin(
+	w http.ResponseWriter,
+	session *goidc.AuthnSession,
+	user user.User,
+	authTime time.Time,
+) (
+	goidc.AuthnStatus,
+	error,
+) {
+	// When the consent is for a business entity, the user must be one of its
+	// representatives. The user is told why the login can't proceed and can
+	// cancel, which rejects the consent.
+	cnpj := session.StoredParameter(paramConsentCNPJ).(string)
+	if cnpj != "" && !user.IsRepresentative(cnpj) {
+		return a.executeTemplate(w, "login.html", authnPage{
+			CallbackID: session.CallbackID,
+			Error:      "you are not a representative of the business entity of this consent",
+		})
+	}
+
+	session.StoreParameter(paramUserID, user.UserName)
+	session.StoreParameter(paramAuthTime, authTime.Unix())
 	return goidc.StatusSuccess, nil
 }
 
+// verifyOTP asks the user for a one-time password when the client requested
+// LOA3, otherwise the user is authenticated with LOA2.
+func (a authenticator) verifyOTP(
+	w http.ResponseWriter,
+	r *http.Request,
+	meta api.RequestMeta,
+	session *goidc.AuthnSession,
+) (
+	goidc.AuthnStatus,
+	error,
+) {
+	if !isLOA3Requested(session) {
+		session.StoreParameter(paramACR, string(api.ACROpenInsuranceLOA2))
+		return goidc.StatusSuccess, nil
+	}
+
+	_ = r.ParseForm()
+
+	isVerify := r.PostFormValue(verifyFormParam)
+	if isVerify == "" {
+		return a.executeTemplate(w, "otp.html", authnPage{
+			CallbackID: session.CallbackID,
+		})
+	}
+
+	if isVerify != "true" {
+		consentID := session.StoredParameter(paramConsentID).(string)
+		a.rejectConsent(r.Context(), meta, consentID, consent.RejectionInfo{
+			RejectedBy: api.ConsentRejectedByUSER,
+			Reason:     api.ConsentRejectedReasonCodeCUSTOMERMANUALLYREJECTED,
+		})
+		return goidc.StatusFailure, errors.New("consent not granted")
+	}
+
+	userID := session.StoredParameter(paramUserID).(string)
+	if !a.userService.IsOTPValid(r.Context(), userID, r.PostFormValue(otpFormParam)) {
+		return a.executeTemplate(w, "otp.html", authnPage{
+			CallbackID: session.CallbackID,
+			Error:      "invalid code",
+		})
+	}
+
+	session.StoreParameter(paramACR, string(api.ACROpenInsuranceLOA3))
+	return goidc.StatusSuccess, nil
+}
+
+// isLOA3Requested returns whether the client asked for LOA3 either through
+// acr_values or through the acr claim.
+func isLOA3Requested(session *goidc.AuthnSession) bool {
+	loa3 := string(api.ACROpenInsuranceLOA3)
+	if slices.Contains(strings.Split(session.ACRValues, " "), loa3) {
+		return true
+	}
+
+	if session.Claims == nil {
+		return false
+	}
+
+	for _, claims := range []map[string]goidc.ClaimObjectInfo{
+		session.Claims.IDToken,
+		session.Claims.UserInfo,
+	} {
+		acr, ok := claims[goidc.ClaimACR]
+		if ok && (acr.Value == loa3 || slices.Contains(acr.Values, loa3)) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (a authenticator) grantConsent(
 	w http.ResponseWriter,
 	r *http.Request,
@@ -227,60 +553,172 @@ func (a authenticator) grantConsent(
 	for _, p := range strings.Split(session.StoredParameter(paramPermissions).(string), " ") {
 		permissions = append(permissions, api.ConsentPermission(p))
 	}
+	userID := session.StoredParameter(paramUserID).(string)
 	isConsented := r.PostFormValue(consentFormParam)
 	if isConsented == "" {
-		return a.executeTemplate(w, "consent.html", authnPage{
-			CallbackID:  session.CallbackID,
-			Permissions: permissions,
-		})
+		return a.renderConsentPage(w, r, session, userID, permissions, "")
 	}
 
 	consentID := session.StoredParameter(paramConsentID).(string)
 
 	if isConsented != "true" {
-		_ = a.consentService.Reject(
-			r.Context(),
-			meta,
-			consentID,
-			consent.RejectionInfo{
-				RejectedBy: api.ConsentRejectedByUSER,
-				Reason:     api.ConsentRejectedReasonCodeCUSTOMERMANUALLYREJECTED,
-			},
-		)
+		a.rejectConsent(r.Context(), meta, consentID, consent.RejectionInfo{
+			RejectedBy: api.ConsentRejectedByUSER,
+			Reason:     api.ConsentRejectedReasonCodeCUSTOMERMANUALLYREJECTED,
+		})
 		return goidc.StatusFailure, errors.New("consent not granted")
 	}
 
-	if err := a.consentService.Authorize(r.Context(), consentID, permissions...); err != nil {
+	// Keep the mandatory permissions and the optional ones the user selected.
+	var grantedPermissions []api.ConsentPermission
+	hasOptionalPermissions, isOptionalPermissionGranted := false, false
+	for _, p := range permissions {
+		isOptional := consent.IsPermissionOptional(p)
+		isSelected := slices.Contains(r.PostForm[permissionsFormParam], string(p))
+		hasOptionalPermissions = hasOptionalPermissions || isOptional
+		isOptionalPermissionGranted = isOptionalPermissionGranted || (isOptional && isSelected)
+		if !isOptional || isSelected {
+			grantedPermissions = append(grantedPermissions, p)
+		}
+	}
+
+	// The mandatory permissions alone don't give access to any data.
+	if hasOptionalPermissions && !isOptionalPermissionGranted {
+		return a.renderConsentPage(w, r, session, userID, permissions, "select at least one permission")
+	}
+
+	// Only the selected resources covered by the granted permissions are shared.
+	consentableResources, err := a.resourceService.ConsentableResources(r.Context(), userID, grantedPermissions)
+	if err != nil {
+		return goidc.StatusFailure, err
+	}
+
+	var resourceIDs []string
+	for _, rs := range consentableResources {
+		if slices.Contains(r.PostForm[resourcesFormParam], rs.ResourceId) {
+			resourceIDs = append(resourceIDs, rs.ResourceId)
+		}
+	}
+
+	// A consent with no resource IDs gives access to all the resources, so at
+	// least one must be selected when there are any.
+	if len(consentableResources) != 0 && len(resourceIDs) == 0 {
+		return a.renderConsentPage(w, r, session, userID, permissions, "select at least one resource")
+	}
+
+	if err := a.consentService.Authorize(
+		r.Context(),
+		consentID,
+		userID,
+		grantedPermissions,
+		resourceIDs,
+	); err != nil {
 		return goidc.StatusFailure, err
 	}
+
+	// Keep track of the permissions actually granted so the scopes can be
+	// reduced accordingly.
+	session.StoreParameter(paramPermissions, joinPermissions(grantedPermissions))
 	return goidc.StatusSuccess, nil
 }
 
+// renderConsentPage displays the permissions requested and the resources of
+// the user they cover, so the user can choose what to share.
+func (a authenticator) renderConsentPage(
+	w http.ResponseWriter,
+	r *http.Request,
+	session *goidc.AuthnSession,
+	userID string,
+	permissions []api.ConsentPermission,
+	errorMessage string,
+) (
+	goidc.AuthnStatus,
+	error,
+) {
+	resources, err := a.resourceService.ConsentableResources(r.Context(), userID, permissions)
+	if err != nil {
+		return goidc.StatusFailure, err
+	}
+
+	var options []permissionOption
+	for _, p := range permissions {
+		options = append(options, permissionOption{
+			Permission: p,
+			Optional:   consent.IsPermissionOptional(p),
+		})
+	}
+	return a.executeTemplate(w, "consent.html", authnPage{
+		CallbackID:  session.CallbackID,
+		Permissions: options,
+		Resources:   resources,
+		Business:    a.business(r.Context(), session),
+		Error:       errorMessage,
+	})
+}
+
+// business returns the company the consent was requested for or nil if the
+// consent is for a personal account.
+func (a authenticator) business(
+	ctx context.Context,
+	session *goidc.AuthnSession,
+) *user.Company {
+	cnpj := session.StoredParameter(paramConsentCNPJ).(string)
+	if cnpj == "" {
+		return nil
+	}
+
+	company, err := a.userService.Company(ctx, cnpj)
+	if err != nil {
+		// The company is not registered, but its CNPJ can still be shown.
+		return &user.Company{CNPJ: cnpj}
+	}
+	return &company
+}
+
 func (a authenticator) finishFlow(
 	session *goidc.AuthnSession,
 ) (
 	goidc.AuthnStatus,
 	error,
 ) {
+	var permissions []api.ConsentPermission
+	for _, p := range strings.Split(session.StoredParameter(paramPermissions).(string), " ") {
+		permissions = append(permissions, api.ConsentPermission(p))
+	}
+
 	session.SetUserID(session.StoredParameter(paramUserID).(string))
-	// TODO: Grant scopes based on permissions.
-	session.GrantScopes(session.Scopes)
-	session.SetIDTokenClaimACR(api.ACROpenInsuranceLOA2)
-	session.SetIDTokenClaimAuthTime(int(time.Now().Unix()))
+	session.GrantScopes(api.GrantedScopes(session.Scopes, permissions))
+	acr := goidc.ACR(session.StoredParameter(paramACR).(string))
+	session.SetIDTokenClaimACR(acr)
+	session.SetIDTokenClaimAuthTime(int(session.StoredParameter(paramAuthTime).(int64)))
 
 	if session.Claims != nil {
 		if slices.Contains(session.Claims.IDTokenEssentials(), goidc.ClaimACR) {
-			session.SetIDTokenClaimACR(api.ACROpenInsuranceLOA2)
+			session.SetIDTokenClaimACR(acr)
 		}
 
 		if slices.Contains(session.Claims.UserInfoEssentials(), goidc.ClaimACR) {
-			session.SetUserInfoClaimACR(api.ACROpenInsuranceLOA2)
+			session.SetUserInfoClaimACR(acr)
 		}
 	}
 
 	return goidc.StatusSuccess, nil
 }
 
+// rejectConsent rejects the consent when the flow can't be completed. The flow
+// fails regardless, so an error rejecting the consent is only logged.
+func (a authenticator) rejectConsent(
+	ctx context.Context,
+	meta api.RequestMeta,
+	consentID string,
+	info consent.RejectionInfo,
+) {
+	if err := a.consentService.Reject(ctx, meta, consentID, info); err != nil {
+		api.Logger(ctx).Error("could not reject the consent",
+			slog.String("consent_id", consentID), slog.Any("error", err))
+	}
+}
+
 func (a authenticator) executeTemplate(
 	w http.ResponseWriter,
 	templateName string,
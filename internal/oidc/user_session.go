@@ -0,0 +1,181 @@
+package oidc
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	userSessionCookie       = "mockin_session"
+	userSessionLogoutCookie = "mockin_session_logout"
+	userSessionLifetimeSecs = 3600
+)
+
+// UserSession keeps a user logged in across authorization flows.
+type UserSession struct {
+	ID              string    `bson:"_id"`
+	Username        string    `bson:"username"`
+	AuthenticatedAt time.Time `bson:"authenticated_at"`
+	ExpiresAt       time.Time `bson:"expires_at"`
+}
+
+func (s UserSession) IsExpired() bool {
+	return time.Now().UTC().After(s.ExpiresAt)
+}
+
+// UserSessionManager stores the sessions of the users logged in.
+type UserSessionManager interface {
+	save(ctx context.Context, session UserSession) error
+	session(ctx context.Context, id string) (UserSession, error)
+	delete(ctx context.Context, id string) error
+	// DeleteExpired removes all the sessions that reached their expiration.
+	DeleteExpired(ctx context.Context) error
+}
+
+// MongoUserSessionManager keeps the user sessions in a mongo collection.
+type MongoUserSessionManager struct {
+	Collection *mongo.Collection
+}
+
+func NewMongoUserSessionManager(database *mongo.Database) MongoUserSessionManager {
+	return MongoUserSessionManager{
+		Collection: database.Collection("user_sessions"),
+	}
+}
+
+// CreateIndexes creates a TTL index so mongo removes the sessions once they
+// expire.
+func (manager MongoUserSessionManager) CreateIndexes(ctx context.Context) error {
+	_, err := manager.Collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+func (manager MongoUserSessionManager) save(
+	ctx context.Context,
+	session UserSession,
+) error {
+	shouldUpsert := true
+	filter := bson.D{{Key: "_id", Value: session.ID}}
+	if _, err := manager.Collection.ReplaceOne(
+		ctx,
+		filter,
+		session,
+		&options.ReplaceOptions{Upsert: &shouldUpsert},
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (manager MongoUserSessionManager) session(
+	ctx context.Context,
+	id string,
+) (
+	UserSession,
+	error,
+) {
+	filter := bson.D{{Key: "_id", Value: id}}
+	result := manager.Collection.FindOne(ctx, filter)
+	if result.Err() != nil {
+		return UserSession{}, result.Err()
+	}
+
+	var session UserSession
+	if err := result.Decode(&session); err != nil {
+		return UserSession{}, err
+	}
+
+	return session, nil
+}
+
+func (manager MongoUserSessionManager) delete(
+	ctx context.Context,
+	id string,
+) error {
+	filter := bson.D{{Key: "_id", Value: id}}
+	if _, err := manager.Collection.DeleteOne(ctx, filter); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (manager MongoUserSessionManager) DeleteExpired(ctx context.Context) error {
+	filter := bson.D{{Key: "expires_at", Value: bson.D{
+		{Key: "$lt", Value: time.Now().UTC()},
+	}}}
+	if _, err := manager.Collection.DeleteMany(ctx, filter); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// userSessionFromRequest returns the user session referenced by the cookie in
+// the request if it's still valid.
+func userSessionFromRequest(
+	manager UserSessionManager,
+	r *http.Request,
+) (
+	UserSession,
+	bool,
+) {
+	cookie, err := r.Cookie(userSessionCookie)
+	if err != nil {
+		return UserSession{}, false
+	}
+
+	session, err := manager.session(r.Context(), cookie.Value)
+	if err != nil || session.IsExpired() {
+		return UserSession{}, false
+	}
+
+	return session, true
+}
+
+// LogoutHandler ends the session of the user making the request.
+// The session is identified by the strict cookie, which browsers don't send in
+// cross-site requests, so other sites can't log the user out.
+func LogoutHandler(manager UserSessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionCookie, err := r.Cookie(userSessionCookie)
+		if err == nil {
+			logoutCookie, err := r.Cookie(userSessionLogoutCookie)
+			if err != nil || logoutCookie.Value != sessionCookie.Value {
+				api.ResponseErrorMiddleware(w, r, api.NewError("FORBIDDEN", http.StatusForbidden,
+					"the logout must be requested from the same site"))
+				return
+			}
+
+			if err := manager.delete(r.Context(), sessionCookie.Value); err != nil {
+				api.Logger(r.Context()).Error("could not delete the user session",
+					slog.Any("error", err))
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+
+		for _, name := range []string{userSessionCookie, userSessionLogoutCookie} {
+			http.SetCookie(w, &http.Cookie{
+				Name:     name,
+				Value:    "",
+				Path:     "/",
+				MaxAge:   -1,
+				Secure:   true,
+				HttpOnly: true,
+			})
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
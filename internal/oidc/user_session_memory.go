@@ -0,0 +1,59 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var errUserSessionNotFound = errors.New("user session not found")
+
+// MemoryUserSessionManager keeps the user sessions in memory, so they are lost
+// when the server stops.
+type MemoryUserSessionManager struct {
+	mu       sync.RWMutex
+	sessions map[string]UserSession
+}
+
+func NewMemoryUserSessionManager() *MemoryUserSessionManager {
+	return &MemoryUserSessionManager{
+		sessions: make(map[string]UserSession),
+	}
+}
+
+func (manager *MemoryUserSessionManager) save(_ context.Context, session UserSession) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.sessions[session.ID] = session
+	return nil
+}
+
+func (manager *MemoryUserSessionManager) session(_ context.Context, id string) (UserSession, error) {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+	session, ok := manager.sessions[id]
+	if !ok {
+		return UserSession{}, errUserSessionNotFound
+	}
+
+	return session, nil
+}
+
+func (manager *MemoryUserSessionManager) delete(_ context.Context, id string) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	delete(manager.sessions, id)
+	return nil
+}
+
+func (manager *MemoryUserSessionManager) DeleteExpired(_ context.Context) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	for id, session := range manager.sessions {
+		if session.IsExpired() {
+			delete(manager.sessions, id)
+		}
+	}
+
+	return nil
+}
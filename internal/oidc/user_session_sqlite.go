@@ -0,0 +1,95 @@
+package oidc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+)
+
+// SQLiteUserSessionManager keeps the user sessions in a SQLite table.
+type SQLiteUserSessionManager struct {
+	db *sql.DB
+}
+
+func NewSQLiteUserSessionManager(db *sql.DB) SQLiteUserSessionManager {
+	return SQLiteUserSessionManager{
+		db: db,
+	}
+}
+
+// CreateTables creates the user sessions table if it doesn't exist yet.
+func (manager SQLiteUserSessionManager) CreateTables(ctx context.Context) error {
+	return api.SQLiteExec(
+		ctx,
+		manager.db,
+		`CREATE TABLE IF NOT EXISTS user_sessions (
+			id TEXT PRIMARY KEY,
+			username TEXT NOT NULL,
+			authenticated_at INTEGER NOT NULL,
+			expires_at INTEGER NOT NULL
+		)`,
+	)
+}
+
+func (manager SQLiteUserSessionManager) save(
+	ctx context.Context,
+	session UserSession,
+) error {
+	_, err := manager.db.ExecContext(
+		ctx,
+		`INSERT INTO user_sessions (id, username, authenticated_at, expires_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			username = excluded.username,
+			authenticated_at = excluded.authenticated_at,
+			expires_at = excluded.expires_at`,
+		session.ID,
+		session.Username,
+		session.AuthenticatedAt.Unix(),
+		session.ExpiresAt.Unix(),
+	)
+	return err
+}
+
+func (manager SQLiteUserSessionManager) session(
+	ctx context.Context,
+	id string,
+) (
+	UserSession,
+	error,
+) {
+	var authenticatedAt, expiresAt int64
+	session := UserSession{ID: id}
+	err := manager.db.QueryRowContext(
+		ctx,
+		`SELECT username, authenticated_at, expires_at FROM user_sessions WHERE id = ?`,
+		id,
+	).Scan(&session.Username, &authenticatedAt, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return UserSession{}, errUserSessionNotFound
+	}
+	if err != nil {
+		return UserSession{}, err
+	}
+
+	session.AuthenticatedAt = time.Unix(authenticatedAt, 0).UTC()
+	session.ExpiresAt = time.Unix(expiresAt, 0).UTC()
+	return session, nil
+}
+
+func (manager SQLiteUserSessionManager) delete(ctx context.Context, id string) error {
+	_, err := manager.db.ExecContext(ctx, `DELETE FROM user_sessions WHERE id = ?`, id)
+	return err
+}
+
+func (manager SQLiteUserSessionManager) DeleteExpired(ctx context.Context) error {
+	_, err := manager.db.ExecContext(
+		ctx,
+		`DELETE FROM user_sessions WHERE expires_at < ?`,
+		time.Now().Unix(),
+	)
+	return err
+}
@@ -20,6 +20,8 @@ import (
 
 const (
 	HeaderClientCert = "X-Client-Cert"
+
+	storeKeyConsentExpiresAt = "consent_expires_at"
 )
 
 func HandleGrantFunc(consentService consent.Service) goidc.HandleGrantFunc {
@@ -42,6 +44,10 @@ func HandleGrantFunc(consentService consent.Service) goidc.HandleGrantFunc {
 				"consent is not authorized")
 		}
 
+		if gi.Store == nil {
+			gi.Store = make(map[string]any)
+		}
+		gi.Store[storeKeyConsentExpiresAt] = consent.ExpiresAt.Unix()
 		return nil
 	}
 }
@@ -53,8 +59,19 @@ func ShoudIssueRefreshTokenFunc() goidc.ShouldIssueRefreshTokenFunc {
 	}
 }
 
+// ClientCertFunc returns the certificate presented by the client.
+// When MockIn terminates TLS itself, it is the certificate verified during the
+// handshake and X-Client-Cert is ignored. Otherwise, it is the certificate
+// forwarded by the reverse proxy in X-Client-Cert.
 func ClientCertFunc() goidc.ClientCertFunc {
 	return func(r *http.Request) (*x509.Certificate, error) {
+		if r.TLS != nil {
+			if len(r.TLS.PeerCertificates) == 0 {
+				return nil, errors.New("the client certificate was not informed")
+			}
+			return r.TLS.PeerCertificates[0], nil
+		}
+
 		rawClientCert := r.Header.Get(HeaderClientCert)
 		if rawClientCert == "" {
 			return nil, errors.New("the client certificate was not informed")
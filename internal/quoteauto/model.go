@@ -1,6 +1,7 @@
 package quoteauto
 
 import (
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,16 +14,48 @@ type Lead struct {
 	Status               api.QuoteStatus       `bson:"status"`
 	RejectionReason      string                `bson:"rejection_reason"`
 	StatusUpdateDateTime time.Time             `bson:"updated_at"`
+	ExpiresAt            time.Time             `bson:"expires_at"`
 	Data                 api.QuoteAutoLeadData `bson:"data"`
 }
 
+// IsExpired returns true if the lead is still being processed and reached the
+// expiration date.
+func (l Lead) IsExpired() bool {
+	return l.Status == api.QuoteStatusRCVD && time.Now().UTC().After(l.ExpiresAt)
+}
+
 type Quote struct {
 	ID                   string            `bson:"_id"`
 	ConsentID            string            `bson:"consent_id"`
 	Status               api.QuoteStatus   `bson:"status"`
 	RejectionReason      string            `bson:"rejection_reason"`
 	StatusUpdateDateTime time.Time         `bson:"updated_at"`
+	ExpiresAt            time.Time         `bson:"expires_at"`
 	Data                 api.QuoteAutoData `bson:"data"`
+	// Version is incremented every time the quote is saved, so concurrent
+	// modifications can be detected.
+	Version int `bson:"version"`
+}
+
+// IsExpired returns true if the quote was not finalized by the client and
+// reached the expiration date.
+func (q Quote) IsExpired() bool {
+	return slices.Contains(quoteStatusesInProgress, q.Status) &&
+		time.Now().UTC().After(q.ExpiresAt)
+}
+
+// Snapshot is the state of all the leads and quotes.
+type Snapshot struct {
+	Leads  []Lead  `json:"leads"`
+	Quotes []Quote `json:"quotes"`
+}
+
+// quoteStatusesInProgress are the statuses for which a quote can still be
+// modified.
+var quoteStatusesInProgress = []api.QuoteStatus{
+	api.QuoteStatusRCVD,
+	api.QuoteStatusEVAL,
+	api.QuoteStatusACPT,
 }
 
 func newLead(req api.CreateQuoteAutoLeadRequest) Lead {
@@ -31,6 +64,7 @@ func newLead(req api.CreateQuoteAutoLeadRequest) Lead {
 		ConsentID:            req.Data.ConsentId,
 		Status:               api.QuoteStatusRCVD,
 		StatusUpdateDateTime: time.Now().UTC(),
+		ExpiresAt:            req.Data.ExpirationDateTime.Time,
 		Data:                 req.Data,
 	}
 
@@ -72,6 +106,7 @@ func newQuote(req api.CreateQuoteAutoRequest) Quote {
 		ConsentID:            req.Data.ConsentId,
 		Status:               api.QuoteStatusRCVD,
 		StatusUpdateDateTime: time.Now().UTC(),
+		ExpiresAt:            req.Data.ExpirationDateTime.Time,
 		Data:                 req.Data,
 	}
 
@@ -2,6 +2,7 @@ package quoteauto
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -11,6 +12,15 @@ import (
 	"github.com/luikyv/go-open-insurance/internal/webhook"
 )
 
+// maxSaveAttempts is how many times a quote is fetched and modified again when
+// a concurrent modification is detected.
+const maxSaveAttempts = 3
+
+// errConcurrentModification is returned when the quote was modified by another
+// request while this one was being processed.
+var errConcurrentModification = api.NewError("CONFLICT", http.StatusConflict,
+	"the quote was modified by another request, try again")
+
 type Service struct {
 	storage        Storage
 	webhookService webhook.Service
@@ -26,6 +36,71 @@ func NewService(
 	}
 }
 
+// ExpireQuotes cancels all the leads and quotes that were not finalized before
+// reaching their expiration date.
+func (s Service) ExpireQuotes(ctx context.Context) error {
+	leads, err := s.storage.expiredLeads(ctx)
+	if err != nil {
+		api.Logger(ctx).Error("could not fetch the expired auto quote leads",
+			slog.String("error", err.Error()))
+		return api.ErrInternal
+	}
+
+	// A record that can't be cancelled must not prevent the others from being
+	// cancelled.
+	var errs []error
+	for _, lead := range leads {
+		api.Logger(ctx).Debug("cancelling expired auto quote lead",
+			slog.String("consent_id", lead.ConsentID))
+		if err := s.revokeLead(ctx, &lead); err != nil {
+			api.Logger(ctx).Error("could not cancel the expired auto quote lead",
+				slog.String("consent_id", lead.ConsentID), slog.Any("error", err))
+			errs = append(errs, fmt.Errorf("could not cancel the expired auto quote lead %s: %w", lead.ConsentID, err))
+		}
+	}
+
+	quotes, err := s.storage.expiredQuotes(ctx)
+	if err != nil {
+		api.Logger(ctx).Error("could not fetch the expired auto quotes",
+			slog.String("error", err.Error()))
+		return errors.Join(append(errs, api.ErrInternal)...)
+	}
+
+	for _, quote := range quotes {
+		api.Logger(ctx).Debug("cancelling expired auto quote",
+			slog.String("consent_id", quote.ConsentID))
+		// If the quote was modified in the meantime, it is left for the next
+		// run, when it will be fetched again if still expired.
+		if err := s.cancelQuote(ctx, &quote); err != nil && !errors.Is(err, errConcurrentModification) {
+			api.Logger(ctx).Error("could not cancel the expired auto quote",
+				slog.String("consent_id", quote.ConsentID), slog.Any("error", err))
+			errs = append(errs, fmt.Errorf("could not cancel the expired auto quote %s: %w", quote.ConsentID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Snapshot returns all the leads and quotes.
+func (s Service) Snapshot(ctx context.Context) (Snapshot, error) {
+	leads, err := s.storage.allLeads(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	quotes, err := s.storage.allQuotes(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	return Snapshot{Leads: leads, Quotes: quotes}, nil
+}
+
+// Restore replaces all the leads and quotes by the ones in the snapshot.
+func (s Service) Restore(ctx context.Context, snapshot Snapshot) error {
+	return s.storage.replaceAll(ctx, snapshot.Leads, snapshot.Quotes)
+}
+
 func (s Service) createLead(
 	ctx context.Context,
 	meta api.RequestMeta,
@@ -118,16 +193,24 @@ func (s Service) quoteStatus(
 	api.GetQuoteAutoStatusResponse,
 	error,
 ) {
-	quote, err := s.quoteByConsentID(ctx, consentID)
-	if err != nil {
-		return api.GetQuoteAutoStatusResponse{}, err
+	// Concurrent status polls may try to move the quote forward at the same
+	// time, in which case the quote is fetched again.
+	for attempt := 1; ; attempt++ {
+		quote, err := s.quoteByConsentID(ctx, consentID)
+		if err != nil {
+			return api.GetQuoteAutoStatusResponse{}, err
+		}
+
+		err = s.modifyQuote(ctx, meta, &quote)
+		if errors.Is(err, errConcurrentModification) && attempt < maxSaveAttempts {
+			continue
+		}
+		if err != nil {
+			return api.GetQuoteAutoStatusResponse{}, err
+		}
+
+		return newGetQuoteAutoStatusResponse(meta, quote), nil
 	}
-
-	if err := s.modifyQuote(ctx, meta, &quote); err != nil {
-		return api.GetQuoteAutoStatusResponse{}, err
-	}
-
-	return newGetQuoteAutoStatusResponse(meta, quote), nil
 }
 
 func (s Service) modifyQuote(
@@ -135,6 +218,11 @@ func (s Service) modifyQuote(
 	meta api.RequestMeta,
 	quote *Quote,
 ) error {
+	if quote.IsExpired() {
+		api.Logger(ctx).Debug("auto quote reached expiration, moving to cancelled")
+		return s.cancelQuote(ctx, quote)
+	}
+
 	quoteWasModified := false
 
 	// The conformance suite rejection test sends the term end date prior to the
@@ -159,12 +247,16 @@ func (s Service) modifyQuote(
 		return nil
 	}
 
+	if err := s.saveQuote(ctx, quote); err != nil {
+		return err
+	}
+
 	s.webhookService.Notify(
 		ctx,
 		meta.ClientID,
 		fmt.Sprintf("/quote/v1/request/%s/quote-status", quote.ConsentID),
 	)
-	return s.saveQuote(ctx, quote)
+	return nil
 }
 
 func (s Service) patchQuote(
@@ -238,26 +330,42 @@ func (s Service) quoteByConsentID(
 func (s Service) validateCreateQuoteRequest(
 	_ context.Context,
 	meta api.RequestMeta,
-	_ api.CreateQuoteAutoRequest,
+	req api.CreateQuoteAutoRequest,
 ) error {
 	if meta.Error != nil {
 		return api.NewError("NAO_INFORMADO", http.StatusUnprocessableEntity,
 			meta.Error.Error())
 	}
 
+	if err := validateCustomer("data.quoteCustomer", req.Data.QuoteCustomer); err != nil {
+		return err
+	}
+
+	if req.Data.HistoricalData != nil && req.Data.HistoricalData.Customer != nil {
+		return validateCustomer("data.historicalData.customer", *req.Data.HistoricalData.Customer)
+	}
+
 	return nil
 }
 
 func (s Service) validateLead(
 	_ context.Context,
 	meta api.RequestMeta,
-	_ Lead,
+	lead Lead,
 ) error {
 	if meta.Error != nil {
 		return api.NewError("NAO_INFORMADO", http.StatusUnprocessableEntity,
 			meta.Error.Error())
 	}
 
+	if err := validateCustomer("data.quoteCustomer", lead.Data.QuoteCustomer); err != nil {
+		return err
+	}
+
+	if lead.Data.HistoricalData != nil && lead.Data.HistoricalData.Customer != nil {
+		return validateCustomer("data.historicalData.customer", *lead.Data.HistoricalData.Customer)
+	}
+
 	return nil
 }
 
@@ -273,13 +381,37 @@ func (s Service) validateLeadRevocation(
 	return nil
 }
 
+// validateCustomer verifies the documents informed for the customer at the
+// field informed.
+func validateCustomer(field string, customer api.QuoteCustomerData) error {
+	personal, err := customer.AsQuotePersonalCustomerData()
+	if err != nil || personal.IdentificationData == nil {
+		return nil
+	}
+
+	if err := api.ValidateCPF(field+".identificationData.cpfNumber", personal.IdentificationData.CpfNumber); err != nil {
+		return err
+	}
+
+	if cnpj := personal.IdentificationData.CompanyInfo.CnpjNumber; cnpj != "" {
+		return api.ValidateCNPJ(field+".identificationData.companyInfo.cnpjNumber", cnpj)
+	}
+
+	return nil
+}
+
 func (s Service) saveQuote(
 	ctx context.Context,
 	quote *Quote,
 ) error {
 
 	quote.StatusUpdateDateTime = time.Now().UTC()
-	if err := s.storage.saveQuote(ctx, *quote); err != nil {
+	if err := s.storage.saveQuote(ctx, quote); err != nil {
+		if errors.Is(err, errQuoteVersionConflict) {
+			api.Logger(ctx).Debug("the auto quote was modified concurrently",
+				slog.String("consent_id", quote.ConsentID), slog.Int("version", quote.Version))
+			return errConcurrentModification
+		}
 		api.Logger(ctx).Error("could not save auto quote",
 			slog.String("error", err.Error()))
 		return api.ErrInternal
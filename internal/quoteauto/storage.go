@@ -2,100 +2,36 @@ package quoteauto
 
 import (
 	"context"
-
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"errors"
 )
 
-type Storage struct {
-	quoteLeadCollection *mongo.Collection
-	quoteCollection     *mongo.Collection
-}
-
-func NewStorage(db *mongo.Database) Storage {
-	return Storage{
-		quoteLeadCollection: db.Collection("auto_quote_leads"),
-		quoteCollection:     db.Collection("auto_quotes"),
-	}
-}
-
-func (st Storage) saveLead(
-	ctx context.Context,
-	lead Lead,
-) error {
-	shouldUpsert := true
-	filter := bson.D{{Key: "_id", Value: lead.ID}}
-	if _, err := st.quoteLeadCollection.ReplaceOne(
-		ctx,
-		filter,
-		lead,
-		&options.ReplaceOptions{Upsert: &shouldUpsert},
-	); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (st Storage) fetchLeadByConsentID(
-	ctx context.Context,
-	id string,
-) (
-	Lead,
-	error,
-) {
-	filter := bson.D{{Key: "consent_id", Value: id}}
-
-	result := st.quoteLeadCollection.FindOne(ctx, filter)
-	if result.Err() != nil {
-		return Lead{}, result.Err()
-	}
-
-	var lead Lead
-	if err := result.Decode(&lead); err != nil {
-		return Lead{}, err
-	}
-
-	return lead, nil
-}
-
-func (st Storage) saveQuote(
-	ctx context.Context,
-	quote Quote,
-) error {
-	shouldUpsert := true
-	filter := bson.D{{Key: "_id", Value: quote.ID}}
-	if _, err := st.quoteCollection.ReplaceOne(
-		ctx,
-		filter,
-		quote,
-		&options.ReplaceOptions{Upsert: &shouldUpsert},
-	); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (st Storage) fetchQuoteByConsentID(
-	ctx context.Context,
-	id string,
-) (
-	Quote,
-	error,
-) {
-	filter := bson.D{{Key: "consent_id", Value: id}}
-
-	result := st.quoteCollection.FindOne(ctx, filter)
-	if result.Err() != nil {
-		return Quote{}, result.Err()
-	}
-
-	var quote Quote
-	if err := result.Decode(&quote); err != nil {
-		return Quote{}, err
-	}
+var (
+	errLeadNotFound  = errors.New("auto quote lead not found")
+	errQuoteNotFound = errors.New("auto quote not found")
+	// errQuoteVersionConflict is returned when saving a quote that was saved by
+	// someone else since it was fetched.
+	errQuoteVersionConflict = errors.New("auto quote was modified concurrently")
+)
 
-	return quote, nil
+// Storage persists the auto quote leads and quotes.
+// The implementations return errLeadNotFound and errQuoteNotFound when
+// fetching records that don't exist.
+type Storage interface {
+	saveLead(ctx context.Context, lead Lead) error
+	fetchLeadByConsentID(ctx context.Context, id string) (Lead, error)
+	// saveQuote inserts the quote if its version is zero, otherwise it replaces
+	// the stored quote only if it still has the same version, returning
+	// errQuoteVersionConflict if not.
+	// On success, the version of the quote is incremented.
+	saveQuote(ctx context.Context, quote *Quote) error
+	fetchQuoteByConsentID(ctx context.Context, id string) (Quote, error)
+	// expiredLeads returns the leads still being processed that reached their
+	// expiration date.
+	expiredLeads(ctx context.Context) ([]Lead, error)
+	// expiredQuotes returns the quotes not finalized by the client that
+	// reached their expiration date.
+	expiredQuotes(ctx context.Context) ([]Quote, error)
+	allLeads(ctx context.Context) ([]Lead, error)
+	allQuotes(ctx context.Context) ([]Quote, error)
+	replaceAll(ctx context.Context, leads []Lead, quotes []Quote) error
 }
@@ -0,0 +1,130 @@
+package quoteauto
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// MemoryStorage keeps the leads and quotes in memory, so they are lost when
+// the server stops.
+// The records are kept in the order they were first saved.
+type MemoryStorage struct {
+	mu     sync.RWMutex
+	leads  []Lead
+	quotes []Quote
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{}
+}
+
+func (st *MemoryStorage) saveLead(_ context.Context, lead Lead) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	i := slices.IndexFunc(st.leads, func(l Lead) bool {
+		return l.ID == lead.ID
+	})
+	if i == -1 {
+		st.leads = append(st.leads, lead)
+		return nil
+	}
+
+	st.leads[i] = lead
+	return nil
+}
+
+func (st *MemoryStorage) fetchLeadByConsentID(_ context.Context, id string) (Lead, error) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	i := slices.IndexFunc(st.leads, func(l Lead) bool {
+		return l.ConsentID == id
+	})
+	if i == -1 {
+		return Lead{}, errLeadNotFound
+	}
+
+	return st.leads[i], nil
+}
+
+func (st *MemoryStorage) saveQuote(_ context.Context, quote *Quote) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	i := slices.IndexFunc(st.quotes, func(q Quote) bool {
+		return q.ID == quote.ID
+	})
+	if i == -1 {
+		if quote.Version != 0 {
+			return errQuoteVersionConflict
+		}
+		quote.Version++
+		st.quotes = append(st.quotes, *quote)
+		return nil
+	}
+
+	if st.quotes[i].Version != quote.Version {
+		return errQuoteVersionConflict
+	}
+	quote.Version++
+	st.quotes[i] = *quote
+	return nil
+}
+
+func (st *MemoryStorage) fetchQuoteByConsentID(_ context.Context, id string) (Quote, error) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	i := slices.IndexFunc(st.quotes, func(q Quote) bool {
+		return q.ConsentID == id
+	})
+	if i == -1 {
+		return Quote{}, errQuoteNotFound
+	}
+
+	return st.quotes[i], nil
+}
+
+func (st *MemoryStorage) expiredLeads(_ context.Context) ([]Lead, error) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	var leads []Lead
+	for _, lead := range st.leads {
+		if lead.IsExpired() {
+			leads = append(leads, lead)
+		}
+	}
+
+	return leads, nil
+}
+
+func (st *MemoryStorage) expiredQuotes(_ context.Context) ([]Quote, error) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	var quotes []Quote
+	for _, quote := range st.quotes {
+		if quote.IsExpired() {
+			quotes = append(quotes, quote)
+		}
+	}
+
+	return quotes, nil
+}
+
+func (st *MemoryStorage) allLeads(_ context.Context) ([]Lead, error) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return append([]Lead{}, st.leads...), nil
+}
+
+func (st *MemoryStorage) allQuotes(_ context.Context) ([]Quote, error) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return append([]Quote{}, st.quotes...), nil
+}
+
+func (st *MemoryStorage) replaceAll(_ context.Context, leads []Lead, quotes []Quote) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.leads = slices.Clone(leads)
+	st.quotes = slices.Clone(quotes)
+	return nil
+}
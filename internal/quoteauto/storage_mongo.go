@@ -0,0 +1,194 @@
+package quoteauto
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStorage keeps the leads and quotes in mongo collections.
+type MongoStorage struct {
+	quoteLeadCollection *mongo.Collection
+	quoteCollection     *mongo.Collection
+}
+
+func NewMongoStorage(db *mongo.Database) MongoStorage {
+	return MongoStorage{
+		quoteLeadCollection: db.Collection("auto_quote_leads"),
+		quoteCollection:     db.Collection("auto_quotes"),
+	}
+}
+
+func (st MongoStorage) saveLead(
+	ctx context.Context,
+	lead Lead,
+) error {
+	shouldUpsert := true
+	filter := bson.D{{Key: "_id", Value: lead.ID}}
+	if _, err := st.quoteLeadCollection.ReplaceOne(
+		ctx,
+		filter,
+		lead,
+		&options.ReplaceOptions{Upsert: &shouldUpsert},
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (st MongoStorage) fetchLeadByConsentID(
+	ctx context.Context,
+	id string,
+) (
+	Lead,
+	error,
+) {
+	filter := bson.D{{Key: "consent_id", Value: id}}
+
+	result := st.quoteLeadCollection.FindOne(ctx, filter)
+	if result.Err() != nil {
+		if errors.Is(result.Err(), mongo.ErrNoDocuments) {
+			return Lead{}, errLeadNotFound
+		}
+		return Lead{}, result.Err()
+	}
+
+	var lead Lead
+	if err := result.Decode(&lead); err != nil {
+		return Lead{}, err
+	}
+
+	return lead, nil
+}
+
+func (st MongoStorage) saveQuote(
+	ctx context.Context,
+	quote *Quote,
+) error {
+	updated := *quote
+	updated.Version++
+
+	// A new quote is upserted. If it was inserted concurrently, the filter
+	// won't match and the upsert fails with a duplicate key error.
+	// Quotes stored before versioning was introduced have no version.
+	if quote.Version == 0 {
+		shouldUpsert := true
+		filter := bson.D{
+			{Key: "_id", Value: quote.ID},
+			{Key: "version", Value: bson.D{{Key: "$in", Value: bson.A{0, nil}}}},
+		}
+		if _, err := st.quoteCollection.ReplaceOne(
+			ctx,
+			filter,
+			updated,
+			&options.ReplaceOptions{Upsert: &shouldUpsert},
+		); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return errQuoteVersionConflict
+			}
+			return err
+		}
+
+		quote.Version = updated.Version
+		return nil
+	}
+
+	filter := bson.D{
+		{Key: "_id", Value: quote.ID},
+		{Key: "version", Value: quote.Version},
+	}
+	result, err := st.quoteCollection.ReplaceOne(ctx, filter, updated)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errQuoteVersionConflict
+	}
+
+	quote.Version = updated.Version
+	return nil
+}
+
+func (st MongoStorage) fetchQuoteByConsentID(
+	ctx context.Context,
+	id string,
+) (
+	Quote,
+	error,
+) {
+	filter := bson.D{{Key: "consent_id", Value: id}}
+
+	result := st.quoteCollection.FindOne(ctx, filter)
+	if result.Err() != nil {
+		if errors.Is(result.Err(), mongo.ErrNoDocuments) {
+			return Quote{}, errQuoteNotFound
+		}
+		return Quote{}, result.Err()
+	}
+
+	var quote Quote
+	if err := result.Decode(&quote); err != nil {
+		return Quote{}, err
+	}
+
+	return quote, nil
+}
+
+func (st MongoStorage) expiredLeads(ctx context.Context) ([]Lead, error) {
+	filter := bson.D{
+		{Key: "status", Value: api.QuoteStatusRCVD},
+		{Key: "expires_at", Value: bson.D{{Key: "$lt", Value: time.Now().UTC()}}},
+	}
+
+	cursor, err := st.quoteLeadCollection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var leads []Lead
+	if err := cursor.All(ctx, &leads); err != nil {
+		return nil, err
+	}
+
+	return leads, nil
+}
+
+func (st MongoStorage) expiredQuotes(ctx context.Context) ([]Quote, error) {
+	filter := bson.D{
+		{Key: "status", Value: bson.D{{Key: "$in", Value: quoteStatusesInProgress}}},
+		{Key: "expires_at", Value: bson.D{{Key: "$lt", Value: time.Now().UTC()}}},
+	}
+
+	cursor, err := st.quoteCollection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var quotes []Quote
+	if err := cursor.All(ctx, &quotes); err != nil {
+		return nil, err
+	}
+
+	return quotes, nil
+}
+
+func (st MongoStorage) allLeads(ctx context.Context) ([]Lead, error) {
+	return api.FindAll[Lead](ctx, st.quoteLeadCollection)
+}
+
+func (st MongoStorage) allQuotes(ctx context.Context) ([]Quote, error) {
+	return api.FindAll[Quote](ctx, st.quoteCollection)
+}
+
+func (st MongoStorage) replaceAll(ctx context.Context, leads []Lead, quotes []Quote) error {
+	if err := api.ReplaceAll(ctx, st.quoteLeadCollection, leads); err != nil {
+		return err
+	}
+	return api.ReplaceAll(ctx, st.quoteCollection, quotes)
+}
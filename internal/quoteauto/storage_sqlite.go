@@ -0,0 +1,227 @@
+package quoteauto
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+)
+
+// SQLiteStorage keeps the leads and quotes in SQLite tables.
+type SQLiteStorage struct {
+	db *sql.DB
+}
+
+func NewSQLiteStorage(db *sql.DB) SQLiteStorage {
+	return SQLiteStorage{
+		db: db,
+	}
+}
+
+// CreateTables creates the leads and quotes tables if they don't exist yet.
+func (st SQLiteStorage) CreateTables(ctx context.Context) error {
+	return api.SQLiteExec(
+		ctx,
+		st.db,
+		`CREATE TABLE IF NOT EXISTS auto_quote_leads (
+			id TEXT PRIMARY KEY,
+			consent_id TEXT NOT NULL,
+			status TEXT NOT NULL,
+			expires_at INTEGER NOT NULL,
+			data TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS auto_quote_leads_consent_id ON auto_quote_leads (consent_id)`,
+		`CREATE TABLE IF NOT EXISTS auto_quotes (
+			id TEXT PRIMARY KEY,
+			consent_id TEXT NOT NULL,
+			status TEXT NOT NULL,
+			expires_at INTEGER NOT NULL,
+			version INTEGER NOT NULL,
+			data TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS auto_quotes_consent_id ON auto_quotes (consent_id)`,
+	)
+}
+
+func (st SQLiteStorage) saveLead(ctx context.Context, lead Lead) error {
+	data, err := json.Marshal(lead)
+	if err != nil {
+		return err
+	}
+
+	_, err = st.db.ExecContext(
+		ctx,
+		`INSERT INTO auto_quote_leads (id, consent_id, status, expires_at, data)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			consent_id = excluded.consent_id,
+			status = excluded.status,
+			expires_at = excluded.expires_at,
+			data = excluded.data`,
+		lead.ID,
+		lead.ConsentID,
+		lead.Status,
+		lead.ExpiresAt.Unix(),
+		data,
+	)
+	return err
+}
+
+func (st SQLiteStorage) fetchLeadByConsentID(ctx context.Context, id string) (Lead, error) {
+	lead, err := api.SQLiteFindOne[Lead](
+		ctx,
+		st.db,
+		`SELECT data FROM auto_quote_leads WHERE consent_id = ? ORDER BY rowid LIMIT 1`,
+		id,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Lead{}, errLeadNotFound
+	}
+	return lead, err
+}
+
+func (st SQLiteStorage) saveQuote(ctx context.Context, quote *Quote) error {
+	updated := *quote
+	updated.Version++
+	data, err := json.Marshal(updated)
+	if err != nil {
+		return err
+	}
+
+	var result sql.Result
+	// A new quote is inserted, unless one with the same ID was inserted
+	// concurrently.
+	if quote.Version == 0 {
+		result, err = st.db.ExecContext(
+			ctx,
+			`INSERT INTO auto_quotes (id, consent_id, status, expires_at, version, data)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (id) DO NOTHING`,
+			updated.ID,
+			updated.ConsentID,
+			updated.Status,
+			updated.ExpiresAt.Unix(),
+			updated.Version,
+			data,
+		)
+	} else {
+		result, err = st.db.ExecContext(
+			ctx,
+			`UPDATE auto_quotes
+			SET consent_id = ?, status = ?, expires_at = ?, version = ?, data = ?
+			WHERE id = ? AND version = ?`,
+			updated.ConsentID,
+			updated.Status,
+			updated.ExpiresAt.Unix(),
+			updated.Version,
+			data,
+			updated.ID,
+			quote.Version,
+		)
+	}
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return errQuoteVersionConflict
+	}
+
+	quote.Version = updated.Version
+	return nil
+}
+
+func (st SQLiteStorage) fetchQuoteByConsentID(ctx context.Context, id string) (Quote, error) {
+	quote, err := api.SQLiteFindOne[Quote](
+		ctx,
+		st.db,
+		`SELECT data FROM auto_quotes WHERE consent_id = ? ORDER BY rowid LIMIT 1`,
+		id,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Quote{}, errQuoteNotFound
+	}
+	return quote, err
+}
+
+func (st SQLiteStorage) expiredLeads(ctx context.Context) ([]Lead, error) {
+	return api.SQLiteFindAll[Lead](
+		ctx,
+		st.db,
+		`SELECT data FROM auto_quote_leads WHERE status = ? AND expires_at < ?`,
+		api.QuoteStatusRCVD,
+		time.Now().UTC().Unix(),
+	)
+}
+
+func (st SQLiteStorage) expiredQuotes(ctx context.Context) ([]Quote, error) {
+	args := []any{time.Now().UTC().Unix()}
+	for _, status := range quoteStatusesInProgress {
+		args = append(args, status)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(quoteStatusesInProgress)), ", ")
+
+	return api.SQLiteFindAll[Quote](
+		ctx,
+		st.db,
+		`SELECT data FROM auto_quotes WHERE expires_at < ? AND status IN (`+placeholders+`)`,
+		args...,
+	)
+}
+
+func (st SQLiteStorage) allLeads(ctx context.Context) ([]Lead, error) {
+	return api.SQLiteFindAll[Lead](ctx, st.db, `SELECT data FROM auto_quote_leads ORDER BY rowid`)
+}
+
+func (st SQLiteStorage) allQuotes(ctx context.Context) ([]Quote, error) {
+	return api.SQLiteFindAll[Quote](ctx, st.db, `SELECT data FROM auto_quotes ORDER BY rowid`)
+}
+
+func (st SQLiteStorage) replaceAll(ctx context.Context, leads []Lead, quotes []Quote) error {
+	if err := api.SQLiteReplaceAll(ctx, st.db, "auto_quote_leads", leads, func(tx *sql.Tx, lead Lead) error {
+		data, err := json.Marshal(lead)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO auto_quote_leads (id, consent_id, status, expires_at, data)
+			VALUES (?, ?, ?, ?, ?)`,
+			lead.ID,
+			lead.ConsentID,
+			lead.Status,
+			lead.ExpiresAt.Unix(),
+			data,
+		)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	return api.SQLiteReplaceAll(ctx, st.db, "auto_quotes", quotes, func(tx *sql.Tx, quote Quote) error {
+		data, err := json.Marshal(quote)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO auto_quotes (id, consent_id, status, expires_at, version, data)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			quote.ID,
+			quote.ConsentID,
+			quote.Status,
+			quote.ExpiresAt.Unix(),
+			quote.Version,
+			data,
+		)
+		return err
+	})
+}
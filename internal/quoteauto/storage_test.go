@@ -0,0 +1,61 @@
+package quoteauto
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luikyv/go-open-insurance/internal/api"
+	"github.com/luikyv/go-open-insurance/internal/storagetest"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// TestService_SaveConflict saves the same version of a quote twice at the
+// same time and expects exactly one of them to succeed.
+func TestService_SaveConflict(t *testing.T) {
+	cases := []struct {
+		name   string
+		stored bool
+	}{
+		{name: "double insert"},
+		{name: "version conflict", stored: true},
+	}
+
+	storages := storagetest.Storages(t,
+		func() Storage { return NewMemoryStorage() },
+		func(db *sql.DB) (Storage, error) {
+			st := NewSQLiteStorage(db)
+			return st, st.CreateTables(context.Background())
+		},
+		func(db *mongo.Database) Storage { return NewMongoStorage(db) },
+	)
+	for name, st := range storages {
+		for _, c := range cases {
+			t.Run(name+" "+c.name, func(t *testing.T) {
+				s := Service{storage: st}
+				ctx := context.Background()
+				q := Quote{
+					ID:        uuid.NewString(),
+					ConsentID: "urn:mockin:" + uuid.NewString(),
+					Status:    api.QuoteStatusRCVD,
+					ExpiresAt: time.Now().UTC().Add(time.Hour),
+				}
+				if c.stored {
+					if err := s.saveQuote(ctx, &q); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				errs := storagetest.Concurrently(2, func() error {
+					updated := q
+					updated.Status = api.QuoteStatusEVAL
+					return s.saveQuote(ctx, &updated)
+				})
+				storagetest.AssertOneSuccess(t, errs, http.StatusConflict)
+			})
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package resource
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+)
+
+// RegisterAdminHandlers adds the endpoints to manage the resources of the
+// users under prefix.
+func RegisterAdminHandlers(
+	mux *http.ServeMux,
+	prefix string,
+	service Service,
+	validate api.SchemaValidator,
+) {
+	// mu serializes the creation of resources, so the same resource can't be
+	// created twice at the same time.
+	mu := &sync.Mutex{}
+	path := prefix + "/users/{username}/resources"
+	mux.HandleFunc("GET "+path, resourcesHandler(service))
+	mux.HandleFunc("POST "+path, createResourceHandler(service, validate, mu))
+	mux.HandleFunc("PUT "+path+"/{id}", updateResourceHandler(service, validate))
+	mux.HandleFunc("DELETE "+path+"/{id}", deleteResourceHandler(service))
+}
+
+func resourcesHandler(service Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rs, err := service.All(r.Context(), r.PathValue("username"))
+		if err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+		if rs == nil {
+			rs = []api.ResourceData{}
+		}
+		api.WriteJSON(w, http.StatusOK, rs)
+	}
+}
+
+func createResourceHandler(
+	service Service,
+	validate api.SchemaValidator,
+	mu *sync.Mutex,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var rs api.ResourceData
+		if err := api.DecodeJSON(r, &rs); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		if err := validate("ResourceData", rs); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		sub := r.PathValue("username")
+		existingResources, err := service.All(r.Context(), sub)
+		if err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+		for _, existing := range existingResources {
+			if existing.ResourceId == rs.ResourceId {
+				api.ResponseErrorMiddleware(w, r, api.NewError("CONFLICT", http.StatusConflict,
+					"the resource already exists"))
+				return
+			}
+		}
+
+		if err := service.Save(r.Context(), sub, rs); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+		api.WriteJSON(w, http.StatusCreated, rs)
+	}
+}
+
+func updateResourceHandler(service Service, validate api.SchemaValidator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var rs api.ResourceData
+		if err := api.DecodeJSON(r, &rs); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+		rs.ResourceId = r.PathValue("id")
+
+		if err := validate("ResourceData", rs); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		if err := service.Save(r.Context(), r.PathValue("username"), rs); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+		api.WriteJSON(w, http.StatusOK, rs)
+	}
+}
+
+func deleteResourceHandler(service Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := service.Delete(r.Context(), r.PathValue("username"), r.PathValue("id")); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
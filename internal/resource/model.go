@@ -55,3 +55,8 @@ func newResourcesResponse(
 
 	return resp
 }
+
+// Snapshot is the resources of all users indexed by username.
+type Snapshot struct {
+	Resources map[string][]api.ResourceData `json:"resources"`
+}
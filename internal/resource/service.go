@@ -2,7 +2,10 @@ package resource
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/luikyv/go-open-insurance/internal/api"
@@ -10,19 +13,51 @@ import (
 )
 
 type Service struct {
-	storage        *Storage
+	storage        Storage
 	consentService consent.Service
 }
 
-func NewService(storage *Storage, consentService consent.Service) Service {
+func NewService(storage Storage, consentService consent.Service) Service {
 	return Service{
 		storage:        storage,
 		consentService: consentService,
 	}
 }
 
-func (s Service) Add(sub string, resource api.ResourceData) {
-	s.storage.add(sub, resource)
+// Save replaces the resource of the user with the same ID or adds it if there
+// is none.
+func (s Service) Save(ctx context.Context, sub string, resource api.ResourceData) error {
+	return s.storage.save(ctx, sub, resource)
+}
+
+func (s Service) Delete(ctx context.Context, sub string, id string) error {
+	if err := s.storage.delete(ctx, sub, id); err != nil {
+		if errors.Is(err, errResourceNotFound) {
+			return api.NewError("NOT_FOUND", http.StatusNotFound,
+				fmt.Sprintf("resource %s not found", id))
+		}
+		return err
+	}
+	return nil
+}
+
+// All returns every resource of the user regardless of its type.
+func (s Service) All(ctx context.Context, sub string) ([]api.ResourceData, error) {
+	return s.storage.resources(ctx, sub)
+}
+
+// Snapshot returns the resources of all users.
+func (s Service) Snapshot(ctx context.Context) (Snapshot, error) {
+	resources, err := s.storage.snapshot(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	return Snapshot{Resources: resources}, nil
+}
+
+// Restore replaces the resources of all users by the ones in the snapshot.
+func (s Service) Restore(ctx context.Context, snapshot Snapshot) error {
+	return s.storage.restore(ctx, snapshot.Resources)
 }
 
 func (s Service) Resource(
@@ -33,12 +68,18 @@ func (s Service) Resource(
 	api.ResourceData,
 	error,
 ) {
-	r, err := s.storage.get(meta.Subject, id)
+	rs, err := s.storage.resources(ctx, meta.Subject)
 	if err != nil {
-		return api.ResourceData{},
-			api.NewError("NAO_FOUND", http.StatusNotFound, err.Error())
+		return api.ResourceData{}, err
 	}
-	return r, nil
+
+	for _, r := range rs {
+		if r.ResourceId == id {
+			return r, nil
+		}
+	}
+	return api.ResourceData{},
+		api.NewError("NAO_FOUND", http.StatusNotFound, fmt.Sprintf("resource %s not found", id))
 }
 
 func (s Service) resources(
@@ -59,9 +100,69 @@ func (s Service) resources(
 		return api.GetResourcesResponse{}, err
 	}
 
-	consentedTypes := consentedResourceTypes(consent.Permissions)
-	rs := s.storage.resources(meta.Subject, consentedTypes, page)
-	return newResourcesResponse(meta, rs), nil
+	consentableResources, err := s.ConsentableResources(ctx, meta.Subject, consent.Permissions)
+	if err != nil {
+		return api.GetResourcesResponse{}, err
+	}
+
+	var rs []api.ResourceData
+	for _, r := range consentableResources {
+		if consent.HasResource(r.ResourceId) {
+			rs = append(rs, r)
+		}
+	}
+	return newResourcesResponse(meta, api.Paginate(rs, page)), nil
+}
+
+// ConsentableResources returns the resources of the user whose types are
+// covered by the permissions.
+func (s Service) ConsentableResources(
+	ctx context.Context,
+	sub string,
+	permissions []api.ConsentPermission,
+) (
+	[]api.ResourceData,
+	error,
+) {
+	all, err := s.storage.resources(ctx, sub)
+	if err != nil {
+		return nil, err
+	}
+
+	types := consentedResourceTypes(permissions)
+	var rs []api.ResourceData
+	for _, r := range all {
+		if slices.Contains(types, r.Type) {
+			rs = append(rs, r)
+		}
+	}
+	return rs, nil
+}
+
+// Consent returns the consent of the request, so the resources shared can be
+// checked with [consent.Consent.HasResource] without fetching it for each one.
+func (s Service) Consent(ctx context.Context, meta api.RequestMeta) (consent.Consent, error) {
+	return s.consentService.Fetch(ctx, meta, meta.ConsentID)
+}
+
+// Verify checks that the user shared the resource with the client when
+// authorizing the consent.
+func (s Service) Verify(
+	ctx context.Context,
+	meta api.RequestMeta,
+	id string,
+) error {
+	consent, err := s.consentService.Fetch(ctx, meta, meta.ConsentID)
+	if err != nil {
+		return err
+	}
+
+	if !consent.HasResource(id) {
+		return api.NewError("FORBIDDEN", http.StatusForbidden,
+			"the resource was not consented")
+	}
+
+	return nil
 }
 
 func consentedResourceTypes(permissions []api.ConsentPermission) []api.ResourceType {
@@ -1,46 +1,24 @@
 package resource
 
 import (
-	"fmt"
-	"slices"
+	"context"
+	"errors"
 
 	"github.com/luikyv/go-open-insurance/internal/api"
 )
 
-type Storage struct {
-	resourcesMap map[string][]api.ResourceData
-}
-
-func NewStorage() *Storage {
-	return &Storage{
-		resourcesMap: make(map[string][]api.ResourceData),
-	}
-}
-
-func (s *Storage) add(sub string, resource api.ResourceData) {
-	s.resourcesMap[sub] = append(s.resourcesMap[sub], resource)
-}
-
-func (s *Storage) resources(
-	sub string,
-	types []api.ResourceType,
-	page api.Pagination,
-) api.Page[api.ResourceData] {
-	var rs []api.ResourceData
-	for _, r := range s.resourcesMap[sub] {
-		if slices.Contains(types, r.Type) {
-			rs = append(rs, r)
-		}
-	}
-	return api.Paginate(rs, page)
-}
-
-func (s *Storage) get(sub string, id string) (api.ResourceData, error) {
-	for _, r := range s.resourcesMap[sub] {
-		if r.ResourceId == id {
-			return r, nil
-		}
-	}
-
-	return api.ResourceData{}, fmt.Errorf("resource %s not found", id)
+var errResourceNotFound = errors.New("resource not found")
+
+// Storage persists the resources of the users.
+type Storage interface {
+	// resources returns every resource of the user in the order they were
+	// added.
+	resources(ctx context.Context, sub string) ([]api.ResourceData, error)
+	// save replaces the resource with the same ID or adds it if there is none.
+	save(ctx context.Context, sub string, resource api.ResourceData) error
+	// delete returns errResourceNotFound if the user has no resource with the
+	// ID.
+	delete(ctx context.Context, sub string, id string) error
+	snapshot(ctx context.Context) (map[string][]api.ResourceData, error)
+	restore(ctx context.Context, resources map[string][]api.ResourceData) error
 }
@@ -0,0 +1,91 @@
+package resource
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+)
+
+// MemoryStorage keeps the resources in memory, so they are lost when the
+// server stops.
+// Saving or deleting a resource replaces the slice of the user instead of
+// modifying it, so the slices returned can be read without the lock.
+type MemoryStorage struct {
+	mu           sync.RWMutex
+	resourcesMap map[string][]api.ResourceData
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		resourcesMap: make(map[string][]api.ResourceData),
+	}
+}
+
+func (s *MemoryStorage) resources(_ context.Context, sub string) ([]api.ResourceData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clip(s.resourcesMap[sub]), nil
+}
+
+func (s *MemoryStorage) save(_ context.Context, sub string, resource api.ResourceData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resourcesMap[sub] = saveResource(s.resourcesMap[sub], resource)
+	return nil
+}
+
+func (s *MemoryStorage) delete(_ context.Context, sub string, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rs, err := deleteResource(s.resourcesMap[sub], id)
+	if err != nil {
+		return err
+	}
+
+	s.resourcesMap[sub] = rs
+	return nil
+}
+
+func (s *MemoryStorage) snapshot(_ context.Context) (map[string][]api.ResourceData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return maps.Clone(s.resourcesMap), nil
+}
+
+func (s *MemoryStorage) restore(_ context.Context, resources map[string][]api.ResourceData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resourcesMap = maps.Clone(resources)
+	if s.resourcesMap == nil {
+		s.resourcesMap = make(map[string][]api.ResourceData)
+	}
+	return nil
+}
+
+// saveResource returns a copy of rs with the resource with the same ID
+// replaced or with the resource appended if there is none.
+func saveResource(rs []api.ResourceData, resource api.ResourceData) []api.ResourceData {
+	for i, r := range rs {
+		if r.ResourceId == resource.ResourceId {
+			rs = slices.Clone(rs)
+			rs[i] = resource
+			return rs
+		}
+	}
+	return append(slices.Clip(rs), resource)
+}
+
+// deleteResource returns a copy of rs without the resource with the ID.
+func deleteResource(rs []api.ResourceData, id string) ([]api.ResourceData, error) {
+	i := slices.IndexFunc(rs, func(r api.ResourceData) bool {
+		return r.ResourceId == id
+	})
+	if i == -1 {
+		return nil, errResourceNotFound
+	}
+
+	return slices.Concat(rs[:i], rs[i+1:]), nil
+}
@@ -0,0 +1,65 @@
+package resource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+	"github.com/luikyv/go-open-insurance/internal/storagetest"
+)
+
+// TestMemoryStorage_Concurrent saves and deletes resources of a user while
+// others read them, so the race detector reports if the slices returned are
+// modified in place. The goroutines yield so readers hold a slice while it
+// is changed even with a single CPU.
+func TestMemoryStorage_Concurrent(t *testing.T) {
+	st := NewMemoryStorage()
+	ctx := context.Background()
+
+	var n atomic.Int32
+	errs := storagetest.Concurrently(50, func() error {
+		i := n.Add(1)
+		if i%2 == 0 {
+			for range 20 {
+				rs, err := st.resources(ctx, "bob")
+				if err != nil {
+					return err
+				}
+				runtime.Gosched()
+				for _, r := range rs {
+					if r.ResourceId == "" {
+						return errors.New("got a resource without id")
+					}
+				}
+			}
+			return nil
+		}
+
+		id := fmt.Sprintf("resource-%d", i)
+		if err := st.save(ctx, "bob", api.ResourceData{ResourceId: id + "-deleted"}); err != nil {
+			return err
+		}
+		if err := st.save(ctx, "bob", api.ResourceData{ResourceId: id}); err != nil {
+			return err
+		}
+		runtime.Gosched()
+		return st.delete(ctx, "bob", id+"-deleted")
+	})
+	for _, err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	rs, err := st.resources(ctx, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) != 25 {
+		t.Errorf("got %d resources, want 25", len(rs))
+	}
+}
@@ -0,0 +1,56 @@
+package resource
+
+import (
+	"context"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MongoStorage keeps the resources in a mongo collection, one document per
+// user.
+type MongoStorage struct {
+	resourcesKV api.MongoKeyValue[[]api.ResourceData]
+}
+
+func NewMongoStorage(db *mongo.Database) MongoStorage {
+	return MongoStorage{
+		resourcesKV: api.NewMongoKeyValue[[]api.ResourceData](db.Collection("resources")),
+	}
+}
+
+func (s MongoStorage) resources(ctx context.Context, sub string) ([]api.ResourceData, error) {
+	rs, _, err := s.resourcesKV.Get(ctx, sub)
+	return rs, err
+}
+
+func (s MongoStorage) save(ctx context.Context, sub string, resource api.ResourceData) error {
+	rs, err := s.resources(ctx, sub)
+	if err != nil {
+		return err
+	}
+
+	return s.resourcesKV.Set(ctx, sub, saveResource(rs, resource))
+}
+
+func (s MongoStorage) delete(ctx context.Context, sub string, id string) error {
+	rs, err := s.resources(ctx, sub)
+	if err != nil {
+		return err
+	}
+
+	rs, err = deleteResource(rs, id)
+	if err != nil {
+		return err
+	}
+
+	return s.resourcesKV.Set(ctx, sub, rs)
+}
+
+func (s MongoStorage) snapshot(ctx context.Context) (map[string][]api.ResourceData, error) {
+	return s.resourcesKV.All(ctx)
+}
+
+func (s MongoStorage) restore(ctx context.Context, resources map[string][]api.ResourceData) error {
+	return s.resourcesKV.ReplaceAll(ctx, resources)
+}
@@ -0,0 +1,60 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+)
+
+// Job is a unit of work executed periodically by the [Scheduler].
+type Job struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+type Scheduler struct {
+	interval time.Duration
+	jobs     []Job
+}
+
+func New(interval time.Duration, jobs ...Job) Scheduler {
+	return Scheduler{
+		interval: interval,
+		jobs:     jobs,
+	}
+}
+
+// Run executes all the jobs once every interval until the context is
+// cancelled.
+// A job failing doesn't prevent the others from running.
+// An interval of zero disables the scheduler, so Run returns immediately.
+func (s Scheduler) Run(ctx context.Context) {
+	if s.interval <= 0 {
+		api.Logger(ctx).Debug("the scheduler is disabled", slog.Duration("interval", s.interval))
+		return
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runJobs(ctx)
+		}
+	}
+}
+
+func (s Scheduler) runJobs(ctx context.Context) {
+	for _, job := range s.jobs {
+		api.Logger(ctx).Debug("running scheduled job", slog.String("job", job.Name))
+		if err := job.Run(ctx); err != nil {
+			api.Logger(ctx).Error("scheduled job failed",
+				slog.String("job", job.Name), slog.Any("error", err))
+		}
+	}
+}
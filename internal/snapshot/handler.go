@@ -0,0 +1,64 @@
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+)
+
+// maxRequestSize is the maximum size of the snapshots imported, as sent, i.e.
+// usually compressed.
+const maxRequestSize = 64 << 20
+
+// RegisterAdminHandlers adds the endpoints to export and import snapshots
+// under prefix.
+func RegisterAdminHandlers(mux *http.ServeMux, prefix string, service Service) {
+	mux.HandleFunc("GET "+prefix+"/snapshot", exportHandler(service))
+	mux.HandleFunc("POST "+prefix+"/snapshot", importHandler(service))
+}
+
+// exportHandler downloads the current state as a gzip compressed JSON file.
+func exportHandler(service Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snapshot, err := service.Export(r.Context())
+		if err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		fileName := fmt.Sprintf("mockin-snapshot-%s.json.gz", snapshot.CreatedAt.Format("20060102T150405Z"))
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+		w.WriteHeader(http.StatusOK)
+		if err := Write(w, snapshot); err != nil {
+			api.Logger(r.Context()).Error("could not write the snapshot", slog.Any("error", err))
+		}
+	}
+}
+
+func importHandler(service Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snapshot, err := Read(http.MaxBytesReader(w, r.Body, maxRequestSize))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) || errors.Is(err, errTooLarge) {
+				api.ResponseErrorMiddleware(w, r, api.NewError("REQUEST_TOO_LARGE", http.StatusRequestEntityTooLarge,
+					fmt.Sprintf("could not read the snapshot: %s", err)))
+				return
+			}
+			api.ResponseErrorMiddleware(w, r, api.NewError("INVALID_REQUEST", http.StatusBadRequest,
+				fmt.Sprintf("could not read the snapshot: %s", err)))
+			return
+		}
+
+		if err := service.Import(r.Context(), snapshot); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,338 @@
+// Package snapshot captures and restores the whole state of MockIn, so a
+// scenario can be reproduced exactly.
+package snapshot
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/luikyv/go-open-insurance/internal/api"
+	"github.com/luikyv/go-open-insurance/internal/capitalizationtitle"
+	"github.com/luikyv/go-open-insurance/internal/consent"
+	"github.com/luikyv/go-open-insurance/internal/customer"
+	"github.com/luikyv/go-open-insurance/internal/oidc"
+	"github.com/luikyv/go-open-insurance/internal/quoteauto"
+	"github.com/luikyv/go-open-insurance/internal/resource"
+	"github.com/luikyv/go-open-insurance/internal/user"
+)
+
+// version identifies the format of the snapshot. It must be incremented
+// whenever a change makes older snapshots impossible to import.
+const version = 2
+
+// maxSize is the maximum size of a snapshot once decompressed.
+const maxSize = 256 << 20
+
+var errTooLarge = fmt.Errorf("the snapshot exceeds %d bytes", maxSize)
+
+// Snapshot is the state of MockIn.
+// Sessions and idempotency records are not part of it, so tokens issued
+// before a snapshot is imported may stop working.
+type Snapshot struct {
+	Version             int                          `json:"version"`
+	CreatedAt           time.Time                    `json:"created_at"`
+	Users               user.Snapshot                `json:"users"`
+	Clients             []*goidc.Client              `json:"clients"`
+	Consents            []consent.Consent            `json:"consents"`
+	QuotesAuto          quoteauto.Snapshot           `json:"quotes_auto"`
+	Customers           customer.Snapshot            `json:"customers"`
+	Resources           resource.Snapshot            `json:"resources"`
+	CapitalizationTitle capitalizationtitle.Snapshot `json:"capitalization_title"`
+}
+
+type Service struct {
+	userService                user.Service
+	clientManager              oidc.ClientManager
+	consentService             consent.Service
+	quoteAutoService           quoteauto.Service
+	customerService            customer.Service
+	resourceService            resource.Service
+	capitalizationTitleService capitalizationtitle.Service
+}
+
+func NewService(
+	userService user.Service,
+	clientManager oidc.ClientManager,
+	consentService consent.Service,
+	quoteAutoService quoteauto.Service,
+	customerService customer.Service,
+	resourceService resource.Service,
+	capitalizationTitleService capitalizationtitle.Service,
+) Service {
+	return Service{
+		userService:                userService,
+		clientManager:              clientManager,
+		consentService:             consentService,
+		quoteAutoService:           quoteAutoService,
+		customerService:            customerService,
+		resourceService:            resourceService,
+		capitalizationTitleService: capitalizationTitleService,
+	}
+}
+
+// Export captures the current state.
+func (s Service) Export(ctx context.Context) (Snapshot, error) {
+	users, err := s.userService.Snapshot(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	clients, err := s.clientManager.All(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	consents, err := s.consentService.Snapshot(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	quotesAuto, err := s.quoteAutoService.Snapshot(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	customers, err := s.customerService.Snapshot(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	resources, err := s.resourceService.Snapshot(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	capitalizationTitle, err := s.capitalizationTitleService.Snapshot(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	return Snapshot{
+		Version:             version,
+		CreatedAt:           time.Now().UTC(),
+		Users:               users,
+		Clients:             clients,
+		Consents:            consents,
+		QuotesAuto:          quotesAuto,
+		Customers:           customers,
+		Resources:           resources,
+		CapitalizationTitle: capitalizationTitle,
+	}, nil
+}
+
+// Import replaces the current state by the one in the snapshot.
+// The snapshot is validated before anything is replaced. The state is kept in
+// separate storages that can't be replaced in a single transaction, so if
+// replacing one of them fails, the state before the import is restored.
+func (s Service) Import(ctx context.Context, snapshot Snapshot) error {
+	if err := validate(snapshot); err != nil {
+		return err
+	}
+
+	previous, err := s.Export(ctx)
+	if err != nil {
+		return fmt.Errorf("could not save the current state before importing: %w", err)
+	}
+
+	api.Logger(ctx).Info("importing snapshot", slog.Time("created_at", snapshot.CreatedAt))
+	if err := s.restore(ctx, snapshot); err != nil {
+		api.Logger(ctx).Error("could not import the snapshot, restoring the previous state",
+			slog.Any("error", err))
+		if rollbackErr := s.restore(ctx, previous); rollbackErr != nil {
+			return errors.Join(err, fmt.Errorf("could not restore the previous state: %w", rollbackErr))
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s Service) restore(ctx context.Context, snapshot Snapshot) error {
+	if err := s.userService.Restore(ctx, snapshot.Users); err != nil {
+		return err
+	}
+
+	if err := s.clientManager.ReplaceAll(ctx, snapshot.Clients); err != nil {
+		return err
+	}
+
+	if err := s.consentService.Restore(ctx, snapshot.Consents); err != nil {
+		return err
+	}
+
+	if err := s.quoteAutoService.Restore(ctx, snapshot.QuotesAuto); err != nil {
+		return err
+	}
+
+	if err := s.customerService.Restore(ctx, snapshot.Customers); err != nil {
+		return err
+	}
+
+	if err := s.resourceService.Restore(ctx, snapshot.Resources); err != nil {
+		return err
+	}
+
+	return s.capitalizationTitleService.Restore(ctx, snapshot.CapitalizationTitle)
+}
+
+// validate checks the snapshot is consistent, i.e. the records have unique
+// identifiers and reference users and companies that are part of it.
+// All the problems found are reported at once.
+func validate(snapshot Snapshot) error {
+	if snapshot.Version != version {
+		return api.NewError("NAO_INFORMADO", http.StatusUnprocessableEntity,
+			fmt.Sprintf("snapshot version %d is not supported, expected %d", snapshot.Version, version))
+	}
+
+	var problems []string
+	addProblem := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	companies := map[string]bool{}
+	for i, company := range snapshot.Users.Companies {
+		if company.CNPJ == "" {
+			addProblem("users.companies[%d]: the cnpj is missing", i)
+		} else if companies[company.CNPJ] {
+			addProblem("users.companies[%d]: the cnpj %s is duplicated", i, company.CNPJ)
+		}
+		companies[company.CNPJ] = true
+	}
+
+	users := map[string]bool{}
+	for i, usr := range snapshot.Users.Users {
+		if usr.UserName == "" {
+			addProblem("users.users[%d]: the username is missing", i)
+		} else if users[usr.UserName] {
+			addProblem("users.users[%d]: the username %s is duplicated", i, usr.UserName)
+		}
+		users[usr.UserName] = true
+
+		for _, cnpj := range usr.CompanyCNPJs {
+			if !companies[cnpj] {
+				addProblem("users.users[%d]: the company %s doesn't exist", i, cnpj)
+			}
+		}
+	}
+
+	clients := map[string]bool{}
+	for i, client := range snapshot.Clients {
+		if client == nil || client.ID == "" {
+			addProblem("clients[%d]: the id is missing", i)
+			continue
+		}
+		if clients[client.ID] {
+			addProblem("clients[%d]: the id %s is duplicated", i, client.ID)
+		}
+		clients[client.ID] = true
+	}
+
+	consents := map[string]bool{}
+	for i, c := range snapshot.Consents {
+		if c.ID == "" {
+			addProblem("consents[%d]: the id is missing", i)
+		} else if consents[c.ID] {
+			addProblem("consents[%d]: the id %s is duplicated", i, c.ID)
+		}
+		consents[c.ID] = true
+
+		if c.UserID != "" && !users[c.UserID] {
+			addProblem("consents[%d]: the user %s doesn't exist", i, c.UserID)
+		}
+	}
+
+	checkUsers := func(field string, usernames []string) {
+		for _, username := range usernames {
+			if !users[username] {
+				addProblem("%s: the user %s doesn't exist", field, username)
+			}
+		}
+	}
+	checkUsers("customers.personal_identifications", keys(snapshot.Customers.PersonalIdentifications))
+	checkUsers("customers.personal_qualifications", keys(snapshot.Customers.PersonalQualifications))
+	checkUsers("customers.personal_complimentary_info", keys(snapshot.Customers.PersonalComplimentaryInfo))
+	checkUsers("resources.resources", keys(snapshot.Resources.Resources))
+	checkUsers("capitalization_title.plans", keys(snapshot.CapitalizationTitle.Plans))
+
+	if len(problems) != 0 {
+		return api.NewError(api.ErrorCodeInvalidParameter, http.StatusUnprocessableEntity,
+			"invalid snapshot: "+strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// keys returns the keys of m sorted, so the problems are always reported in
+// the same order.
+func keys[V any](m map[string]V) []string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	slices.Sort(ks)
+	return ks
+}
+
+// Write encodes the snapshot as gzip compressed JSON.
+func Write(w io.Writer, snapshot Snapshot) error {
+	gw := gzip.NewWriter(w)
+	if err := json.NewEncoder(gw).Encode(snapshot); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// Read decodes a snapshot written by [Write]. Uncompressed JSON is accepted
+// as well, so snapshots can be edited by hand.
+// Snapshots larger than maxSize once decompressed are rejected.
+func Read(r io.Reader) (Snapshot, error) {
+	br := bufio.NewReader(r)
+	header, _ := br.Peek(2)
+
+	var reader io.Reader = br
+	if bytes.Equal(header, []byte{0x1f, 0x8b}) {
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		defer gr.Close()
+		reader = gr
+	}
+
+	var snapshot Snapshot
+	if err := json.NewDecoder(&limitedReader{r: reader, n: maxSize}).Decode(&snapshot); err != nil {
+		return Snapshot{}, err
+	}
+	return snapshot, nil
+}
+
+// limitedReader is like [io.LimitedReader], but fails with errTooLarge
+// instead of returning EOF when the limit is reached, so a truncated snapshot
+// is not reported as malformed JSON.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if lr.n <= 0 {
+		return 0, errTooLarge
+	}
+	if int64(len(p)) > lr.n {
+		p = p[:lr.n]
+	}
+	n, err := lr.r.Read(p)
+	lr.n -= int64(n)
+	return n, err
+}
@@ -0,0 +1,123 @@
+// Package storagetest helps testing the storages against every backend.
+package storagetest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luikyv/go-open-insurance/internal/api"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Storages returns a storage for each backend. The sqlite one is created in a
+// temporary database and the mongo one only if MOCKIN_TEST_DB_CONNECTION points
+// to a reachable database.
+func Storages[S any](
+	t *testing.T,
+	memory func() S,
+	sqlite func(db *sql.DB) (S, error),
+	mongo func(db *mongo.Database) S,
+) map[string]S {
+	t.Helper()
+	storages := map[string]S{
+		"memory": memory(),
+	}
+
+	db, err := api.OpenSQLite(filepath.Join(t.TempDir(), "mockin.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	sqliteStorage, err := sqlite(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storages["sqlite"] = sqliteStorage
+
+	if mongoDB := mongoDatabase(t); mongoDB != nil {
+		storages["mongo"] = mongo(mongoDB)
+	}
+	return storages
+}
+
+// mongoDatabase returns a new database, which is dropped when the test ends.
+func mongoDatabase(t *testing.T) *mongo.Database {
+	t.Helper()
+	uri := os.Getenv("MOCKIN_TEST_DB_CONNECTION")
+	if uri == "" {
+		t.Log("MOCKIN_TEST_DB_CONNECTION is not set, skipping mongo")
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).SetBSONOptions(&options.BSONOptions{
+		UseJSONStructTags: true,
+		NilMapAsEmpty:     true,
+		NilSliceAsEmpty:   true,
+	}))
+	if err != nil {
+		t.Logf("could not connect to mongo, skipping it: %v", err)
+		return nil
+	}
+	if err := conn.Ping(ctx, nil); err != nil {
+		t.Logf("could not reach mongo, skipping it: %v", err)
+		_ = conn.Disconnect(context.Background())
+		return nil
+	}
+
+	db := conn.Database("mockin_test_" + uuid.NewString())
+	t.Cleanup(func() {
+		_ = db.Drop(context.Background())
+		_ = conn.Disconnect(context.Background())
+	})
+	return db
+}
+
+// Concurrently runs fn n times at the same time and returns the errors.
+func Concurrently(n int, fn func() error) []error {
+	errs := make([]error, n)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			errs[i] = fn()
+		}()
+	}
+	close(start)
+	wg.Wait()
+	return errs
+}
+
+// AssertOneSuccess checks that exactly one of errs is nil and that the others
+// are [api.Error] with the status code informed.
+func AssertOneSuccess(t *testing.T, errs []error, statusCode int) {
+	t.Helper()
+	succeeded, failed := 0, 0
+	for _, err := range errs {
+		var apiErr api.Error
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.As(err, &apiErr) && apiErr.StatusCode == statusCode:
+			failed++
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if succeeded != 1 || failed != len(errs)-1 {
+		t.Errorf("got %d successes and %d failures with %d, want 1 and %d",
+			succeeded, failed, statusCode, len(errs)-1)
+	}
+}
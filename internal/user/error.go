@@ -1,11 +1,21 @@
 package user
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/luikyv/go-open-insurance/internal/api"
 )
 
 var (
-	errorUserNotFound = api.NewError("USER_NOT_FOUND", http.StatusNotFound, "could not find user")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrUserLocked         = errors.New("the user is locked")
+	ErrPasswordExpired    = errors.New("the password is expired")
+)
+
+var (
+	errorUserNotFound         = api.NewError("USER_NOT_FOUND", http.StatusNotFound, "could not find user")
+	errorUserAlreadyExists    = api.NewError("USER_ALREADY_EXISTS", http.StatusConflict, "the username or cpf is already in use")
+	errorCompanyNotFound      = api.NewError("COMPANY_NOT_FOUND", http.StatusNotFound, "could not find company")
+	errorCompanyAlreadyExists = api.NewError("COMPANY_ALREADY_EXISTS", http.StatusConflict, "the cnpj is already in use")
 )
@@ -0,0 +1,258 @@
+package user
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+)
+
+// RegisterAdminHandlers adds the endpoints to manage users and companies
+// under prefix.
+func RegisterAdminHandlers(mux *http.ServeMux, prefix string, service Service) {
+	mux.HandleFunc("POST "+prefix+"/users", createUserHandler(service))
+	mux.HandleFunc("GET "+prefix+"/users", usersHandler(service))
+	mux.HandleFunc("GET "+prefix+"/users/{username}", userHandler(service))
+	mux.HandleFunc("PUT "+prefix+"/users/{username}", updateUserHandler(service))
+	mux.HandleFunc("DELETE "+prefix+"/users/{username}", deleteUserHandler(service))
+	mux.HandleFunc("GET "+prefix+"/users/{username}/otp", otpHandler(service))
+
+	mux.HandleFunc("POST "+prefix+"/companies", createCompanyHandler(service))
+	mux.HandleFunc("GET "+prefix+"/companies", companiesHandler(service))
+	mux.HandleFunc("GET "+prefix+"/companies/{cnpj}", companyHandler(service))
+	mux.HandleFunc("PUT "+prefix+"/companies/{cnpj}", updateCompanyHandler(service))
+	mux.HandleFunc("DELETE "+prefix+"/companies/{cnpj}", deleteCompanyHandler(service))
+}
+
+type userRequest struct {
+	UserName          string     `json:"username"`
+	Email             string     `json:"email"`
+	CPF               string     `json:"cpf"`
+	Name              string     `json:"name"`
+	CompanyCNPJs      []string   `json:"company_cnpjs"`
+	Password          string     `json:"password"`
+	PasswordExpiresAt *time.Time `json:"password_expires_at"`
+	LockedUntil       *time.Time `json:"locked_until"`
+}
+
+func (req userRequest) user() User {
+	user := User{
+		UserName:     req.UserName,
+		Email:        req.Email,
+		CPF:          req.CPF,
+		Name:         req.Name,
+		CompanyCNPJs: req.CompanyCNPJs,
+	}
+	if req.PasswordExpiresAt != nil {
+		user.PasswordExpiresAt = *req.PasswordExpiresAt
+	}
+	if req.LockedUntil != nil {
+		user.LockedUntil = *req.LockedUntil
+	}
+	return user
+}
+
+// userResponse leaves out the user's secrets.
+type userResponse struct {
+	UserName          string     `json:"username"`
+	Email             string     `json:"email"`
+	CPF               string     `json:"cpf"`
+	Name              string     `json:"name"`
+	CompanyCNPJs      []string   `json:"company_cnpjs"`
+	PasswordExpiresAt *time.Time `json:"password_expires_at,omitempty"`
+	LockedUntil       *time.Time `json:"locked_until,omitempty"`
+}
+
+func newUserResponse(user User) userResponse {
+	resp := userResponse{
+		UserName:     user.UserName,
+		Email:        user.Email,
+		CPF:          user.CPF,
+		Name:         user.Name,
+		CompanyCNPJs: user.CompanyCNPJs,
+	}
+	if !user.PasswordExpiresAt.IsZero() {
+		resp.PasswordExpiresAt = &user.PasswordExpiresAt
+	}
+	if user.IsLocked() {
+		resp.LockedUntil = &user.LockedUntil
+	}
+	return resp
+}
+
+type companyRequest struct {
+	CNPJ string `json:"cnpj"`
+	Name string `json:"name"`
+}
+
+type companyResponse = companyRequest
+
+type otpResponse struct {
+	Code string `json:"code"`
+}
+
+func createUserHandler(service Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req userRequest
+		if err := api.DecodeJSON(r, &req); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		user := req.user()
+		if err := service.Create(r.Context(), user, req.Password); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		api.WriteJSON(w, http.StatusCreated, newUserResponse(user))
+	}
+}
+
+func usersHandler(service Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		users, err := service.Users(r.Context())
+		if err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		resp := []userResponse{}
+		for _, user := range users {
+			resp = append(resp, newUserResponse(user))
+		}
+		api.WriteJSON(w, http.StatusOK, resp)
+	}
+}
+
+func userHandler(service Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := service.User(r.Context(), r.PathValue("username"))
+		if err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		api.WriteJSON(w, http.StatusOK, newUserResponse(user))
+	}
+}
+
+func updateUserHandler(service Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req userRequest
+		if err := api.DecodeJSON(r, &req); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+		req.UserName = r.PathValue("username")
+
+		user := req.user()
+		if err := service.Update(r.Context(), user, req.Password); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		api.WriteJSON(w, http.StatusOK, newUserResponse(user))
+	}
+}
+
+func deleteUserHandler(service Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := service.Delete(r.Context(), r.PathValue("username")); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// otpHandler returns the one-time password currently valid for the user
+// informed in the path, so automated tests can go through LOA3 flows.
+func otpHandler(service Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		code, err := service.OTP(r.Context(), r.PathValue("username"))
+		if err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		api.WriteJSON(w, http.StatusOK, otpResponse{Code: code})
+	}
+}
+
+func createCompanyHandler(service Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req companyRequest
+		if err := api.DecodeJSON(r, &req); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		company := Company(req)
+		if err := service.CreateCompany(r.Context(), company); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		api.WriteJSON(w, http.StatusCreated, companyResponse(company))
+	}
+}
+
+func companiesHandler(service Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		companies, err := service.Companies(r.Context())
+		if err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		resp := []companyResponse{}
+		for _, company := range companies {
+			resp = append(resp, companyResponse(company))
+		}
+		api.WriteJSON(w, http.StatusOK, resp)
+	}
+}
+
+func companyHandler(service Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		company, err := service.Company(r.Context(), r.PathValue("cnpj"))
+		if err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		api.WriteJSON(w, http.StatusOK, companyResponse(company))
+	}
+}
+
+func updateCompanyHandler(service Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req companyRequest
+		if err := api.DecodeJSON(r, &req); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+		req.CNPJ = r.PathValue("cnpj")
+
+		company := Company(req)
+		if err := service.UpdateCompany(r.Context(), company); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		api.WriteJSON(w, http.StatusOK, companyResponse(company))
+	}
+}
+
+func deleteCompanyHandler(service Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := service.DeleteCompany(r.Context(), r.PathValue("cnpj")); err != nil {
+			api.ResponseErrorMiddleware(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
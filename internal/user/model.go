@@ -1,14 +1,53 @@
 package user
 
+import (
+	"slices"
+	"time"
+)
+
 type User struct {
-	UserName     string
-	Email        string
-	CPF          string
-	Name         string
-	CompanyCNPJs []string
+	UserName     string   `bson:"_id" json:"username"`
+	Email        string   `bson:"email" json:"email"`
+	CPF          string   `bson:"cpf" json:"cpf"`
+	Name         string   `bson:"name" json:"name"`
+	CompanyCNPJs []string `bson:"company_cnpjs" json:"company_cnpjs"`
+	// OTPSecret is the base32 encoded seed of the user's one-time passwords.
+	OTPSecret string `bson:"otp_secret" json:"otp_secret"`
+	// OTPLastCounter is the period of the last one-time password used, so
+	// codes can't be used again.
+	OTPLastCounter int64 `bson:"otp_last_counter" json:"otp_last_counter"`
+	// PasswordHash is the bcrypt hash of the user's password.
+	PasswordHash []byte `bson:"password_hash" json:"password_hash"`
+	// PasswordExpiresAt is when the password must be changed. The zero value
+	// means the password never expires.
+	PasswordExpiresAt time.Time `bson:"password_expires_at" json:"password_expires_at"`
+	FailedLogins      int       `bson:"failed_logins" json:"failed_logins"`
+	// LockedUntil is set when the user fails to log in too many times in a
+	// row.
+	LockedUntil time.Time `bson:"locked_until" json:"locked_until"`
+}
+
+func (u User) IsLocked() bool {
+	return time.Now().UTC().Before(u.LockedUntil)
+}
+
+func (u User) IsPasswordExpired() bool {
+	return !u.PasswordExpiresAt.IsZero() && time.Now().UTC().After(u.PasswordExpiresAt)
+}
+
+// IsRepresentative returns whether the user is authorized to act on behalf of
+// the company identified by cnpj.
+func (u User) IsRepresentative(cnpj string) bool {
+	return slices.Contains(u.CompanyCNPJs, cnpj)
 }
 
 type Company struct {
-	Name string
-	CNPJ string
+	CNPJ string `bson:"_id" json:"cnpj"`
+	Name string `bson:"name" json:"name"`
+}
+
+// Snapshot is the state of all the users and companies.
+type Snapshot struct {
+	Users     []User    `json:"users"`
+	Companies []Company `json:"companies"`
 }
@@ -2,39 +2,238 @@ package user
 
 import (
 	"context"
-	"net/http"
+	"log/slog"
+	"time"
 
 	"github.com/luikyv/go-open-insurance/internal/api"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	maxFailedLogins     = 3
+	lockoutDurationSecs = 900
 )
 
 type Service struct {
-	storage *Storage
+	storage Storage
 }
 
-func NewService(storage *Storage) Service {
+func NewService(storage Storage) Service {
 	return Service{
 		storage: storage,
 	}
 }
 
-func (s Service) Create(ctx context.Context, user User) error {
+// Create registers the user with the password informed.
+func (s Service) Create(ctx context.Context, user User, password string) error {
+	if err := s.validate(ctx, user); err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = hash
+
+	if user.OTPSecret == "" {
+		secret, err := newOTPSecret()
+		if err != nil {
+			return err
+		}
+		user.OTPSecret = secret
+	}
 	return s.storage.create(ctx, user)
 }
 
-func (s Service) User(username string) (User, error) {
-	user, err := s.storage.user(username)
+// Update replaces the information of an existing user.
+// The password is only changed if informed and the OTP seed is kept.
+func (s Service) Update(ctx context.Context, user User, password string) error {
+	if err := s.validate(ctx, user); err != nil {
+		return err
+	}
+
+	current, err := s.User(ctx, user.UserName)
 	if err != nil {
-		return User{}, api.NewError("USER_NOT_FOUND", http.StatusNotFound, "user not found")
+		return err
 	}
 
-	return user, nil
+	user.OTPSecret = current.OTPSecret
+	user.OTPLastCounter = current.OTPLastCounter
+	user.PasswordHash = current.PasswordHash
+	if password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		user.PasswordHash = hash
+	}
+
+	return s.storage.save(ctx, user)
+}
+
+func (s Service) Delete(ctx context.Context, username string) error {
+	return s.storage.delete(ctx, username)
+}
+
+func (s Service) User(ctx context.Context, username string) (User, error) {
+	return s.storage.user(ctx, username)
+}
+
+func (s Service) Users(ctx context.Context) ([]User, error) {
+	return s.storage.allUsers(ctx)
 }
 
-func (s Service) UserByCPF(cpf string) (User, error) {
-	user, err := s.storage.userByCPF(cpf)
+// Authenticate verifies the password of the user.
+// After maxFailedLogins consecutive failures, the user is locked for
+// lockoutDurationSecs even if the right password is informed.
+// The failures are recorded atomically, so concurrent attempts can't bypass
+// the lockout, and the rest of the user is never overwritten.
+func (s Service) Authenticate(
+	ctx context.Context,
+	username string,
+	password string,
+) (
+	User,
+	error,
+) {
+	user, err := s.User(ctx, username)
 	if err != nil {
-		return User{}, api.NewError("USER_NOT_FOUND", http.StatusNotFound, "cpf not found")
+		return User{}, err
+	}
+
+	if user.IsLocked() {
+		return User{}, ErrUserLocked
+	}
+
+	if err := bcrypt.CompareHashAndPassword(user.PasswordHash, []byte(password)); err != nil {
+		lockedUntil := time.Now().UTC().Add(lockoutDurationSecs * time.Second).Truncate(time.Millisecond)
+		user, err := s.storage.recordFailedLogin(ctx, username, maxFailedLogins, lockedUntil)
+		if err != nil {
+			return User{}, err
+		}
+		if user.LockedUntil.Equal(lockedUntil) {
+			api.Logger(ctx).Info("locking user after too many failed logins",
+				slog.String("username", username))
+		}
+		return User{}, ErrInvalidCredentials
+	}
+
+	// The user is read again, since it may have been locked by concurrent
+	// attempts while the password was verified.
+	user, err = s.storage.resetFailedLogins(ctx, username)
+	if err != nil {
+		return User{}, err
+	}
+	if user.IsLocked() {
+		return User{}, ErrUserLocked
+	}
+
+	if user.IsPasswordExpired() {
+		return User{}, ErrPasswordExpired
 	}
 
 	return user, nil
 }
+
+func (s Service) UserByCPF(ctx context.Context, cpf string) (User, error) {
+	return s.storage.userByCPF(ctx, cpf)
+}
+
+func (s Service) CreateCompany(ctx context.Context, company Company) error {
+	if err := api.ValidateCNPJ("cnpj", company.CNPJ); err != nil {
+		return err
+	}
+
+	return s.storage.createCompany(ctx, company)
+}
+
+// UpdateCompany replaces the information of an existing company.
+func (s Service) UpdateCompany(ctx context.Context, company Company) error {
+	if _, err := s.Company(ctx, company.CNPJ); err != nil {
+		return err
+	}
+
+	return s.storage.saveCompany(ctx, company)
+}
+
+func (s Service) DeleteCompany(ctx context.Context, cnpj string) error {
+	return s.storage.deleteCompany(ctx, cnpj)
+}
+
+func (s Service) Company(ctx context.Context, cnpj string) (Company, error) {
+	return s.storage.company(ctx, cnpj)
+}
+
+func (s Service) Companies(ctx context.Context) ([]Company, error) {
+	return s.storage.allCompanies(ctx)
+}
+
+// Snapshot returns all the users and companies.
+func (s Service) Snapshot(ctx context.Context) (Snapshot, error) {
+	users, err := s.Users(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	companies, err := s.Companies(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	return Snapshot{Users: users, Companies: companies}, nil
+}
+
+// Restore replaces all the users and companies by the ones in the snapshot.
+func (s Service) Restore(ctx context.Context, snapshot Snapshot) error {
+	return s.storage.replaceAll(ctx, snapshot.Users, snapshot.Companies)
+}
+
+// OTP returns the one-time password currently valid for the user.
+func (s Service) OTP(ctx context.Context, username string) (string, error) {
+	user, err := s.User(ctx, username)
+	if err != nil {
+		return "", err
+	}
+
+	return otp(user.OTPSecret, time.Now())
+}
+
+// IsOTPValid verifies the code informed by the user.
+// A code is accepted only once, and neither are the codes of earlier periods
+// once one is used, so an intercepted code can't be replayed.
+func (s Service) IsOTPValid(ctx context.Context, username, code string) bool {
+	user, err := s.User(ctx, username)
+	if err != nil {
+		return false
+	}
+
+	counter, ok := verifyOTP(user.OTPSecret, code, time.Now())
+	if !ok {
+		return false
+	}
+
+	used, err := s.storage.useOTPCounter(ctx, username, counter)
+	if err != nil {
+		api.Logger(ctx).Error("could not register the one-time password use",
+			slog.String("username", username), slog.Any("error", err))
+		return false
+	}
+	return used
+}
+
+// validate verifies the documents of the user and that the companies it
+// represents exist.
+func (s Service) validate(ctx context.Context, user User) error {
+	if err := api.ValidateCPF("cpf", user.CPF); err != nil {
+		return err
+	}
+
+	for _, cnpj := range user.CompanyCNPJs {
+		if _, err := s.Company(ctx, cnpj); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// TestService_AuthenticateConcurrent fails to log in maxFailedLogins times at
+// the same time while one-time passwords are used, and expects the user to be
+// locked without losing the one-time password counter.
+func TestService_AuthenticateConcurrent(t *testing.T) {
+	s := NewService(NewMemoryStorage())
+	ctx := context.Background()
+	if err := s.Create(ctx, User{UserName: "bob", CPF: "76109277673"}, "password"); err != nil {
+		t.Fatal(err)
+	}
+
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range maxFailedLogins {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			<-start
+			if _, err := s.Authenticate(ctx, "bob", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("got %v, want %v", err, ErrInvalidCredentials)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			<-start
+			if _, err := s.storage.useOTPCounter(ctx, "bob", int64(i+1)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	u, err := s.User(ctx, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !u.IsLocked() {
+		t.Errorf("the user is not locked after %d concurrent failed logins", maxFailedLogins)
+	}
+	if u.OTPLastCounter != maxFailedLogins {
+		t.Errorf("got otp counter %d, want %d", u.OTPLastCounter, maxFailedLogins)
+	}
+
+	if _, err := s.Authenticate(ctx, "bob", "password"); !errors.Is(err, ErrUserLocked) {
+		t.Errorf("got %v, want %v", err, ErrUserLocked)
+	}
+}
+
+// TestService_AuthenticateResetsFailures expects a successful login to clear
+// the failures before the user is locked.
+func TestService_AuthenticateResetsFailures(t *testing.T) {
+	s := NewService(NewMemoryStorage())
+	ctx := context.Background()
+	if err := s.Create(ctx, User{UserName: "bob", CPF: "76109277673"}, "password"); err != nil {
+		t.Fatal(err)
+	}
+
+	for range maxFailedLogins - 1 {
+		if _, err := s.Authenticate(ctx, "bob", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("got %v, want %v", err, ErrInvalidCredentials)
+		}
+	}
+	if _, err := s.Authenticate(ctx, "bob", "password"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Authenticate(ctx, "bob", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("got %v, want %v", err, ErrInvalidCredentials)
+	}
+
+	u, err := s.User(ctx, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.IsLocked() || u.FailedLogins != 1 {
+		t.Errorf("got locked %v and %d failed logins, want unlocked and 1", u.IsLocked(), u.FailedLogins)
+	}
+}
@@ -2,59 +2,34 @@ package user
 
 import (
 	"context"
-	"errors"
+	"time"
 )
 
-type Storage struct {
-	users []User
-}
-
-func NewStorage() *Storage {
-	return &Storage{
-		users: []User{},
-	}
-}
-
-func (st *Storage) create(_ context.Context, user User) error {
-	_, ok := findFirst(st.users, func(u User) bool {
-		return u.UserName == user.UserName
-	})
-
-	if ok {
-		return errors.New("user already exists")
-	}
-
-	st.users = append(st.users, user)
-	return nil
-}
-
-func (st *Storage) user(username string) (User, error) {
-	user, ok := findFirst(st.users, func(user User) bool {
-		return user.UserName == username
-	})
-	if !ok {
-		return User{}, errorUserNotFound
-	}
-	return user, nil
-}
-
-func (st *Storage) userByCPF(cpf string) (User, error) {
-	user, ok := findFirst(st.users, func(user User) bool {
-		return user.CPF == cpf
-	})
-	if !ok {
-		return User{}, errorUserNotFound
-	}
-
-	return user, nil
-}
-
-func findFirst[T any](elements []T, condition func(t T) bool) (T, bool) {
-	for _, e := range elements {
-		if condition(e) {
-			return e, true
-		}
-	}
-
-	return *new(T), false
+// Storage persists the users and companies.
+// The implementations return errorUserNotFound, errorCompanyNotFound,
+// errorUserAlreadyExists and errorCompanyAlreadyExists to report missing and
+// duplicate records. A CPF belongs to only one user.
+type Storage interface {
+	create(ctx context.Context, user User) error
+	save(ctx context.Context, user User) error
+	user(ctx context.Context, username string) (User, error)
+	userByCPF(ctx context.Context, cpf string) (User, error)
+	allUsers(ctx context.Context) ([]User, error)
+	delete(ctx context.Context, username string) error
+	// useOTPCounter atomically sets the last one-time password counter of the
+	// user if counter is greater than it and reports whether it was.
+	useOTPCounter(ctx context.Context, username string, counter int64) (bool, error)
+	// recordFailedLogin atomically increments the failed logins of the user.
+	// When they reach maxFailures, they are reset and the user is locked until
+	// lockedUntil. The user is returned updated.
+	recordFailedLogin(ctx context.Context, username string, maxFailures int, lockedUntil time.Time) (User, error)
+	// resetFailedLogins atomically clears the failed logins of the user and
+	// returns it updated.
+	resetFailedLogins(ctx context.Context, username string) (User, error)
+	createCompany(ctx context.Context, company Company) error
+	saveCompany(ctx context.Context, company Company) error
+	company(ctx context.Context, cnpj string) (Company, error)
+	allCompanies(ctx context.Context) ([]Company, error)
+	deleteCompany(ctx context.Context, cnpj string) error
+	replaceAll(ctx context.Context, users []User, companies []Company) error
 }
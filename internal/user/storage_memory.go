@@ -0,0 +1,222 @@
+package user
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemoryStorage keeps the users and companies in memory, so they are lost
+// when the server stops.
+// The records are kept in the order they were created.
+type MemoryStorage struct {
+	mu        sync.RWMutex
+	users     []User
+	companies []Company
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{}
+}
+
+func (st *MemoryStorage) create(_ context.Context, user User) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if slices.ContainsFunc(st.users, func(u User) bool {
+		return u.UserName == user.UserName || u.CPF == user.CPF
+	}) {
+		return errorUserAlreadyExists
+	}
+
+	st.users = append(st.users, user)
+	return nil
+}
+
+func (st *MemoryStorage) save(_ context.Context, user User) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if slices.ContainsFunc(st.users, func(u User) bool {
+		return u.UserName != user.UserName && u.CPF == user.CPF
+	}) {
+		return errorUserAlreadyExists
+	}
+
+	i := st.userIndex(user.UserName)
+	if i == -1 {
+		st.users = append(st.users, user)
+		return nil
+	}
+
+	st.users[i] = user
+	return nil
+}
+
+func (st *MemoryStorage) user(_ context.Context, username string) (User, error) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	i := st.userIndex(username)
+	if i == -1 {
+		return User{}, errorUserNotFound
+	}
+
+	return st.users[i], nil
+}
+
+func (st *MemoryStorage) userByCPF(_ context.Context, cpf string) (User, error) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	i := slices.IndexFunc(st.users, func(u User) bool {
+		return u.CPF == cpf
+	})
+	if i == -1 {
+		return User{}, errorUserNotFound
+	}
+
+	return st.users[i], nil
+}
+
+func (st *MemoryStorage) allUsers(_ context.Context) ([]User, error) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return append([]User{}, st.users...), nil
+}
+
+func (st *MemoryStorage) delete(_ context.Context, username string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	i := st.userIndex(username)
+	if i == -1 {
+		return errorUserNotFound
+	}
+
+	st.users = slices.Delete(st.users, i, i+1)
+	return nil
+}
+
+func (st *MemoryStorage) useOTPCounter(_ context.Context, username string, counter int64) (bool, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	i := st.userIndex(username)
+	if i == -1 {
+		return false, errorUserNotFound
+	}
+
+	if counter <= st.users[i].OTPLastCounter {
+		return false, nil
+	}
+
+	st.users[i].OTPLastCounter = counter
+	return true, nil
+}
+
+func (st *MemoryStorage) recordFailedLogin(
+	_ context.Context,
+	username string,
+	maxFailures int,
+	lockedUntil time.Time,
+) (
+	User,
+	error,
+) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	i := st.userIndex(username)
+	if i == -1 {
+		return User{}, errorUserNotFound
+	}
+
+	st.users[i].FailedLogins++
+	if st.users[i].FailedLogins >= maxFailures {
+		st.users[i].FailedLogins = 0
+		st.users[i].LockedUntil = lockedUntil
+	}
+	return st.users[i], nil
+}
+
+func (st *MemoryStorage) resetFailedLogins(_ context.Context, username string) (User, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	i := st.userIndex(username)
+	if i == -1 {
+		return User{}, errorUserNotFound
+	}
+
+	st.users[i].FailedLogins = 0
+	return st.users[i], nil
+}
+
+// userIndex must be called with the lock held.
+func (st *MemoryStorage) userIndex(username string) int {
+	return slices.IndexFunc(st.users, func(u User) bool {
+		return u.UserName == username
+	})
+}
+
+func (st *MemoryStorage) createCompany(_ context.Context, company Company) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.companyIndex(company.CNPJ) != -1 {
+		return errorCompanyAlreadyExists
+	}
+
+	st.companies = append(st.companies, company)
+	return nil
+}
+
+func (st *MemoryStorage) saveCompany(_ context.Context, company Company) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	i := st.companyIndex(company.CNPJ)
+	if i == -1 {
+		st.companies = append(st.companies, company)
+		return nil
+	}
+
+	st.companies[i] = company
+	return nil
+}
+
+func (st *MemoryStorage) company(_ context.Context, cnpj string) (Company, error) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	i := st.companyIndex(cnpj)
+	if i == -1 {
+		return Company{}, errorCompanyNotFound
+	}
+
+	return st.companies[i], nil
+}
+
+func (st *MemoryStorage) allCompanies(_ context.Context) ([]Company, error) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return append([]Company{}, st.companies...), nil
+}
+
+func (st *MemoryStorage) deleteCompany(_ context.Context, cnpj string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	i := st.companyIndex(cnpj)
+	if i == -1 {
+		return errorCompanyNotFound
+	}
+
+	st.companies = slices.Delete(st.companies, i, i+1)
+	return nil
+}
+
+// companyIndex must be called with the lock held.
+func (st *MemoryStorage) companyIndex(cnpj string) int {
+	return slices.IndexFunc(st.companies, func(c Company) bool {
+		return c.CNPJ == cnpj
+	})
+}
+
+func (st *MemoryStorage) replaceAll(_ context.Context, users []User, companies []Company) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.users = slices.Clone(users)
+	st.companies = slices.Clone(companies)
+	return nil
+}
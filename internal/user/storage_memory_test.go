@@ -0,0 +1,36 @@
+package user
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/luikyv/go-open-insurance/internal/storagetest"
+)
+
+// TestMemoryStorage_UseOTPCounter uses the same one-time password counter at
+// the same time and expects it to be accepted only once.
+func TestMemoryStorage_UseOTPCounter(t *testing.T) {
+	st := NewMemoryStorage()
+	ctx := context.Background()
+	if err := st.create(ctx, User{UserName: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var accepted atomic.Int32
+	errs := storagetest.Concurrently(50, func() error {
+		ok, err := st.useOTPCounter(ctx, "bob", 1)
+		if ok {
+			accepted.Add(1)
+		}
+		return err
+	})
+	for _, err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if got := accepted.Load(); got != 1 {
+		t.Errorf("the one-time password was accepted %d times, want 1", got)
+	}
+}
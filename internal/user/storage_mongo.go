@@ -0,0 +1,268 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"github.com/luikyv/go-open-insurance/internal/api"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStorage keeps the users and companies in mongo collections.
+type MongoStorage struct {
+	users     *mongo.Collection
+	companies *mongo.Collection
+}
+
+func NewMongoStorage(db *mongo.Database) MongoStorage {
+	return MongoStorage{
+		users:     db.Collection("users"),
+		companies: db.Collection("companies"),
+	}
+}
+
+// CreateIndexes makes sure a CPF belongs to only one user.
+func (st MongoStorage) CreateIndexes(ctx context.Context) error {
+	_, err := st.users.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "cpf", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func (st MongoStorage) create(ctx context.Context, user User) error {
+	if _, err := st.users.InsertOne(ctx, user); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errorUserAlreadyExists
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (st MongoStorage) save(ctx context.Context, user User) error {
+	shouldUpsert := true
+	filter := bson.D{{Key: "_id", Value: user.UserName}}
+	if _, err := st.users.ReplaceOne(
+		ctx,
+		filter,
+		user,
+		&options.ReplaceOptions{Upsert: &shouldUpsert},
+	); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errorUserAlreadyExists
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (st MongoStorage) useOTPCounter(ctx context.Context, username string, counter int64) (bool, error) {
+	// Users created before the counter existed don't have the field.
+	filter := bson.D{
+		{Key: "_id", Value: username},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "otp_last_counter", Value: bson.D{{Key: "$lt", Value: counter}}}},
+			bson.D{{Key: "otp_last_counter", Value: bson.D{{Key: "$exists", Value: false}}}},
+		}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "otp_last_counter", Value: counter}}}}
+	result, err := st.users.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
+func (st MongoStorage) recordFailedLogin(
+	ctx context.Context,
+	username string,
+	maxFailures int,
+	lockedUntil time.Time,
+) (
+	User,
+	error,
+) {
+	// The second stage sees the failed logins incremented by the first one.
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.D{
+			{Key: "failed_logins", Value: bson.D{{Key: "$add", Value: bson.A{
+				bson.D{{Key: "$ifNull", Value: bson.A{"$failed_logins", 0}}}, 1,
+			}}}},
+		}}},
+		{{Key: "$set", Value: bson.D{
+			{Key: "locked_until", Value: bson.D{{Key: "$cond", Value: bson.A{
+				bson.D{{Key: "$gte", Value: bson.A{"$failed_logins", maxFailures}}}, lockedUntil, "$locked_until",
+			}}}},
+			{Key: "failed_logins", Value: bson.D{{Key: "$cond", Value: bson.A{
+				bson.D{{Key: "$gte", Value: bson.A{"$failed_logins", maxFailures}}}, 0, "$failed_logins",
+			}}}},
+		}}},
+	}
+	return st.updateUser(ctx, username, update)
+}
+
+func (st MongoStorage) resetFailedLogins(ctx context.Context, username string) (User, error) {
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "failed_logins", Value: 0}}}}
+	return st.updateUser(ctx, username, update)
+}
+
+// updateUser applies the update to the user and returns it updated.
+func (st MongoStorage) updateUser(ctx context.Context, username string, update any) (User, error) {
+	result := st.users.FindOneAndUpdate(
+		ctx,
+		bson.D{{Key: "_id", Value: username}},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	)
+	if result.Err() != nil {
+		if result.Err() == mongo.ErrNoDocuments {
+			return User{}, errorUserNotFound
+		}
+		return User{}, result.Err()
+	}
+
+	var user User
+	if err := result.Decode(&user); err != nil {
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+func (st MongoStorage) user(ctx context.Context, username string) (User, error) {
+	return st.userWithFilter(ctx, bson.D{{Key: "_id", Value: username}})
+}
+
+func (st MongoStorage) userByCPF(ctx context.Context, cpf string) (User, error) {
+	return st.userWithFilter(ctx, bson.D{{Key: "cpf", Value: cpf}})
+}
+
+func (st MongoStorage) userWithFilter(ctx context.Context, filter any) (User, error) {
+	result := st.users.FindOne(ctx, filter)
+	if result.Err() != nil {
+		if result.Err() == mongo.ErrNoDocuments {
+			return User{}, errorUserNotFound
+		}
+		return User{}, result.Err()
+	}
+
+	var user User
+	if err := result.Decode(&user); err != nil {
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+func (st MongoStorage) allUsers(ctx context.Context) ([]User, error) {
+	cursor, err := st.users.Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+
+	users := []User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (st MongoStorage) delete(ctx context.Context, username string) error {
+	filter := bson.D{{Key: "_id", Value: username}}
+	result, err := st.users.DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return errorUserNotFound
+	}
+
+	return nil
+}
+
+func (st MongoStorage) createCompany(ctx context.Context, company Company) error {
+	if _, err := st.companies.InsertOne(ctx, company); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errorCompanyAlreadyExists
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (st MongoStorage) saveCompany(ctx context.Context, company Company) error {
+	shouldUpsert := true
+	filter := bson.D{{Key: "_id", Value: company.CNPJ}}
+	if _, err := st.companies.ReplaceOne(
+		ctx,
+		filter,
+		company,
+		&options.ReplaceOptions{Upsert: &shouldUpsert},
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (st MongoStorage) company(ctx context.Context, cnpj string) (Company, error) {
+	filter := bson.D{{Key: "_id", Value: cnpj}}
+	result := st.companies.FindOne(ctx, filter)
+	if result.Err() != nil {
+		if result.Err() == mongo.ErrNoDocuments {
+			return Company{}, errorCompanyNotFound
+		}
+		return Company{}, result.Err()
+	}
+
+	var company Company
+	if err := result.Decode(&company); err != nil {
+		return Company{}, err
+	}
+
+	return company, nil
+}
+
+func (st MongoStorage) allCompanies(ctx context.Context) ([]Company, error) {
+	cursor, err := st.companies.Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+
+	companies := []Company{}
+	if err := cursor.All(ctx, &companies); err != nil {
+		return nil, err
+	}
+
+	return companies, nil
+}
+
+func (st MongoStorage) deleteCompany(ctx context.Context, cnpj string) error {
+	filter := bson.D{{Key: "_id", Value: cnpj}}
+	result, err := st.companies.DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return errorCompanyNotFound
+	}
+
+	return nil
+}
+
+func (st MongoStorage) replaceAll(ctx context.Context, users []User, companies []Company) error {
+	if err := api.ReplaceAll(ctx, st.companies, companies); err != nil {
+		return err
+	}
+	return api.ReplaceAll(ctx, st.users, users)
+}